@@ -14,7 +14,6 @@ import (
 	"fmt"
 
 	"github.com/alejandroEsc/golang-maas-client/pkg/api"
-	"github.com/alejandroEsc/golang-maas-client/pkg/api/v2"
 	"github.com/spf13/viper"
 )
 
@@ -63,13 +62,7 @@ func main() {
 }
 
 func machines(maas *api.MAAS) {
-
-	params := v2.MachinesParams(v2.MachinesArgs{})
-	rawMachines, err := maas.Get("machines", "", params.Values)
-	checkError(err)
-
-	var machines []v2.Machine
-	err = json.Unmarshal(rawMachines, &machines)
+	machines, err := maas.Machines(api.MachinesArgs{})
 	checkError(err)
 
 	fmt.Printf("\nGot list of %v machines\n", len(machines))
@@ -97,17 +90,13 @@ func files(maas *api.MAAS) {
 
 	// Upload a file.
 	fmt.Println("Uploading a file...")
-	params := v2.FileParams(v2.AddFileArgs{Filename: fileName})
-
-	_, err = maas.PostFile("files", "", params.Values, fileContent)
+	err = maas.AddFile(api.AddFileArgs{Filename: fileName, Content: fileContent})
 	checkError(err)
 	fmt.Println("File sent.")
 
 	// Fetch the file.
-	fileBytes, err := maas.Get("files/"+fileName, "", nil)
+	file, err := maas.GetFile(fileName)
 	checkError(err)
-	var file v2.File
-	err = json.Unmarshal(fileBytes, &file)
 
 	if bytes.Compare([]byte(file.Content), fileContent) != 0 {
 		maas.Delete(file.ResourceURI)
@@ -116,7 +105,7 @@ func files(maas *api.MAAS) {
 	fmt.Println("Got file.")
 
 	// Fetch list of filesResource.
-	var listFiles []v2.File
+	var listFiles []api.File
 	listBytes, err := maas.Get("files", "", nil)
 	err = json.Unmarshal(listBytes, &listFiles)
 	checkError(err)
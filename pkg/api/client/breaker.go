@@ -0,0 +1,208 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failures that
+	// opens a Breaker when Breaker.Threshold is not set.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is how long a Breaker stays open before probing
+	// the server when Breaker.Cooldown is not set.
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// BreakerState is the state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every request without sending it.
+	BreakerOpen
+	// BreakerHalfOpen lets a few probe requests through to find whether
+	// the server has recovered.
+	BreakerHalfOpen
+)
+
+// String implements fmt.Stringer.
+func (state BreakerState) String() string {
+	switch state {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(state))
+}
+
+// Breaker is a circuit breaker protecting callers from an unhealthy server.
+// It opens after Threshold consecutive attempts fail with a transport
+// error or a 5xx response; while it is open, requests fail at once with a
+// util.CircuitOpenError, which is not retried. After Cooldown it lets
+// Probes requests through: it closes if they succeed and opens again if
+// they fail.
+//
+// The zero value is ready to use. A Breaker can be shared by the clients
+// talking to the same server.
+type Breaker struct {
+	// Threshold is the number of consecutive failures that opens the
+	// breaker. Zero means DefaultBreakerThreshold.
+	Threshold int
+	// Cooldown is how long the breaker stays open before probing the
+	// server. Zero means DefaultBreakerCooldown.
+	Cooldown time.Duration
+	// Probes is the number of requests let through at the same time while
+	// half-open. Zero means 1.
+	Probes int
+	// OnStateChange, when set, is called with every state transition. It
+	// is called synchronously by the request causing the transition, and
+	// must not use the breaker.
+	OnStateChange func(from, to BreakerState)
+	// Clock times the cooldown. Nil means WallClock.
+	Clock Clock
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Middleware returns a Middleware guarding every attempt with the breaker.
+// Setting MAASClient.Breaker already installs it, so it must not also be
+// added to MAASClient.Middleware.
+func (b *Breaker) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(call *Call) (*Result, error) {
+			probe, err := b.allow()
+			if err != nil {
+				return nil, err
+			}
+			result, err := next.Do(call)
+			b.record(probe, outcome(call, result, err))
+			return result, err
+		})
+	}
+}
+
+// attemptOutcome classifies an attempt for the breaker.
+type attemptOutcome int
+
+const (
+	attemptSucceeded attemptOutcome = iota
+	attemptFailed
+	// attemptAbandoned attempts tell nothing of the server's health.
+	attemptAbandoned
+)
+
+func outcome(call *Call, result *Result, err error) attemptOutcome {
+	switch {
+	case err == nil:
+		return attemptSucceeded
+	case result != nil && result.StatusCode >= 500:
+		return attemptFailed
+	case result != nil:
+		// The server is answering, even if it refuses the request.
+		return attemptSucceeded
+	case call.Request.Context().Err() != nil:
+		return attemptAbandoned
+	}
+	return attemptFailed
+}
+
+// allow returns whether an attempt can be sent, and whether it is a probe.
+func (b *Breaker) allow() (bool, error) {
+	b.mu.Lock()
+	from := b.state
+	if b.state == BreakerOpen && b.clock().Now().Sub(b.openedAt) >= b.cooldown() {
+		b.state = BreakerHalfOpen
+		b.probes = 0
+	}
+	var probe bool
+	var err error
+	switch b.state {
+	case BreakerOpen:
+		err = errors.Trace(util.NewCircuitOpenError(fmt.Sprintf("circuit breaker open after %d consecutive failures", b.failures)))
+	case BreakerHalfOpen:
+		maxProbes := b.Probes
+		if maxProbes < 1 {
+			maxProbes = 1
+		}
+		if b.probes >= maxProbes {
+			err = errors.Trace(util.NewCircuitOpenError("circuit breaker half-open, waiting for probes"))
+		} else {
+			b.probes++
+			probe = true
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	return probe, err
+}
+
+// record updates the breaker with the outcome of an attempt.
+func (b *Breaker) record(probe bool, outcome attemptOutcome) {
+	b.mu.Lock()
+	from := b.state
+	if probe {
+		b.probes--
+	}
+	switch outcome {
+	case attemptSucceeded:
+		b.failures = 0
+		if probe && b.state == BreakerHalfOpen {
+			b.state = BreakerClosed
+		}
+	case attemptFailed:
+		b.failures++
+		threshold := b.Threshold
+		if threshold == 0 {
+			threshold = DefaultBreakerThreshold
+		}
+		if (probe && b.state == BreakerHalfOpen) || (b.state == BreakerClosed && b.failures >= threshold) {
+			b.state = BreakerOpen
+			b.openedAt = b.clock().Now()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *Breaker) notify(from, to BreakerState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
+
+func (b *Breaker) cooldown() time.Duration {
+	if b.Cooldown == 0 {
+		return DefaultBreakerCooldown
+	}
+	return b.Cooldown
+}
+
+func (b *Breaker) clock() Clock {
+	if b.Clock == nil {
+		return WallClock
+	}
+	return b.Clock
+}
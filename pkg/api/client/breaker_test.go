@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type transition struct {
+	from, to BreakerState
+}
+
+func newTestBreaker(clock Clock) (*Breaker, *[]transition) {
+	var transitions []transition
+	breaker := &Breaker{
+		Threshold: 2,
+		Cooldown:  time.Minute,
+		Clock:     clock,
+		OnStateChange: func(from, to BreakerState) {
+			transitions = append(transitions, transition{from, to})
+		},
+	}
+	return breaker, &transitions
+}
+
+func TestBreakerOpensAndFailsFast(t *testing.T) {
+	server, nbRequests := newStatusServer(http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusBadGateway)
+	defer server.Close()
+	breaker, transitions := newTestBreaker(&fakeClock{})
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: &fakeClock{}, Breaker: breaker})
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.True(t, util.IsCircuitOpenError(err), "%v", err)
+	assert.Equal(t, *nbRequests, 2)
+	assert.Equal(t, breaker.State(), BreakerOpen)
+	assert.Equal(t, *transitions, []transition{{BreakerClosed, BreakerOpen}})
+
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.True(t, util.IsCircuitOpenError(err), "%v", err)
+	assert.Equal(t, *nbRequests, 2)
+}
+
+func TestBreakerClientErrorsKeepItClosed(t *testing.T) {
+	server, _ := newStatusServer(http.StatusNotFound, http.StatusConflict, http.StatusNotFound)
+	defer server.Close()
+	breaker, _ := newTestBreaker(&fakeClock{})
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Breaker: breaker})
+	assert.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+		_, ok := GetServerError(err)
+		assert.True(t, ok)
+	}
+
+	assert.Equal(t, breaker.State(), BreakerClosed)
+}
+
+func TestBreakerHalfOpenProbeCloses(t *testing.T) {
+	server, nbRequests := newStatusServer(http.StatusInternalServerError, http.StatusInternalServerError)
+	defer server.Close()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	breaker, transitions := newTestBreaker(clock)
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Breaker: breaker})
+	assert.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		client.Get(&url.URL{Path: "machines/"}, "", nil)
+	}
+	assert.Equal(t, breaker.State(), BreakerOpen)
+
+	clock.now = clock.now.Add(time.Minute)
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, *nbRequests, 3)
+	assert.Equal(t, breaker.State(), BreakerClosed)
+	assert.Equal(t, *transitions, []transition{
+		{BreakerClosed, BreakerOpen},
+		{BreakerOpen, BreakerHalfOpen},
+		{BreakerHalfOpen, BreakerClosed},
+	})
+}
+
+func TestBreakerHalfOpenProbeReopens(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	breaker, transitions := newTestBreaker(clock)
+	call := &Call{Request: (&http.Request{}).WithContext(context.Background())}
+	failed := func(next Doer) Doer {
+		return DoerFunc(func(*Call) (*Result, error) {
+			return &Result{StatusCode: http.StatusBadGateway}, ServerError{StatusCode: http.StatusBadGateway}
+		})
+	}
+	doer := breaker.Middleware()(failed(nil))
+	doer.Do(call)
+	doer.Do(call)
+
+	clock.now = clock.now.Add(time.Minute)
+	_, err := doer.Do(call)
+
+	assert.NotNil(t, err)
+	assert.False(t, util.IsCircuitOpenError(err))
+	assert.Equal(t, breaker.State(), BreakerOpen)
+	_, err = doer.Do(call)
+	assert.True(t, util.IsCircuitOpenError(err))
+	assert.Equal(t, *transitions, []transition{
+		{BreakerClosed, BreakerOpen},
+		{BreakerOpen, BreakerHalfOpen},
+		{BreakerHalfOpen, BreakerOpen},
+	})
+}
+
+func TestBreakerHalfOpenLimitsProbes(t *testing.T) {
+	breaker := &Breaker{state: BreakerHalfOpen}
+
+	probe, err := breaker.allow()
+	assert.True(t, probe)
+	assert.Nil(t, err)
+	_, err = breaker.allow()
+	assert.True(t, util.IsCircuitOpenError(err))
+
+	breaker.record(true, attemptAbandoned)
+	assert.Equal(t, breaker.State(), BreakerHalfOpen)
+	probe, err = breaker.allow()
+	assert.True(t, probe)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,433 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// Scrubbed replaces the values removed from recorded interactions.
+const Scrubbed = "[SCRUBBED]"
+
+// Cassette is a sequence of recorded HTTP interactions with a maas server,
+// saved as JSON so that it can be replayed by tests.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response the server gave to it.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the part of a request used to match it on replay.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Op     string `json:"op,omitempty"`
+	// Params holds the query parameters other than op and the form
+	// parameters of the body. File parts of multipart bodies are recorded
+	// as their file name.
+	Params url.Values  `json:"params,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// RecordedResponse is a response replayed as recorded.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// LoadCassette reads a cassette saved by Save.
+func LoadCassette(filename string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, errors.Annotatef(err, "cannot parse cassette %q", filename)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to filename, replacing it.
+func (c *Cassette) Save(filename string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(filename, append(data, '\n'), 0644))
+}
+
+// Scrubbing describes what a Recorder removes from the interactions before
+// keeping them. Names are matched case-insensitively against patterns
+// using the syntax of path.Match.
+type Scrubbing struct {
+	// Headers are patterns of request and response headers that are not
+	// recorded at all.
+	Headers []string
+	// Params are patterns of request parameters whose values are scrubbed.
+	Params []string
+	// Keys are patterns of keys whose values are scrubbed, at any depth,
+	// from JSON response bodies.
+	Keys []string
+}
+
+// DefaultScrubbing returns the Scrubbing used by recorders that are not
+// given one. It removes the OAuth signature, the session and CSRF cookies,
+// passwords, secrets and tokens, and the power parameters of machines.
+func DefaultScrubbing() *Scrubbing {
+	return &Scrubbing{
+		Headers: []string{"Authorization", "Cookie", "Set-Cookie", csrfHeaderName},
+		Params:  []string{"power_parameters*", "*password*", "*secret*", "*token*", "csrfmiddlewaretoken"},
+		Keys:    []string{"power_parameters", "power_pass", "*password*", "*secret*", "*token*", "consumer_key"},
+	}
+}
+
+func (s *Scrubbing) header(header http.Header) http.Header {
+	scrubbed := make(http.Header, len(header))
+	for name, values := range header {
+		if !scrubMatch(name, s.Headers) {
+			scrubbed[name] = values
+		}
+	}
+	if len(scrubbed) == 0 {
+		return nil
+	}
+	return scrubbed
+}
+
+func (s *Scrubbing) params(params url.Values) url.Values {
+	for name, values := range params {
+		if scrubMatch(name, s.Params) {
+			for i := range values {
+				values[i] = Scrubbed
+			}
+		}
+	}
+	return params
+}
+
+func (s *Scrubbing) body(body []byte) string {
+	var decoded interface{}
+	if len(s.Keys) == 0 || json.Unmarshal(body, &decoded) != nil {
+		return string(body)
+	}
+	if !s.value(decoded) {
+		// Keep the server's formatting when there is nothing to scrub.
+		return string(body)
+	}
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		return string(body)
+	}
+	return string(encoded)
+}
+
+// value scrubs a decoded JSON value in place, and reports whether anything
+// was scrubbed.
+func (s *Scrubbing) value(value interface{}) bool {
+	var scrubbed bool
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if scrubMatch(key, s.Keys) {
+				value[key] = Scrubbed
+				scrubbed = true
+			} else if s.value(item) {
+				scrubbed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if s.value(item) {
+				scrubbed = true
+			}
+		}
+	}
+	return scrubbed
+}
+
+func scrubMatch(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Recorder is an http.RoundTripper sending requests with Transport and
+// recording them and their responses, scrubbed, into a cassette. Use it as
+// the Transport of the Options of a client talking to a real maas, then
+// Save the cassette for a Replayer.
+type Recorder struct {
+	// Transport sends the requests. Nil means http.DefaultTransport.
+	Transport http.RoundTripper
+	// Scrubbing is applied to the recorded interactions. Nil means
+	// DefaultScrubbing.
+	Scrubbing *Scrubbing
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(request, r.scrubbing())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: *recorded,
+		Response: RecordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     r.scrubbing().header(response.Header),
+			Body:       r.scrubbing().body(body),
+		},
+	})
+	return response, nil
+}
+
+// Cassette returns a copy of the interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]*Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Save writes the interactions recorded so far to filename.
+func (r *Recorder) Save(filename string) error {
+	return r.Cassette().Save(filename)
+}
+
+func (r *Recorder) scrubbing() *Scrubbing {
+	if r.Scrubbing == nil {
+		return DefaultScrubbing()
+	}
+	return r.Scrubbing
+}
+
+// recordRequest returns the scrubbed recording of request, leaving its body
+// ready to be sent.
+func recordRequest(request *http.Request, scrubbing *Scrubbing) (*RecordedRequest, error) {
+	query := request.URL.Query()
+	op := query.Get("op")
+	query.Del("op")
+	params, err := bodyParams(request)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for name, values := range query {
+		params[name] = append(values, params[name]...)
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+	return &RecordedRequest{
+		Method: request.Method,
+		Path:   request.URL.Path,
+		Op:     op,
+		Params: scrubbing.params(params),
+		Header: scrubbing.header(request.Header),
+	}, nil
+}
+
+// bodyParams returns the form parameters of the body of request, which is
+// replaced by a copy.
+func bodyParams(request *http.Request) (url.Values, error) {
+	params := make(url.Values)
+	if request.Body == nil || request.Body == http.NoBody {
+		return params, nil
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	mediaType, mediaParams, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		return url.ParseQuery(string(body))
+	case "multipart/form-data":
+		reader := multipart.NewReader(bytes.NewReader(body), mediaParams["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return params, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if part.FileName() != "" {
+				params.Add(part.FormName(), part.FileName())
+				continue
+			}
+			value, err := ioutil.ReadAll(part)
+			if err != nil {
+				return nil, err
+			}
+			params.Add(part.FormName(), string(value))
+		}
+	}
+	return params, nil
+}
+
+// Match selects the parts of a request a Replayer compares with the
+// recorded ones.
+type Match int
+
+const (
+	// MatchMethod compares the HTTP methods.
+	MatchMethod Match = 1 << iota
+	// MatchPath compares the URL paths, but not the scheme or host, so
+	// that a cassette can be replayed against any server URL.
+	MatchPath
+	// MatchOp compares the op query parameters.
+	MatchOp
+	// MatchParams compares the other parameters, in any order, after
+	// scrubbing.
+	MatchParams
+
+	// DefaultMatch compares everything.
+	DefaultMatch = MatchMethod | MatchPath | MatchOp | MatchParams
+)
+
+// Replayer is an http.RoundTripper answering requests with the responses of
+// a cassette instead of sending them. Each request is answered by the first
+// interaction that matches it and was not replayed yet; a request matching
+// none fails with a NotFound error.
+type Replayer struct {
+	// Match is what is compared to find the interaction answering a
+	// request. Zero means DefaultMatch.
+	Match Match
+	// Scrubbing is applied to the requests before comparing their
+	// parameters, and must be the one the cassette was recorded with. Nil
+	// means DefaultScrubbing.
+	Scrubbing *Scrubbing
+
+	mu       sync.Mutex
+	cassette *Cassette
+	replayed []bool
+}
+
+// NewReplayer returns a Replayer of the cassette.
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{
+		cassette: cassette,
+		replayed: make([]bool, len(cassette.Interactions)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(request *http.Request) (*http.Response, error) {
+	scrubbing := r.Scrubbing
+	if scrubbing == nil {
+		scrubbing = DefaultScrubbing()
+	}
+	recorded, err := recordRequest(request, scrubbing)
+	if request.Body != nil {
+		request.Body.Close()
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.replayed[i] || !r.matches(&interaction.Request, recorded) {
+			continue
+		}
+		r.replayed[i] = true
+		recordedResponse := interaction.Response
+		header := http.Header{}
+		for name, values := range recordedResponse.Header {
+			header[name] = append([]string(nil), values...)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recordedResponse.StatusCode, http.StatusText(recordedResponse.StatusCode)),
+			StatusCode:    recordedResponse.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(recordedResponse.Body)),
+			ContentLength: int64(len(recordedResponse.Body)),
+			Request:       request,
+		}, nil
+	}
+	return nil, errors.NotFoundf("recorded interaction for %s %s?op=%s", recorded.Method, recorded.Path, recorded.Op)
+}
+
+// Unplayed returns the interactions of the cassette that were not replayed
+// yet.
+func (r *Replayer) Unplayed() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unplayed []*Interaction
+	for i, interaction := range r.cassette.Interactions {
+		if !r.replayed[i] {
+			unplayed = append(unplayed, interaction)
+		}
+	}
+	return unplayed
+}
+
+func (r *Replayer) matches(recorded, request *RecordedRequest) bool {
+	match := r.Match
+	if match == 0 {
+		match = DefaultMatch
+	}
+	switch {
+	case match&MatchMethod != 0 && recorded.Method != request.Method:
+		return false
+	case match&MatchPath != 0 && recorded.Path != request.Path:
+		return false
+	case match&MatchOp != 0 && recorded.Op != request.Op:
+		return false
+	case match&MatchParams != 0 && !sameParams(recorded.Params, request.Params):
+		return false
+	}
+	return true
+}
+
+// sameParams reports whether a and b hold the same values for the same
+// names, regardless of the order of the names.
+func sameParams(a, b url.Values) bool {
+	return a.Encode() == b.Encode()
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRecordedServer() *SimpleTestServer {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/machines/?hostname=node-1", http.StatusOK, `[{"system_id": "4y3ha3", "power_parameters": {"power_pass": "hunter2"}}]`)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, `{"system_id": "4y3ha3"}`)
+	server.AddPostResponse("/api/2.0/account/?op=create_authorisation_token", http.StatusOK, `{"token_key": "k", "token_secret": "s", "consumer_key": "c"}`)
+	server.Start()
+	return server
+}
+
+// isReplayMiss reports whether err is a Replayer failing to find an
+// interaction.
+func isReplayMiss(err error) bool {
+	urlErr, ok := errors.Cause(err).(*url.Error)
+	return ok && errors.IsNotFound(urlErr.Err)
+}
+
+func TestRecorderScrubsInteractions(t *testing.T) {
+	server := newRecordedServer()
+	defer server.Close()
+	recorder := &Recorder{}
+	client, err := NewAuthenticatedMAASClientWithOptions(AddAPIVersionToURL(server.URL, "2.0"), "a:b:c", Options{Transport: recorder})
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", url.Values{"hostname": {"node-1"}})
+	assert.Nil(t, err)
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", url.Values{"zone": {"z1"}, "password": {"hunter2"}}, nil)
+	assert.Nil(t, err)
+	body, err := client.Post(&url.URL{Path: "account/"}, "create_authorisation_token", nil, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, string(body), `{"token_key": "k", "token_secret": "s", "consumer_key": "c"}`)
+	interactions := recorder.Cassette().Interactions
+	assert.Len(t, interactions, 3)
+	assert.Equal(t, interactions[0].Request.Method, "GET")
+	assert.Equal(t, interactions[0].Request.Path, "/api/2.0/machines/")
+	assert.Equal(t, interactions[0].Request.Params, url.Values{"hostname": {"node-1"}})
+	assert.Equal(t, interactions[0].Request.Header.Get("Authorization"), "")
+	assert.Equal(t, interactions[0].Response.Body, `[{"power_parameters":"[SCRUBBED]","system_id":"4y3ha3"}]`)
+	assert.Equal(t, interactions[1].Request.Op, "allocate")
+	assert.Equal(t, interactions[1].Request.Params, url.Values{"zone": {"z1"}, "password": {Scrubbed}})
+	assert.Equal(t, interactions[1].Response.Body, `{"system_id": "4y3ha3"}`)
+	assert.Equal(t, interactions[2].Response.Body, `{"consumer_key":"[SCRUBBED]","token_key":"[SCRUBBED]","token_secret":"[SCRUBBED]"}`)
+}
+
+func TestRecorderMultipartParams(t *testing.T) {
+	server := NewSimpleServer()
+	server.AddPostResponse("/api/2.0/files/?op=add", http.StatusOK, `{}`)
+	server.Start()
+	defer server.Close()
+	recorder := &Recorder{}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Transport: recorder})
+	assert.Nil(t, err)
+
+	_, err = client.Post(&url.URL{Path: "files/"}, "add", url.Values{"filename": {"f"}}, map[string][]byte{"file": []byte("content")})
+
+	assert.Nil(t, err)
+	assert.Equal(t, recorder.Cassette().Interactions[0].Request.Params, url.Values{"filename": {"f"}, "file": {"file"}})
+}
+
+func TestCassetteReplay(t *testing.T) {
+	server := newRecordedServer()
+	recorder := &Recorder{}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Transport: recorder})
+	assert.Nil(t, err)
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", url.Values{"zone": {"z1"}, "password": {"hunter2"}}, nil)
+	assert.Nil(t, err)
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", url.Values{"hostname": {"node-1"}})
+	assert.Nil(t, err)
+	server.Close()
+	dir, err := ioutil.TempDir("", "maas-cassette")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "cassette.json")
+	assert.Nil(t, recorder.Save(filename))
+
+	cassette, err := LoadCassette(filename)
+	assert.Nil(t, err)
+	replayer := NewReplayer(cassette)
+	client, err = NewAnonymousClientWithOptions("http://maas.invalid/", "2.0", Options{Transport: replayer})
+	assert.Nil(t, err)
+	// Interactions are matched out of order, on any host, with the
+	// scrubbed parameters.
+	body, err := client.Get(&url.URL{Path: "machines/"}, "", url.Values{"hostname": {"node-1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), `[{"power_parameters":"[SCRUBBED]","system_id":"4y3ha3"}]`)
+	assert.Len(t, replayer.Unplayed(), 1)
+	body, err = client.Post(&url.URL{Path: "machines/"}, "allocate", url.Values{"password": {"other"}, "zone": {"z1"}}, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), `{"system_id": "4y3ha3"}`)
+	assert.Len(t, replayer.Unplayed(), 0)
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", url.Values{"hostname": {"node-1"}})
+	assert.True(t, isReplayMiss(err), "%v", err)
+}
+
+func TestReplayerMatch(t *testing.T) {
+	cassette := &Cassette{Interactions: []*Interaction{{
+		Request:  RecordedRequest{Method: "POST", Path: "/api/2.0/machines/", Op: "allocate", Params: url.Values{"zone": {"z1"}}},
+		Response: RecordedResponse{StatusCode: http.StatusConflict, Body: "no machine"},
+	}}}
+	replayer := NewReplayer(cassette)
+	client, err := NewAnonymousClientWithOptions("http://maas.invalid/", "2.0", Options{Transport: replayer})
+	assert.Nil(t, err)
+
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", url.Values{"zone": {"z2"}}, nil)
+	assert.True(t, isReplayMiss(err), "%v", err)
+
+	replayer.Match = MatchMethod | MatchPath | MatchOp
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", url.Values{"zone": {"z2"}}, nil)
+	svrErr, ok := GetServerError(err)
+	assert.True(t, ok, "%v", err)
+	assert.Equal(t, svrErr.StatusCode, http.StatusConflict)
+	assert.Equal(t, svrErr.BodyMessage, "no machine")
+}
@@ -5,6 +5,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,18 +13,15 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
 	"github.com/juju/errors"
 )
 
 const (
-	// Number of retries performed when the server returns a 503
-	// response with a 'Retry-after' header.  A request will be issued
-	// at most NumberOfRetries + 1 times.
+	// Number of retries performed by the default retry policy.  A
+	// request will be issued at most NumberOfRetries + 1 times.
 	NumberOfRetries = 4
 
 	RetryAfterHeaderName = "Retry-After"
@@ -34,6 +32,31 @@ const (
 type MAASClient struct {
 	APIURL *url.URL
 	Signer OAuthSigner
+	// HTTPClient sends the requests. It is safe for concurrent use and pools
+	// keep-alive connections, so it should be shared rather than rebuilt.
+	// When nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// RetryPolicy decides which failed requests are sent again. When nil,
+	// DefaultRetryPolicy is used.
+	RetryPolicy RetryPolicy
+	// Clock times the waits between retries. When nil, WallClock is used.
+	Clock Clock
+	// Middleware wraps every attempt at sending a request, the first
+	// being the outermost.
+	Middleware []Middleware
+	// Metrics, when set, records every attempt at sending a request.
+	Metrics *Metrics
+	// Tracer, when set, starts a span for every attempt at sending a
+	// request.
+	Tracer Tracer
+	// Limiter, when set, caps the rate and concurrency of the attempts at
+	// sending requests.
+	Limiter *Limiter
+	// Breaker, when set, stops sending requests to a failing server.
+	Breaker *Breaker
+	// Endpoints, when set, sends the requests to one of several region
+	// controllers instead of the host of APIURL.
+	Endpoints *EndpointPool
 }
 
 // ServerError is an http error (or at least, a non-2xx result) received from
@@ -69,50 +92,81 @@ func readAndClose(stream io.ReadCloser) ([]byte, error) {
 // MAASClient-side errors will return an empty response and a non-nil error.  For
 // server-side errors however (i.e. responses with a non 2XX status code), the
 // returned error will be ServerError and the returned body will reflect the
-// server's response.  If the server returns a 503 response with a 'Retry-after'
-// header, the request will be transparenty retried.
+// server's response.  Failed attempts are transparently retried as decided by
+// the client's RetryPolicy.  Cancelling the request's context aborts the
+// request, including any wait between retries.
 func (client MAASClient) dispatchRequest(request *http.Request) ([]byte, error) {
-	// First, store the request's body into a byte[] to be able to restore it
-	// after each request.
-	bodyContent, err := readAndClose(request.Body)
-	if err != nil {
-		return nil, err
+	ctx := request.Context()
+	getBody := request.GetBody
+	if getBody == nil {
+		// Store the request's body into a byte[] to be able to restore it
+		// after each request.
+		bodyContent, err := readAndClose(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		getBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(bodyContent)), nil
+		}
 	}
-	for retry := 0; retry < NumberOfRetries; retry++ {
+	policy := client.retryPolicy()
+	clock := client.clock()
+	requestID := requestID(ctx)
+	op := request.URL.Query().Get("op")
+	var lastBody []byte
+	var lastErr error
+	for attempt := 1; ; attempt++ {
 		// Restore body before issuing request.
-		newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
-		request.Body = newBody
-		body, err := client.dispatchSingleRequest(request)
-		// If this is a 503 response with a non-void "Retry-After" header: wait
-		// as instructed and retry the request.
+		newBody, err := getBody()
 		if err != nil {
-			serverError, ok := errors.Cause(err).(ServerError)
-			if ok && serverError.StatusCode == http.StatusServiceUnavailable {
-				retry_time_int, errConv := strconv.Atoi(serverError.Header.Get(RetryAfterHeaderName))
-				if errConv == nil {
-					select {
-					case <-time.After(time.Duration(retry_time_int) * time.Second):
-					}
-					continue
-				}
+			if attempt > 1 {
+				// The body cannot be sent again, so the request is not
+				// retried after all: report why the last attempt failed.
+				return lastBody, lastErr
 			}
+			return nil, err
+		}
+		request.Body = newBody
+		body, err := client.dispatchSingleRequest(client.newCall(request, requestID, attempt))
+		if err == nil || ctx.Err() != nil {
+			return body, err
+		}
+		lastBody, lastErr = body, err
+		delay, retry := policy.Retry(RetryAttempt{
+			Number: attempt,
+			Method: request.Method,
+			Op:     op,
+			Err:    err,
+			Now:    clock.Now(),
+		})
+		if !retry {
+			return body, err
+		}
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		return body, err
 	}
-	// Restore body before issuing request.
-	newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
-	request.Body = newBody
-	return client.dispatchSingleRequest(request)
-}
-
-func (client MAASClient) dispatchSingleRequest(request *http.Request) ([]byte, error) {
-	client.Signer.OAuthSign(request)
-	httpClient := http.Client{}
-	// See https://code.google.com/p/go/issues/detail?id=4677
-	// We need to force the connection to close each time so that we don't
-	// hit the above Go bug.
-	request.Close = true
-	response, err := httpClient.Do(request)
+}
+
+// dispatchSingleRequest makes one attempt at sending the call's request,
+// through the client's middleware.
+func (client MAASClient) dispatchSingleRequest(call *Call) ([]byte, error) {
+	result, err := client.chain(DoerFunc(client.send)).Do(call)
+	if result == nil {
+		return nil, err
+	}
+	return result.Body, err
+}
+
+// send signs and sends the call's request, and reads the response.
+func (client MAASClient) send(call *Call) (*Result, error) {
+	request := call.Request
+	if err := client.Signer.OAuthSign(request); err != nil {
+		return nil, errors.Annotate(err, "cannot sign request")
+	}
+	response, err := client.httpClient().Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -120,11 +174,41 @@ func (client MAASClient) dispatchSingleRequest(request *http.Request) ([]byte, e
 	if err != nil {
 		return nil, err
 	}
+	result := &Result{StatusCode: response.StatusCode, Header: response.Header, Body: body}
 	if response.StatusCode < 200 || response.StatusCode > 299 {
-		err := errors.Errorf("ServerError: %v (%s)", response.Status, body)
-		return body, errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(body)})
+		return result, newServerError(response, body)
+	}
+	return result, nil
+}
+
+// newServerError returns the error for a non-2xx response.
+func newServerError(response *http.Response, body []byte) error {
+	err := errors.Errorf("ServerError: %v (%s)", response.Status, body)
+	return errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(body)})
+}
+
+// httpClient returns the http.Client used to send requests.
+func (client MAASClient) httpClient() *http.Client {
+	if client.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return client.HTTPClient
+}
+
+// retryPolicy returns the RetryPolicy deciding which requests are retried.
+func (client MAASClient) retryPolicy() RetryPolicy {
+	if client.RetryPolicy == nil {
+		return DefaultRetryPolicy()
 	}
-	return body, nil
+	return client.RetryPolicy
+}
+
+// clock returns the Clock timing the waits between retries.
+func (client MAASClient) clock() Clock {
+	if client.Clock == nil {
+		return WallClock
+	}
+	return client.Clock
 }
 
 // GetURL returns the URL to a given resource on the API, based on its URI.
@@ -138,6 +222,25 @@ func (client MAASClient) GetURL(uri *url.URL) *url.URL {
 // invocation (if you pass its Name in "operation") or plain resource
 // retrieval (if you leave "operation" blank).
 func (client MAASClient) Get(uri *url.URL, operation string, parameters url.Values) ([]byte, error) {
+	return client.GetContext(context.Background(), uri, operation, parameters)
+}
+
+// GetContext is like Get but the request is bound to ctx, so it is abandoned
+// when ctx is cancelled or its deadline passes.
+func (client MAASClient) GetContext(ctx context.Context, uri *url.URL, operation string, parameters url.Values) ([]byte, error) {
+	queryUrl, err := client.getQueryURL(uri, operation, parameters)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("GET", queryUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.dispatchRequest(request.WithContext(ctx))
+}
+
+// getQueryURL returns the URL of a GET request for the operation.
+func (client MAASClient) getQueryURL(uri *url.URL, operation string, parameters url.Values) (*url.URL, error) {
 	if parameters == nil {
 		parameters = make(url.Values)
 	}
@@ -151,25 +254,7 @@ func (client MAASClient) Get(uri *url.URL, operation string, parameters url.Valu
 	}
 	queryUrl := client.GetURL(uri)
 	queryUrl.RawQuery = parameters.Encode()
-	request, err := http.NewRequest("GET", queryUrl.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	return client.dispatchRequest(request)
-}
-
-// writeMultiPartFiles writes the given files as parts of a multipart message
-// using the given writer.
-func writeMultiPartFiles(writer *multipart.Writer, files map[string][]byte) error {
-	for fileName, fileContent := range files {
-
-		fw, err := writer.CreateFormFile(fileName, fileName)
-		if err != nil {
-			return err
-		}
-		io.Copy(fw, bytes.NewBuffer(fileContent))
-	}
-	return nil
+	return queryUrl, nil
 }
 
 // writeMultiPartParams writes the given parameters as parts of a multipart
@@ -192,65 +277,66 @@ func writeMultiPartParams(writer *multipart.Writer, parameters url.Values) error
 // nonIdempotentRequestFiles implements the common functionality of PUT and
 // POST requests (but not GET or DELETE requests) when uploading files is
 // needed.
-func (client MAASClient) nonIdempotentRequestFiles(method string, uri *url.URL, parameters url.Values, files map[string][]byte) ([]byte, error) {
-	buf := new(bytes.Buffer)
-	writer := multipart.NewWriter(buf)
-	err := writeMultiPartFiles(writer, files)
-	if err != nil {
-		return nil, err
-	}
-	err = writeMultiPartParams(writer, parameters)
-	if err != nil {
-		return nil, err
-	}
-	writer.Close()
-	url := client.GetURL(uri)
-	request, err := http.NewRequest(method, url.String(), buf)
-	if err != nil {
-		return nil, err
+func (client MAASClient) nonIdempotentRequestFiles(ctx context.Context, method string, uri *url.URL, parameters url.Values, files map[string][]byte) ([]byte, error) {
+	upload := Upload{Params: parameters}
+	for fileName, fileContent := range files {
+		upload.Files = append(upload.Files, BytesUploadFile(fileName, fileContent))
 	}
-	request.Header.Set("Content-Type", writer.FormDataContentType())
-	return client.dispatchRequest(request)
-
+	return client.streamRequest(ctx, method, uri, upload)
 }
 
 // nonIdempotentRequest implements the common functionality of PUT and POST
 // requests (but not GET or DELETE requests).
-func (client MAASClient) nonIdempotentRequest(method string, uri *url.URL, parameters url.Values) ([]byte, error) {
+func (client MAASClient) nonIdempotentRequest(ctx context.Context, method string, uri *url.URL, parameters url.Values) ([]byte, error) {
 	url := client.GetURL(uri)
 	request, err := http.NewRequest(method, url.String(), strings.NewReader(string(parameters.Encode())))
 	if err != nil {
 		return nil, err
 	}
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	return client.dispatchRequest(request)
+	return client.dispatchRequest(request.WithContext(ctx))
 }
 
 // Post performs an HTTP "POST" to the API.  This may be either an API method
 // invocation (if you pass its Name in "operation") or plain resource
 // retrieval (if you leave "operation" blank).
 func (client MAASClient) Post(uri *url.URL, operation string, parameters url.Values, files map[string][]byte) ([]byte, error) {
+	return client.PostContext(context.Background(), uri, operation, parameters, files)
+}
+
+// PostContext is like Post but the request is bound to ctx.
+func (client MAASClient) PostContext(ctx context.Context, uri *url.URL, operation string, parameters url.Values, files map[string][]byte) ([]byte, error) {
 	queryParams := url.Values{"op": {operation}}
 	uri.RawQuery = queryParams.Encode()
 	if files != nil {
-		return client.nonIdempotentRequestFiles("POST", uri, parameters, files)
+		return client.nonIdempotentRequestFiles(ctx, "POST", uri, parameters, files)
 	}
-	return client.nonIdempotentRequest("POST", uri, parameters)
+	return client.nonIdempotentRequest(ctx, "POST", uri, parameters)
 }
 
 // Put updates an object on the API, using an HTTP "PUT" request.
 func (client MAASClient) Put(uri *url.URL, parameters url.Values) ([]byte, error) {
-	return client.nonIdempotentRequest("PUT", uri, parameters)
+	return client.PutContext(context.Background(), uri, parameters)
+}
+
+// PutContext is like Put but the request is bound to ctx.
+func (client MAASClient) PutContext(ctx context.Context, uri *url.URL, parameters url.Values) ([]byte, error) {
+	return client.nonIdempotentRequest(ctx, "PUT", uri, parameters)
 }
 
 // Delete deletes an object on the API, using an HTTP "DELETE" request.
 func (client MAASClient) Delete(uri *url.URL) error {
+	return client.DeleteContext(context.Background(), uri)
+}
+
+// DeleteContext is like Delete but the request is bound to ctx.
+func (client MAASClient) DeleteContext(ctx context.Context, uri *url.URL) error {
 	url := client.GetURL(uri)
 	request, err := http.NewRequest("DELETE", url.String(), strings.NewReader(""))
 	if err != nil {
 		return err
 	}
-	_, err = client.dispatchRequest(request)
+	_, err = client.dispatchRequest(request.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -267,6 +353,11 @@ func (signer anonSigner) OAuthSign(request *http.Request) error {
 // *anonSigner implements the OAuthSigner interface.
 var _ OAuthSigner = anonSigner{}
 
+// AnonymousSigner returns an OAuthSigner that leaves requests unsigned.
+func AnonymousSigner() OAuthSigner {
+	return anonSigner{}
+}
+
 // AddAPIVersionToURL will add the version/<version>/ suffix to the
 // given URL, handling trailing slashes. It shouldn't be called with a
 // URL that already includes a version.
@@ -296,12 +387,34 @@ func SplitVersionedURL(url string) (string, string, bool) {
 // http://my.maas.server.example.com/MAAS/
 // APIVersion should contain the version of the maas API that you want to use.
 func NewAnonymousClient(BaseURL string, apiVersion string) (*MAASClient, error) {
+	return NewAnonymousClientWithOptions(BaseURL, apiVersion, Options{})
+}
+
+// NewAnonymousClientWithOptions is like NewAnonymousClient but sends its
+// requests with the http.Client described by opts.
+func NewAnonymousClientWithOptions(BaseURL string, apiVersion string, opts Options) (*MAASClient, error) {
 	versionedURL := AddAPIVersionToURL(BaseURL, apiVersion)
 	parsedURL, err := url.Parse(versionedURL)
 	if err != nil {
 		return nil, err
 	}
-	return &MAASClient{Signer: &anonSigner{}, APIURL: parsedURL}, nil
+	httpClient, err := opts.Client()
+	if err != nil {
+		return nil, err
+	}
+	return &MAASClient{
+		Signer:      &anonSigner{},
+		APIURL:      parsedURL,
+		HTTPClient:  httpClient,
+		RetryPolicy: opts.RetryPolicy,
+		Clock:       opts.Clock,
+		Middleware:  opts.Middleware,
+		Metrics:     opts.Metrics,
+		Tracer:      opts.Tracer,
+		Limiter:     opts.Limiter,
+		Breaker:     opts.Breaker,
+		Endpoints:   opts.Endpoints,
+	}, nil
 }
 
 // NewAuthenticatedMAASClient parses the given maas API key into the
@@ -311,6 +424,12 @@ func NewAnonymousClient(BaseURL string, apiVersion string) (*MAASClient, error)
 // the maas server, e.g.:
 // http://my.maas.server.example.com/MAAS/api/2.0/
 func NewAuthenticatedMAASClient(versionedURL, apiKey string) (*MAASClient, error) {
+	return NewAuthenticatedMAASClientWithOptions(versionedURL, apiKey, Options{})
+}
+
+// NewAuthenticatedMAASClientWithOptions is like NewAuthenticatedMAASClient
+// but sends its requests with the http.Client described by opts.
+func NewAuthenticatedMAASClientWithOptions(versionedURL, apiKey string, opts Options) (*MAASClient, error) {
 	elements := strings.Split(apiKey, ":")
 	if len(elements) != 3 {
 		errString := fmt.Sprintf("invalid API key %q; expected \"<consumer secret>:<token key>:<token secret>\"", apiKey)
@@ -323,7 +442,7 @@ func NewAuthenticatedMAASClient(versionedURL, apiKey string) (*MAASClient, error
 		TokenKey:       elements[1],
 		TokenSecret:    elements[2],
 	}
-	signer, err := NewPlainTestOAuthSigner(token, "maas API")
+	signer, err := NewOAuthSigner(opts.SignatureMethod, token, "maas API")
 	if err != nil {
 		return nil, err
 	}
@@ -331,5 +450,21 @@ func NewAuthenticatedMAASClient(versionedURL, apiKey string) (*MAASClient, error
 	if err != nil {
 		return nil, err
 	}
-	return &MAASClient{Signer: signer, APIURL: parsedURL}, nil
+	httpClient, err := opts.Client()
+	if err != nil {
+		return nil, err
+	}
+	return &MAASClient{
+		Signer:      signer,
+		APIURL:      parsedURL,
+		HTTPClient:  httpClient,
+		RetryPolicy: opts.RetryPolicy,
+		Clock:       opts.Clock,
+		Middleware:  opts.Middleware,
+		Metrics:     opts.Metrics,
+		Tracer:      opts.Tracer,
+		Limiter:     opts.Limiter,
+		Breaker:     opts.Breaker,
+		Endpoints:   opts.Endpoints,
+	}, nil
 }
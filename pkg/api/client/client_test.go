@@ -5,12 +5,15 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -103,6 +106,30 @@ func TestClientdispatchRequestRetriesIsLimited(t *testing.T) {
 	assert.Equal(t, svrError.StatusCode, 503)
 }
 
+func TestClientdispatchRequestRetryWaitIsCancellable(t *testing.T) {
+	URI := "/some/url/?param1=test"
+	nbRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		nbRequests++
+		writer.Header().Set(RetryAfterHeaderName, "60")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	assert.Nil(t, err)
+
+	start := time.Now()
+	_, err = client.dispatchRequest(request.WithContext(ctx))
+
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.True(t, time.Since(start) < 10*time.Second)
+	assert.Equal(t, nbRequests, 1)
+}
+
 func TestClientDispatchRequestReturnsNonServerError(t *testing.T) {
 	client, err := NewAnonymousClient("/foo", "1.0")
 	assert.Nil(t, err)
@@ -171,6 +198,24 @@ func TestClientGetFormatsOperationAsGetParameter(t *testing.T) {
 	assert.Equal(t, string(result), expectedResult)
 }
 
+func TestClientGetContextCancelled(t *testing.T) {
+	URI, err := url.Parse("/some/url")
+	assert.Nil(t, err)
+	server := newSingleServingServer(URI.String(), "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := client.GetContext(ctx, URI, "", nil)
+
+	assert.NotNil(t, err)
+	_, ok := GetServerError(err)
+	assert.False(t, ok)
+	assert.Nil(t, result)
+}
+
 func TestClientPostSendsRequestWithParams(t *testing.T) {
 	URI, err := url.Parse("/some/url")
 	assert.Nil(t, err)
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"net/url"
 
 	"github.com/juju/utils/set"
@@ -13,15 +14,37 @@ type ControllerInterface interface {
 
 	PostFile(path string, op string, params url.Values, fileContent []byte) ([]byte, error)
 
+	// PostStream posts a multipart upload streamed from its file sources.
+	PostStream(path string, op string, upload Upload) ([]byte, error)
+
 	Delete(path string) error
 
 	Get(path string, op string, params url.Values) ([]byte, error)
 
 	GetAPIVersionInfo() (set.Strings, error)
-}
 
+	// The *Context variants behave like the calls above, but abandon the
+	// request when ctx is cancelled or its deadline passes.
+
+	PutContext(ctx context.Context, path string, params url.Values) ([]byte, error)
+
+	PostContext(ctx context.Context, path string, op string, params url.Values) ([]byte, error)
+
+	PostFileContext(ctx context.Context, path string, op string, params url.Values, fileContent []byte) ([]byte, error)
 
+	PostStreamContext(ctx context.Context, path string, op string, upload Upload) ([]byte, error)
 
+	DeleteContext(ctx context.Context, path string) error
+
+	GetContext(ctx context.Context, path string, op string, params url.Values) ([]byte, error)
+
+	GetAPIVersionInfoContext(ctx context.Context) (set.Strings, error)
+}
+
+// ApiHelper is the raw, byte-returning helper surface.
+//
+// Deprecated: use the typed service interfaces of the v2 package, such as
+// v2.Services, v2.FileService and v2.NetworkService.
 type ApiHelper interface {
 
 	// Files
@@ -33,4 +56,4 @@ type ApiHelper interface {
 
 	//Spaces
 	Spaces() ([]byte, error)
-}
\ No newline at end of file
+}
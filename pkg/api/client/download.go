@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// DownloadOptions describes the content expected by a download. The content
+// is checked once fully written, and a mismatch returns a NotValid error.
+type DownloadOptions struct {
+	// Size, when not zero, is the expected length of the content.
+	Size int64
+	// SHA256, when set, is the hex encoded SHA-256 digest of the content.
+	SHA256 string
+}
+
+// Download performs an HTTP "GET" to the API like Get, but writes the body
+// to w as it is received rather than returning it. It returns the number of
+// bytes written.
+//
+// A download interrupted by a failure that the RetryPolicy retries resumes
+// where it stopped, asking the server for the missing range. Errors writing
+// to w are not retried.
+func (client MAASClient) Download(uri *url.URL, operation string, parameters url.Values, w io.Writer, opts DownloadOptions) (int64, error) {
+	return client.DownloadContext(context.Background(), uri, operation, parameters, w, opts)
+}
+
+// DownloadContext is like Download but the request is bound to ctx.
+func (client MAASClient) DownloadContext(ctx context.Context, uri *url.URL, operation string, parameters url.Values, w io.Writer, opts DownloadOptions) (int64, error) {
+	queryURL, err := client.getQueryURL(uri, operation, parameters)
+	if err != nil {
+		return 0, err
+	}
+	digest := sha256.New()
+	dest := &downloadWriter{w: w, digest: digest}
+	policy := client.retryPolicy()
+	clock := client.clock()
+	requestID := requestID(ctx)
+	for attempt := 1; ; attempt++ {
+		err := client.downloadRange(ctx, queryURL, dest, requestID, attempt)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return dest.written, ctx.Err()
+		}
+		if dest.err != nil {
+			return dest.written, errors.Annotate(dest.err, "cannot write download")
+		}
+		delay, retry := policy.Retry(RetryAttempt{
+			Number: attempt,
+			Method: "GET",
+			Op:     operation,
+			Err:    err,
+			Now:    clock.Now(),
+		})
+		if !retry {
+			return dest.written, err
+		}
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+			return dest.written, ctx.Err()
+		}
+	}
+	return dest.written, opts.check(dest.written, digest)
+}
+
+// downloadRange requests the content from the first byte not yet written,
+// and writes it to dest.
+func (client MAASClient) downloadRange(ctx context.Context, queryURL *url.URL, dest *downloadWriter, requestID int64, attempt int) error {
+	request, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	offset := dest.written
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	call := client.newCall(request.WithContext(ctx), requestID, attempt)
+	_, err = client.chain(DoerFunc(func(call *Call) (*Result, error) {
+		return client.receive(call, offset, dest)
+	})).Do(call)
+	return err
+}
+
+// receive signs and sends the call's request, and streams the response body
+// from offset to dest.
+func (client MAASClient) receive(call *Call, offset int64, dest io.Writer) (*Result, error) {
+	request := call.Request
+	if err := client.Signer.OAuthSign(request); err != nil {
+		return nil, errors.Annotate(err, "cannot sign request")
+	}
+	response, err := client.httpClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	result := &Result{StatusCode: response.StatusCode, Header: response.Header}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(response.Body)
+		result.Body = body
+		return result, newServerError(response, body)
+	}
+	if offset > 0 {
+		if response.StatusCode == http.StatusPartialContent {
+			if !strings.HasPrefix(response.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+				return result, errors.Errorf("unexpected content range %q resuming at %d", response.Header.Get("Content-Range"), offset)
+			}
+		} else if _, err := io.CopyN(ioutil.Discard, response.Body, offset); err != nil {
+			// The server ignored the range: skip what was already written.
+			return result, err
+		}
+	}
+	_, err = io.Copy(dest, response.Body)
+	return result, err
+}
+
+func (opts DownloadOptions) check(written int64, digest hash.Hash) error {
+	if opts.Size != 0 && written != opts.Size {
+		return errors.NotValidf("download of %d bytes, expected %d", written, opts.Size)
+	}
+	if opts.SHA256 != "" {
+		sum := hex.EncodeToString(digest.Sum(nil))
+		if !strings.EqualFold(sum, opts.SHA256) {
+			return errors.NotValidf("download with SHA-256 %s, expected %s", sum, opts.SHA256)
+		}
+	}
+	return nil
+}
+
+// downloadWriter writes to w while hashing and counting the content, and
+// keeps the error of w apart from errors reading the response.
+type downloadWriter struct {
+	w       io.Writer
+	digest  hash.Hash
+	written int64
+	err     error
+}
+
+func (d *downloadWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.digest.Write(p[:n])
+	d.written += int64(n)
+	if err != nil {
+		d.err = err
+	}
+	return n, err
+}
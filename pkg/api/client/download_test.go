@@ -0,0 +1,131 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	jujuerrors "github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const downloadContent = "kernel and initrd bundle"
+
+func downloadSHA256() string {
+	sum := sha256.Sum256([]byte(downloadContent))
+	return hex.EncodeToString(sum[:])
+}
+
+// newResumingServer serves downloadContent, dropping the connection half way
+// through the first response. When honourRange is set, later requests get
+// the range they ask for.
+func newResumingServer(t *testing.T, honourRange bool) (*httptest.Server, *[]string) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ranges = append(ranges, request.Header.Get("Range"))
+		if len(ranges) == 1 {
+			half := len(downloadContent) / 2
+			writer.Header().Set("Content-Length", fmt.Sprint(len(downloadContent)))
+			writer.WriteHeader(http.StatusOK)
+			writer.Write([]byte(downloadContent[:half]))
+			writer.(http.Flusher).Flush()
+			conn, _, err := writer.(http.Hijacker).Hijack()
+			assert.Nil(t, err)
+			conn.Close()
+			return
+		}
+		var start int
+		if _, err := fmt.Sscanf(request.Header.Get("Range"), "bytes=%d-", &start); honourRange && err == nil {
+			writer.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(downloadContent)-1, len(downloadContent)))
+			writer.WriteHeader(http.StatusPartialContent)
+			writer.Write([]byte(downloadContent[start:]))
+			return
+		}
+		writer.Write([]byte(downloadContent))
+	}))
+	return server, &ranges
+}
+
+func TestDownload(t *testing.T) {
+	server := newSingleServingServer("/api/2.0/files/?filename=boot&op=get", downloadContent, http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedMAASClient(server.URL+"/api/2.0/", "a:b:c")
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+
+	n, err := client.Download(&url.URL{Path: "files/"}, "get", url.Values{"filename": {"boot"}}, &buf, DownloadOptions{
+		Size:   int64(len(downloadContent)),
+		SHA256: downloadSHA256(),
+	})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, n, len(downloadContent))
+	assert.Equal(t, buf.String(), downloadContent)
+	assert.NotEqual(t, (*server.requestHeader).Get("Authorization"), "")
+}
+
+func TestDownloadChecksContent(t *testing.T) {
+	for _, opts := range []DownloadOptions{{Size: 3}, {SHA256: hex.EncodeToString(make([]byte, 32))}} {
+		server := newSingleServingServer("/api/2.0/files/", downloadContent, http.StatusOK)
+		client, err := NewAnonymousClient(server.URL, "2.0")
+		assert.Nil(t, err)
+
+		_, err = client.Download(&url.URL{Path: "files/"}, "", nil, &bytes.Buffer{}, opts)
+
+		assert.True(t, jujuerrors.IsNotValid(err), "%v", err)
+		server.Close()
+	}
+}
+
+func TestDownloadResumesWithRange(t *testing.T) {
+	server, ranges := newResumingServer(t, true)
+	defer server.Close()
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: &fakeClock{}})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+
+	n, err := client.Download(&url.URL{Path: "files/"}, "", nil, &buf, DownloadOptions{SHA256: downloadSHA256()})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, n, len(downloadContent))
+	assert.Equal(t, buf.String(), downloadContent)
+	assert.Equal(t, *ranges, []string{"", fmt.Sprintf("bytes=%d-", len(downloadContent)/2)})
+}
+
+func TestDownloadResumesWithoutRangeSupport(t *testing.T) {
+	server, ranges := newResumingServer(t, false)
+	defer server.Close()
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: &fakeClock{}})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+
+	_, err = client.Download(&url.URL{Path: "files/"}, "", nil, &buf, DownloadOptions{SHA256: downloadSHA256()})
+
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), downloadContent)
+	assert.Len(t, *ranges, 2)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestDownloadWriteErrorNotRetried(t *testing.T) {
+	server, ranges := newResumingServer(t, true)
+	defer server.Close()
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: &fakeClock{}})
+	assert.Nil(t, err)
+
+	_, err = client.Download(&url.URL{Path: "files/"}, "", nil, failingWriter{}, DownloadOptions{})
+
+	assert.Contains(t, err.Error(), "disk full")
+	assert.Len(t, *ranges, 1)
+}
@@ -0,0 +1,287 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+// DefaultEndpointRetryAfter is how long an endpoint is avoided after failing
+// when EndpointPool.RetryAfter is not set.
+const DefaultEndpointRetryAfter = 30 * time.Second
+
+// EndpointPool routes the requests of a MAASClient to one of several region
+// controllers of the same maas, failing over when the one in use fails.
+//
+// Requests go to the active endpoint, the first one initially. An attempt
+// failing with a transport error or a 5xx response marks its endpoint
+// unhealthy, and the next attempt goes to the next healthy endpoint, which
+// becomes the active one. Whether the failed request is attempted again is
+// decided by the client's RetryPolicy. The active endpoint only changes on
+// failure, so that a sequence of dependent mutations is sent to the same
+// region controller; see also WithEndpointAffinity.
+//
+// Unhealthy endpoints are used again once CheckHealth finds them healthy,
+// or after RetryAfter.
+type EndpointPool struct {
+	// RetryAfter is how long an unhealthy endpoint is avoided when no
+	// health check has found it healthy. Zero means
+	// DefaultEndpointRetryAfter.
+	RetryAfter time.Duration
+	// HTTPClient sends the health checks. Nil means http.DefaultClient.
+	HTTPClient *http.Client
+	// Clock times RetryAfter. Nil means WallClock.
+	Clock Clock
+
+	mu        sync.Mutex
+	endpoints []*endpointState
+	active    int
+}
+
+type endpointState struct {
+	url      *url.URL
+	healthy  bool
+	failedAt time.Time
+}
+
+// EndpointStatus describes an endpoint of an EndpointPool.
+type EndpointStatus struct {
+	URL     string
+	Healthy bool
+	Active  bool
+}
+
+// NewEndpointPool returns a pool of the versioned API URLs of the region
+// controllers, e.g. http://region-1.example.com/MAAS/api/2.0/.
+func NewEndpointPool(versionedURLs ...string) (*EndpointPool, error) {
+	if len(versionedURLs) == 0 {
+		return nil, errors.NotValidf("endpoint pool without endpoints")
+	}
+	pool := &EndpointPool{}
+	for _, versionedURL := range versionedURLs {
+		parsed, err := url.Parse(util.EnsureTrailingSlash(versionedURL))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		pool.endpoints = append(pool.endpoints, &endpointState{url: parsed, healthy: true})
+	}
+	return pool, nil
+}
+
+// SetAPIVersion points the endpoints at another version of the API. Their
+// health is kept, as it does not depend on the version. It is meant for
+// probing the versions a maas supports with a single pool, before the pool
+// is in use.
+func (p *EndpointPool) SetAPIVersion(apiVersion string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, endpoint := range p.endpoints {
+		base, _, includesVersion := SplitVersionedURL(endpoint.url.String())
+		if !includesVersion {
+			return errors.NotValidf("endpoint %s without API version", endpoint.url)
+		}
+		parsed, err := url.Parse(AddAPIVersionToURL(base, apiVersion))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		endpoint.url = parsed
+	}
+	return nil
+}
+
+// Status returns the status of the endpoints, in the order they were given.
+func (p *EndpointPool) Status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := make([]EndpointStatus, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		status[i] = EndpointStatus{
+			URL:     endpoint.url.String(),
+			Healthy: endpoint.healthy,
+			Active:  i == p.active,
+		}
+	}
+	return status
+}
+
+// CheckHealth requests the version endpoint of every region controller, and
+// marks them healthy or not accordingly. It returns an error if none is
+// healthy.
+func (p *EndpointPool) CheckHealth(ctx context.Context) error {
+	p.mu.Lock()
+	urls := make([]*url.URL, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		urls[i] = endpoint.url
+	}
+	p.mu.Unlock()
+
+	var healthy int
+	for i, endpointURL := range urls {
+		err := p.check(ctx, endpointURL)
+		if err == nil {
+			healthy++
+		}
+		p.record(i, err == nil)
+	}
+	if healthy == 0 {
+		return errors.Errorf("no healthy maas endpoint among %d", len(urls))
+	}
+	return nil
+}
+
+func (p *EndpointPool) check(ctx context.Context, endpointURL *url.URL) error {
+	request, err := http.NewRequest("GET", endpointURL.ResolveReference(&url.URL{Path: "version/"}).String(), nil)
+	if err != nil {
+		return err
+	}
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	// Like the requests sent through the pool, only server errors make an
+	// endpoint unhealthy: a 404 means the region controller is up, but does
+	// not serve this version of the API.
+	if response.StatusCode >= 500 {
+		return errors.Errorf("version endpoint answered %d", response.StatusCode)
+	}
+	return nil
+}
+
+// Run checks the health of the endpoints every interval until ctx is done.
+func (p *EndpointPool) Run(ctx context.Context, interval time.Duration) {
+	for {
+		p.CheckHealth(ctx)
+		select {
+		case <-p.clock().After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// choose returns the endpoint for the next attempt: preferred if set, or
+// else the active one, failing over to the next usable endpoint.
+func (p *EndpointPool) choose(preferred int) int {
+	if preferred >= 0 {
+		return preferred
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for offset := range p.endpoints {
+		i := (p.active + offset) % len(p.endpoints)
+		if p.usable(i) {
+			p.active = i
+			return i
+		}
+	}
+	// Every endpoint is unhealthy: keep trying the active one.
+	return p.active
+}
+
+func (p *EndpointPool) usable(i int) bool {
+	endpoint := p.endpoints[i]
+	if endpoint.healthy {
+		return true
+	}
+	retryAfter := p.RetryAfter
+	if retryAfter == 0 {
+		retryAfter = DefaultEndpointRetryAfter
+	}
+	return p.clock().Now().Sub(endpoint.failedAt) >= retryAfter
+}
+
+func (p *EndpointPool) record(i int, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	endpoint := p.endpoints[i]
+	endpoint.healthy = healthy
+	if !healthy {
+		endpoint.failedAt = p.clock().Now()
+	}
+}
+
+// Middleware returns a Middleware sending every attempt to the endpoint
+// chosen by the pool. Setting MAASClient.Endpoints already installs it, so
+// it must not also be added to MAASClient.Middleware.
+func (p *EndpointPool) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(call *Call) (*Result, error) {
+			ctx := call.Request.Context()
+			affinity, _ := ctx.Value(endpointAffinityKey{}).(*endpointAffinity)
+			i := p.choose(affinity.get())
+			affinity.set(i)
+
+			p.mu.Lock()
+			endpointURL := p.endpoints[i].url
+			p.mu.Unlock()
+			target := endpointURL.ResolveReference(&url.URL{Path: call.Path, RawQuery: call.Request.URL.RawQuery})
+			request := call.Request.WithContext(ctx)
+			request.URL = target
+			request.Host = target.Host
+			call.Request = request
+
+			result, err := next.Do(call)
+			switch {
+			case err == nil || (result != nil && result.StatusCode < 500):
+				p.record(i, true)
+			case ctx.Err() != nil:
+			case util.IsCircuitOpenError(err):
+			default:
+				p.record(i, false)
+				affinity.set(-1)
+			}
+			return result, err
+		})
+	}
+}
+
+type endpointAffinityKey struct{}
+
+// endpointAffinity is the endpoint used by the requests bound to a context.
+type endpointAffinity struct {
+	mu    sync.Mutex
+	index int
+}
+
+func (a *endpointAffinity) get() int {
+	if a == nil {
+		return -1
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.index
+}
+
+func (a *endpointAffinity) set(index int) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.index = index
+}
+
+// WithEndpointAffinity returns a context whose requests are all sent to the
+// endpoint used by the first of them, even if the pool's active endpoint
+// changes because of other requests. They only move to another endpoint
+// when one of them fails on it.
+func WithEndpointAffinity(ctx context.Context) context.Context {
+	return context.WithValue(ctx, endpointAffinityKey{}, &endpointAffinity{index: -1})
+}
+
+func (p *EndpointPool) clock() Clock {
+	if p.Clock == nil {
+		return WallClock
+	}
+	return p.Clock
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRegionServer returns a server answering every request with code and
+// counting them.
+func newRegionServer(code int) (*httptest.Server, *[]string) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		paths = append(paths, request.URL.RequestURI())
+		writer.WriteHeader(code)
+	}))
+	return server, &paths
+}
+
+// deadURL returns the URL of a server that is no longer listening.
+func deadURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func newPoolClient(t *testing.T, baseURLs ...string) (*MAASClient, *EndpointPool) {
+	versionedURLs := make([]string, len(baseURLs))
+	for i, baseURL := range baseURLs {
+		versionedURLs[i] = AddAPIVersionToURL(baseURL, "2.0")
+	}
+	pool, err := NewEndpointPool(versionedURLs...)
+	assert.Nil(t, err)
+	client, err := NewAnonymousClientWithOptions(baseURLs[0], "2.0", Options{Clock: &fakeClock{}, Endpoints: pool})
+	assert.Nil(t, err)
+	return client, pool
+}
+
+func TestEndpointPoolFailsOverOnConnectionError(t *testing.T) {
+	server, paths := newRegionServer(http.StatusOK)
+	defer server.Close()
+	dead := deadURL()
+	client, pool := newPoolClient(t, dead, server.URL)
+
+	_, err := client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, *paths, []string{"/api/2.0/machines/?op=allocate"})
+	assert.Equal(t, pool.Status(), []EndpointStatus{
+		{URL: dead + "/api/2.0/", Healthy: false, Active: false},
+		{URL: server.URL + "/api/2.0/", Healthy: true, Active: true},
+	})
+}
+
+func TestEndpointPoolFailsOverOnServerError(t *testing.T) {
+	failing, failingPaths := newRegionServer(http.StatusServiceUnavailable)
+	defer failing.Close()
+	healthy, healthyPaths := newRegionServer(http.StatusOK)
+	defer healthy.Close()
+	client, _ := newPoolClient(t, failing.URL, healthy.URL)
+
+	_, err := client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Len(t, *failingPaths, 1)
+	assert.Len(t, *healthyPaths, 1)
+}
+
+func TestEndpointPoolDoesNotRepeatUnsafeRequests(t *testing.T) {
+	failing, failingPaths := newRegionServer(http.StatusInternalServerError)
+	defer failing.Close()
+	healthy, healthyPaths := newRegionServer(http.StatusOK)
+	defer healthy.Close()
+	client, _ := newPoolClient(t, failing.URL, healthy.URL)
+
+	_, err := client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+	assert.NotNil(t, err)
+	assert.Len(t, *failingPaths, 1)
+	assert.Len(t, *healthyPaths, 0)
+
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+	assert.Nil(t, err)
+	assert.Len(t, *failingPaths, 1)
+	assert.Len(t, *healthyPaths, 1)
+}
+
+func TestEndpointPoolSticksToActive(t *testing.T) {
+	first, firstPaths := newRegionServer(http.StatusOK)
+	defer first.Close()
+	second, secondPaths := newRegionServer(http.StatusOK)
+	defer second.Close()
+	client, pool := newPoolClient(t, first.URL, second.URL)
+	pool.record(0, false)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(&url.URL{Path: "machines/"}, "", nil)
+		assert.Nil(t, err)
+	}
+	// The first endpoint recovers, but requests stay on the second.
+	assert.Nil(t, pool.CheckHealth(context.Background()))
+	_, err := client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Len(t, *firstPaths, 1)
+	assert.Len(t, *secondPaths, 5)
+	assert.True(t, pool.Status()[0].Healthy)
+	assert.True(t, pool.Status()[1].Active)
+}
+
+func TestEndpointPoolRetriesUnhealthyAfterDelay(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pool, err := NewEndpointPool("http://region-1.invalid/api/2.0/", "http://region-2.invalid/api/2.0/")
+	assert.Nil(t, err)
+	pool.Clock = clock
+	pool.RetryAfter = time.Minute
+	pool.record(0, false)
+	assert.Equal(t, pool.choose(-1), 1)
+	pool.record(1, false)
+	clock.now = clock.now.Add(time.Minute)
+
+	assert.Equal(t, pool.choose(-1), 1)
+	pool.record(1, false)
+	assert.Equal(t, pool.choose(-1), 0)
+}
+
+func TestEndpointAffinity(t *testing.T) {
+	first, firstPaths := newRegionServer(http.StatusOK)
+	defer first.Close()
+	second, secondPaths := newRegionServer(http.StatusOK)
+	defer second.Close()
+	client, pool := newPoolClient(t, first.URL, second.URL)
+	ctx := WithEndpointAffinity(context.Background())
+	_, err := client.GetContext(ctx, &url.URL{Path: "machines/"}, "", nil)
+	assert.Nil(t, err)
+
+	// Another request fails over to the second endpoint.
+	pool.record(0, false)
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+	assert.Nil(t, err)
+	_, err = client.PostContext(ctx, &url.URL{Path: "machines/"}, "allocate", nil, nil)
+
+	assert.Nil(t, err)
+	assert.Len(t, *firstPaths, 2)
+	assert.Len(t, *secondPaths, 1)
+}
+
+func TestEndpointPoolCheckHealth(t *testing.T) {
+	healthy, paths := newRegionServer(http.StatusOK)
+	defer healthy.Close()
+	failing, _ := newRegionServer(http.StatusBadGateway)
+	defer failing.Close()
+	pool, err := NewEndpointPool(AddAPIVersionToURL(failing.URL, "2.0"), AddAPIVersionToURL(healthy.URL, "2.0"))
+	assert.Nil(t, err)
+
+	err = pool.CheckHealth(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, *paths, []string{"/api/2.0/version/"})
+	status := pool.Status()
+	assert.False(t, status[0].Healthy)
+	assert.True(t, status[1].Healthy)
+
+	failing.Close()
+	healthy.Close()
+	assert.NotNil(t, pool.CheckHealth(context.Background()))
+}
+
+func TestEndpointPoolSetAPIVersion(t *testing.T) {
+	pool, err := NewEndpointPool("http://region-1.example.com/MAAS/api/2.0/", "http://region-2.example.com/MAAS/api/2.0/")
+	assert.Nil(t, err)
+	pool.record(1, false)
+
+	err = pool.SetAPIVersion("2.4")
+
+	assert.Nil(t, err)
+	assert.Equal(t, pool.Status(), []EndpointStatus{
+		{URL: "http://region-1.example.com/MAAS/api/2.4/", Healthy: true, Active: true},
+		{URL: "http://region-2.example.com/MAAS/api/2.4/", Healthy: false, Active: false},
+	})
+}
+
+func TestNewEndpointPoolEmpty(t *testing.T) {
+	_, err := NewEndpointPool()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FaultKind is a way for a test server to misbehave.
+type FaultKind int
+
+const (
+	// FaultLatency delays the response by Fault.Latency, and then serves
+	// the request normally.
+	FaultLatency FaultKind = iota
+	// FaultStatus answers with Fault.StatusCode and Fault.Body instead of
+	// serving the request. A zero StatusCode picks one of 500, 502, 503
+	// and 504 at random.
+	FaultStatus
+	// FaultReset closes the connection without answering.
+	FaultReset
+	// FaultTruncate serves the request, but closes the connection after
+	// sending half of the response body.
+	FaultTruncate
+	// FaultLoginPage answers with the HTML login page, as maas 1.9 does
+	// instead of a 404 for unknown API versions.
+	FaultLoginPage
+	// FaultMalformedJSON serves the request with the response body cut in
+	// half, so that it is not valid JSON.
+	FaultMalformedJSON
+)
+
+// String implements fmt.Stringer.
+func (kind FaultKind) String() string {
+	switch kind {
+	case FaultLatency:
+		return "latency"
+	case FaultStatus:
+		return "status"
+	case FaultReset:
+		return "reset"
+	case FaultTruncate:
+		return "truncate"
+	case FaultLoginPage:
+		return "login-page"
+	case FaultMalformedJSON:
+		return "malformed-json"
+	}
+	return fmt.Sprintf("FaultKind(%d)", int(kind))
+}
+
+// Fault is a misbehaviour of a FaultInjector for the requests matching its
+// Method and Path.
+type Fault struct {
+	Kind FaultKind
+	// Method is the method of the requests affected. Empty matches any
+	// method.
+	Method string
+	// Path is a pattern, in the syntax of path.Match, of the paths of the
+	// requests affected, e.g. "/api/2.0/machines/*/". Empty matches any
+	// path.
+	Path string
+	// Rate is the probability that a matching request is affected. Zero
+	// means every matching request is.
+	Rate float64
+	// Count is the number of requests affected, after which the fault is
+	// removed. Zero means no limit.
+	Count int
+
+	Latency    time.Duration
+	StatusCode int
+	Body       string
+}
+
+// loginPage is the start of the page maas serves to unauthenticated
+// browsers.
+const loginPage = `<!DOCTYPE html>
+<html><head><title>Login | MAAS</title></head>
+<body><form method="post" action="/MAAS/accounts/login/"></form></body></html>
+`
+
+// FaultInjector is an http.Handler wrapping another one, such as the
+// handler of a SimpleTestServer, and injecting faults into its responses.
+// The random decisions are drawn from a generator seeded by the test, in
+// the order the requests arrive, so that a test sending its requests one at
+// a time sees the same faults on every run.
+type FaultInjector struct {
+	Handler http.Handler
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	faults   []*Fault
+	injected map[FaultKind]int
+}
+
+// NewFaultInjector returns a FaultInjector serving the requests with
+// handler, whose random decisions are drawn from seed.
+func NewFaultInjector(handler http.Handler, seed int64) *FaultInjector {
+	return &FaultInjector{
+		Handler:  handler,
+		rand:     rand.New(rand.NewSource(seed)),
+		injected: make(map[FaultKind]int),
+	}
+}
+
+// Add adds a fault. The faults are checked in the order they were added,
+// and the first affecting the request is injected.
+func (f *FaultInjector) Add(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = append(f.faults, &fault)
+}
+
+// Clear removes all the faults.
+func (f *FaultInjector) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+}
+
+// Injected returns the number of times a kind of fault was injected.
+func (f *FaultInjector) Injected(kind FaultKind) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.injected[kind]
+}
+
+// pick returns the fault to inject into the response to request, if any,
+// and the status code of FaultStatus faults.
+func (f *FaultInjector) pick(request *http.Request) (*Fault, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, fault := range f.faults {
+		if fault.Method != "" && fault.Method != request.Method {
+			continue
+		}
+		if matched, _ := path.Match(fault.Path, request.URL.Path); fault.Path != "" && !matched {
+			continue
+		}
+		if fault.Rate > 0 && f.rand.Float64() >= fault.Rate {
+			continue
+		}
+		code := fault.StatusCode
+		if fault.Kind == FaultStatus && code == 0 {
+			code = []int{500, 502, 503, 504}[f.rand.Intn(4)]
+		}
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				f.faults = append(f.faults[:i:i], f.faults[i+1:]...)
+			}
+		}
+		f.injected[fault.Kind]++
+		return fault, code
+	}
+	return nil, 0
+}
+
+// ServeHTTP implements http.Handler.
+func (f *FaultInjector) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	fault, code := f.pick(request)
+	if fault == nil {
+		f.Handler.ServeHTTP(writer, request)
+		return
+	}
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-request.Context().Done():
+			return
+		}
+	}
+	switch fault.Kind {
+	case FaultLatency:
+		f.Handler.ServeHTTP(writer, request)
+	case FaultStatus:
+		writer.WriteHeader(code)
+		fmt.Fprint(writer, fault.Body)
+	case FaultReset:
+		resetConnection(writer)
+	case FaultLoginPage:
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(writer, loginPage)
+	case FaultTruncate, FaultMalformedJSON:
+		recorder := httptest.NewRecorder()
+		f.Handler.ServeHTTP(recorder, request)
+		body := recorder.Body.Bytes()
+		for name, values := range recorder.Header() {
+			writer.Header()[name] = values
+		}
+		if fault.Kind == FaultMalformedJSON {
+			if len(body) < 2 {
+				body = []byte("{")
+			} else {
+				body = body[:len(body)/2]
+			}
+			writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			writer.WriteHeader(recorder.Code)
+			writer.Write(body)
+			return
+		}
+		writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		writer.WriteHeader(recorder.Code)
+		writer.Write(body[:len(body)/2])
+		if flusher, ok := writer.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		// Close the connection before the promised length was sent.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// resetConnection closes the connection of writer, with a TCP reset if
+// possible.
+func resetConnection(writer http.ResponseWriter) {
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// InjectFaults wraps the handler of the server in a FaultInjector seeded
+// with seed, and returns it. It must be called before the server is
+// started.
+func (s *SimpleTestServer) InjectFaults(seed int64) *FaultInjector {
+	injector := NewFaultInjector(s.Config.Handler, seed)
+	s.Config.Handler = injector
+	return injector
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFaultyClient(t *testing.T, server *SimpleTestServer) *MAASClient {
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+	client.RetryPolicy = &BackoffPolicy{BaseDelay: time.Millisecond}
+	return client
+}
+
+func TestFaultInjectorResetIsRetried(t *testing.T) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "[]")
+	faults := server.InjectFaults(1)
+	faults.Add(Fault{Kind: FaultReset, Path: "/api/2.0/machines/", Count: 1})
+	server.Start()
+	defer server.Close()
+
+	body, err := newFaultyClient(t, server).Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), "[]")
+	assert.Equal(t, faults.Injected(FaultReset), 1)
+	assert.Equal(t, server.RequestCount(), 1)
+}
+
+func TestFaultInjectorTruncateIsRetried(t *testing.T) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `[{"system_id": "4y3ha3"}]`)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, `[{"system_id": "4y3ha3"}]`)
+	faults := server.InjectFaults(1)
+	faults.Add(Fault{Kind: FaultTruncate, Method: "GET", Count: 1})
+	server.Start()
+	defer server.Close()
+
+	body, err := newFaultyClient(t, server).Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), `[{"system_id": "4y3ha3"}]`)
+	assert.Equal(t, faults.Injected(FaultTruncate), 1)
+	assert.Equal(t, server.RequestCount(), 2)
+}
+
+func TestFaultInjectorMalformedJSON(t *testing.T) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.InjectFaults(1).Add(Fault{Kind: FaultMalformedJSON})
+	server.Start()
+	defer server.Close()
+
+	body, err := newFaultyClient(t, server).Get(&url.URL{Path: "users/"}, "whoami", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), `"captain`)
+}
+
+func TestFaultInjectorLoginPage(t *testing.T) {
+	server := NewSimpleServer()
+	server.InjectFaults(1).Add(Fault{Kind: FaultLoginPage, Path: "/api/2.0/version/"})
+	server.Start()
+	defer server.Close()
+
+	body, err := newFaultyClient(t, server).Get(&url.URL{Path: "version/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(body), "<!DOCTYPE html>"), string(body))
+	assert.Equal(t, server.RequestCount(), 0)
+}
+
+// statusSequence returns the status codes of n requests to a server
+// injecting random 5xx responses at rate, seeded with seed.
+func statusSequence(t *testing.T, seed int64, rate float64, n int) []int {
+	injector := NewFaultInjector(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), seed)
+	injector.Add(Fault{Kind: FaultStatus, Path: "/api/2.0/machines/*/", Rate: rate})
+	server := httptest.NewServer(injector)
+	defer server.Close()
+	var codes []int
+	for i := 0; i < n; i++ {
+		for _, path := range []string{"/api/2.0/machines/4y3ha3/", "/api/2.0/zones/"} {
+			response, err := http.Get(server.URL + path)
+			assert.Nil(t, err)
+			response.Body.Close()
+			codes = append(codes, response.StatusCode)
+		}
+	}
+	return codes
+}
+
+func TestFaultInjectorRateIsSeeded(t *testing.T) {
+	codes := statusSequence(t, 42, 0.5, 20)
+
+	assert.Equal(t, statusSequence(t, 42, 0.5, 20), codes)
+	var failed int
+	for i, code := range codes {
+		if i%2 == 1 {
+			// Other routes are not affected.
+			assert.Equal(t, code, http.StatusOK)
+			continue
+		}
+		if code != http.StatusOK {
+			failed++
+			assert.Contains(t, []int{500, 502, 503, 504}, code)
+		}
+	}
+	assert.True(t, failed > 0 && failed < 20, "%d failed", failed)
+}
+
+func TestFaultInjectorLatency(t *testing.T) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, "[]")
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, "[]")
+	faults := server.InjectFaults(1)
+	faults.Add(Fault{Kind: FaultLatency, Latency: time.Hour, Count: 1})
+	server.Start()
+	defer server.Close()
+	client := newFaultyClient(t, server)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.GetContext(ctx, &url.URL{Path: "zones/"}, "", nil)
+	assert.NotNil(t, err)
+
+	faults.Add(Fault{Kind: FaultLatency, Latency: time.Millisecond})
+	body, err := client.Get(&url.URL{Path: "zones/"}, "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), "[]")
+	assert.Equal(t, faults.Injected(FaultLatency), 2)
+}
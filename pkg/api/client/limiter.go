@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Budget limits the requests of one class sent by a Limiter.
+type Budget struct {
+	// Rate is the sustained number of requests per second. Zero means no
+	// rate limit.
+	Rate float64
+	// Burst is the number of requests that can be sent at once after a
+	// quiet period. Zero means 1.
+	Burst int
+	// MaxInFlight is the number of requests that can be in progress at the
+	// same time. Zero means no limit.
+	MaxInFlight int
+}
+
+// LimiterStats is a snapshot of the requests going through a Limiter.
+type LimiterStats struct {
+	// ReadsQueued and WritesQueued are the requests waiting for their
+	// budget.
+	ReadsQueued  int
+	WritesQueued int
+	// ReadsInFlight and WritesInFlight are the requests in progress.
+	ReadsInFlight  int
+	WritesInFlight int
+}
+
+// Limiter caps the rate and concurrency of the requests sent to the maas
+// API, with separate budgets for reads (GET and HEAD requests) and writes
+// (every other method, which includes the maas operations that change
+// state). Every attempt counts, including retries. A Limiter can be shared
+// by several clients to protect a region controller from all of them.
+//
+// Requests wait for their budget in a queue until their context is done,
+// in which case they fail with the context's error without being sent.
+//
+// The zero value is ready to use, and limits nothing.
+type Limiter struct {
+	// Clock times the waits for the rate limits. Nil means WallClock. It
+	// must be set before the Limiter is used.
+	Clock Clock
+
+	mu     sync.Mutex
+	reads  *bucket
+	writes *bucket
+}
+
+// bucket is the state of the token bucket and semaphore of a budget.
+type bucket struct {
+	budget   Budget
+	started  bool
+	tokens   float64
+	last     time.Time
+	slots    chan struct{}
+	queued   int
+	inFlight int
+}
+
+// NewLimiter returns a Limiter with the given budgets.
+func NewLimiter(reads, writes Budget) *Limiter {
+	return &Limiter{reads: newBucket(reads), writes: newBucket(writes)}
+}
+
+func newBucket(budget Budget) *bucket {
+	b := &bucket{budget: budget}
+	if budget.MaxInFlight > 0 {
+		b.slots = make(chan struct{}, budget.MaxInFlight)
+	}
+	return b
+}
+
+// Stats returns the current queue depths and requests in progress.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	return LimiterStats{
+		ReadsQueued:    l.reads.queued,
+		WritesQueued:   l.writes.queued,
+		ReadsInFlight:  l.reads.inFlight,
+		WritesInFlight: l.writes.inFlight,
+	}
+}
+
+// Middleware returns a Middleware holding every attempt until the budget of
+// its class allows it. Setting MAASClient.Limiter already installs it, so it
+// must not also be added to MAASClient.Middleware: every attempt would be
+// counted against the budget twice.
+func (l *Limiter) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(call *Call) (*Result, error) {
+			b := l.bucket(call.Request.Method)
+			if err := l.acquire(call.Request.Context(), b); err != nil {
+				return nil, err
+			}
+			defer l.release(b)
+			return next.Do(call)
+		})
+	}
+}
+
+// bucket returns the bucket of the budget of the requests using method.
+func (l *Limiter) bucket(method string) *bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.init()
+	if method == "GET" || method == "HEAD" {
+		return l.reads
+	}
+	return l.writes
+}
+
+// init gives a zero Limiter unlimited budgets. l.mu must be held.
+func (l *Limiter) init() {
+	if l.reads == nil {
+		l.reads = newBucket(Budget{})
+	}
+	if l.writes == nil {
+		l.writes = newBucket(Budget{})
+	}
+}
+
+// acquire waits for a slot and a token of b, or for ctx to be done.
+func (l *Limiter) acquire(ctx context.Context, b *bucket) error {
+	l.mu.Lock()
+	b.queued++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		b.queued--
+		l.mu.Unlock()
+	}()
+	if b.slots != nil {
+		select {
+		case b.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if delay := l.reserve(b); delay > 0 {
+		select {
+		case <-l.clock().After(delay):
+		case <-ctx.Done():
+			l.mu.Lock()
+			b.tokens++
+			l.mu.Unlock()
+			if b.slots != nil {
+				<-b.slots
+			}
+			return ctx.Err()
+		}
+	}
+	l.mu.Lock()
+	b.inFlight++
+	l.mu.Unlock()
+	return nil
+}
+
+// reserve takes a token from b, and returns how long to wait for the
+// token to be available. Tokens are taken in order, so that requests are
+// spaced by the rate even though they wait concurrently.
+func (l *Limiter) reserve(b *bucket) time.Duration {
+	if b.budget.Rate <= 0 {
+		return 0
+	}
+	burst := float64(b.budget.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock().Now()
+	if !b.started {
+		b.started = true
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*b.budget.Rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.budget.Rate * float64(time.Second))
+}
+
+func (l *Limiter) release(b *bucket) {
+	l.mu.Lock()
+	b.inFlight--
+	l.mu.Unlock()
+	if b.slots != nil {
+		<-b.slots
+	}
+}
+
+func (l *Limiter) clock() Clock {
+	if l.Clock == nil {
+		return WallClock
+	}
+	return l.Clock
+}
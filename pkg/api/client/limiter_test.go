@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterRate(t *testing.T) {
+	server, nbRequests := newStatusServer()
+	defer server.Close()
+	clock := &fakeClock{}
+	limiter := NewLimiter(Budget{Rate: 10, Burst: 2}, Budget{})
+	limiter.Clock = clock
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Limiter: limiter})
+	assert.Nil(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+		assert.Nil(t, err)
+	}
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, *nbRequests, 5)
+	// The writes budget is unlimited, so only the last two reads waited.
+	assert.Equal(t, clock.waits, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond})
+}
+
+func TestLimiterRateRefills(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := NewLimiter(Budget{}, Budget{Rate: 2})
+	limiter.Clock = clock
+
+	assert.Equal(t, limiter.reserve(limiter.writes), time.Duration(0))
+	assert.Equal(t, limiter.reserve(limiter.writes), 500*time.Millisecond)
+	clock.now = clock.now.Add(2 * time.Second)
+	assert.Equal(t, limiter.reserve(limiter.writes), time.Duration(0))
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		started <- struct{}{}
+		<-unblock
+	}))
+	defer server.Close()
+	limiter := NewLimiter(Budget{}, Budget{MaxInFlight: 1})
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Limiter: limiter})
+	assert.Nil(t, err)
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := client.Post(&url.URL{Path: "machines/"}, "power_on", nil, nil)
+			done <- err
+		}()
+	}
+	<-started
+	for limiter.Stats().WritesQueued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, limiter.Stats(), LimiterStats{WritesQueued: 1, WritesInFlight: 1})
+	unblock <- struct{}{}
+	<-started
+	unblock <- struct{}{}
+
+	assert.Nil(t, <-done)
+	assert.Nil(t, <-done)
+	assert.Equal(t, limiter.Stats(), LimiterStats{})
+}
+
+func TestLimiterQueueCancelled(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+	limiter := NewLimiter(Budget{MaxInFlight: 1}, Budget{})
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Limiter: limiter})
+	assert.Nil(t, err)
+	go client.Get(&url.URL{Path: "machines/"}, "", nil)
+	for limiter.Stats().ReadsInFlight != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = client.GetContext(ctx, &url.URL{Path: "machines/"}, "", nil)
+
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, limiter.Stats(), LimiterStats{ReadsInFlight: 1})
+}
+
+// stoppedClock is a clock whose time does not pass.
+type stoppedClock struct {
+	fakeClock
+}
+
+func (c *stoppedClock) After(d time.Duration) <-chan time.Time {
+	return nil
+}
+
+func TestLimiterRateCancelled(t *testing.T) {
+	limiter := NewLimiter(Budget{Rate: 1}, Budget{})
+	limiter.Clock = &stoppedClock{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Nil(t, limiter.acquire(ctx, limiter.reads))
+	limiter.release(limiter.reads)
+	err := limiter.acquire(ctx, limiter.reads)
+
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, limiter.Stats(), LimiterStats{})
+	// The token taken by the cancelled request is given back.
+	assert.Equal(t, limiter.reads.tokens, float64(0))
+}
+
+func TestLimiterZeroValue(t *testing.T) {
+	server, nbRequests := newStatusServer()
+	defer server.Close()
+	limiter := &Limiter{}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Limiter: limiter})
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, *nbRequests, 1)
+	assert.Equal(t, limiter.Stats(), LimiterStats{})
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+const (
+	// LoginPath is the path of the maas login form, relative to the maas
+	// root URL.
+	LoginPath = "accounts/login/"
+
+	csrfCookieName    = "csrftoken"
+	sessionCookieName = "sessionid"
+	csrfHeaderName    = "X-CSRFToken"
+)
+
+// Credentials are the username and password of a maas account.
+type Credentials struct {
+	Username string
+	Password string
+	// TokenName names the API token used by the client. When the account
+	// already has a token with this name it is reused, otherwise a new one
+	// is created with this name. When empty, a new unnamed token is created
+	// on every login.
+	TokenName string
+}
+
+// authorisationToken is the result of the create_authorisation_token
+// operation.
+type authorisationToken struct {
+	Name        string `json:"name"`
+	ConsumerKey string `json:"consumer_key"`
+	TokenKey    string `json:"token_key"`
+	TokenSecret string `json:"token_secret"`
+}
+
+// listedToken is an element of the result of the list_authorisation_tokens
+// operation.
+type listedToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// Login logs into the maas at BaseURL with a username and password, and
+// returns an API key for the account, in the "<consumer key>:<token
+// key>:<token secret>" form accepted by NewAuthenticatedMAASClient. The key
+// is obtained with the account operations of the given API version.
+//
+// If the username or password is wrong, a PermissionError is returned.
+func Login(ctx context.Context, BaseURL, apiVersion string, creds Credentials, opts Options) (string, error) {
+	httpClient, err := opts.Client()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	// Keep the session cookies to this login, and stop at the redirect
+	// following a successful login.
+	sessionClient := *httpClient
+	sessionClient.Jar = jar
+	sessionClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	loginURL, err := url.Parse(util.EnsureTrailingSlash(BaseURL) + LoginPath)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := login(ctx, &sessionClient, loginURL, creds); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	apiURL, err := url.Parse(AddAPIVersionToURL(BaseURL, apiVersion))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	session := MAASClient{
+		APIURL:      apiURL,
+		Signer:      sessionSigner{jar: jar},
+		HTTPClient:  &sessionClient,
+		RetryPolicy: opts.RetryPolicy,
+		Clock:       opts.Clock,
+		Middleware:  opts.Middleware,
+		Metrics:     opts.Metrics,
+		Tracer:      opts.Tracer,
+		Limiter:     opts.Limiter,
+		Breaker:     opts.Breaker,
+	}
+	if creds.TokenName != "" {
+		key, err := findToken(ctx, session, creds.TokenName)
+		if err != nil || key != "" {
+			return key, errors.Trace(err)
+		}
+	}
+	return createToken(ctx, session, creds.TokenName)
+}
+
+// login posts the credentials to the login form, leaving the session cookie
+// in the client's jar.
+func login(ctx context.Context, sessionClient *http.Client, loginURL *url.URL, creds Credentials) error {
+	// Getting the form sets the CSRF cookie expected with the credentials.
+	if err := sendLoginRequest(ctx, sessionClient, "GET", loginURL, nil); err != nil {
+		return errors.Annotate(err, "cannot get login form")
+	}
+	form := url.Values{
+		"username":            {creds.Username},
+		"password":            {creds.Password},
+		"csrfmiddlewaretoken": {cookieValue(sessionClient.Jar, loginURL, csrfCookieName)},
+	}
+	if err := sendLoginRequest(ctx, sessionClient, "POST", loginURL, form); err != nil {
+		return errors.Annotate(err, "cannot log in")
+	}
+	if cookieValue(sessionClient.Jar, loginURL, sessionCookieName) == "" {
+		// maas renders the login form again when the credentials are wrong.
+		return util.NewPermissionError("login failed for user " + creds.Username)
+	}
+	return nil
+}
+
+func sendLoginRequest(ctx context.Context, sessionClient *http.Client, method string, loginURL *url.URL, form url.Values) error {
+	request, err := http.NewRequest(method, loginURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Referer", loginURL.String())
+		request.Header.Set(csrfHeaderName, form.Get("csrfmiddlewaretoken"))
+	}
+	response, err := sessionClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	content, err := readAndClose(response.Body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		return util.NewPermissionError(string(content))
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		err := errors.Errorf("ServerError: %v (%s)", response.Status, content)
+		return errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(content)})
+	}
+	return nil
+}
+
+// findToken returns the API key of the account's token with the given name,
+// or an empty string if there is none.
+func findToken(ctx context.Context, session MAASClient, name string) (string, error) {
+	source, err := session.GetContext(ctx, &url.URL{Path: "account/"}, "list_authorisation_tokens", nil)
+	if err != nil {
+		return "", errors.Annotate(err, "cannot list authorisation tokens")
+	}
+	var tokens []listedToken
+	if err := json.Unmarshal(source, &tokens); err != nil {
+		return "", errors.Annotate(err, "cannot parse authorisation tokens")
+	}
+	for _, token := range tokens {
+		if token.Name == name {
+			return token.Token, nil
+		}
+	}
+	return "", nil
+}
+
+// createToken creates a new API token for the account and returns its key.
+func createToken(ctx context.Context, session MAASClient, name string) (string, error) {
+	params := url.Values{}
+	if name != "" {
+		params.Set("name", name)
+	}
+	source, err := session.PostContext(ctx, &url.URL{Path: "account/"}, "create_authorisation_token", params, nil)
+	if err != nil {
+		return "", errors.Annotate(err, "cannot create authorisation token")
+	}
+	var token authorisationToken
+	if err := json.Unmarshal(source, &token); err != nil {
+		return "", errors.Annotate(err, "cannot parse authorisation token")
+	}
+	return strings.Join([]string{token.ConsumerKey, token.TokenKey, token.TokenSecret}, ":"), nil
+}
+
+func cookieValue(jar http.CookieJar, u *url.URL, name string) string {
+	for _, cookie := range jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// sessionSigner authenticates API requests with the session cookie of a
+// login instead of OAuth. The cookie itself is sent by the client's jar;
+// the signer adds the CSRF token that maas requires alongside it.
+type sessionSigner struct {
+	jar http.CookieJar
+}
+
+func (signer sessionSigner) OAuthSign(request *http.Request) error {
+	request.Header.Set(csrfHeaderName, cookieValue(signer.jar, request.URL, csrfCookieName))
+	return nil
+}
+
+// *sessionSigner implements the OAuthSigner interface.
+var _ OAuthSigner = sessionSigner{}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLoginServer() (*httptest.Server, *LoginHandler) {
+	handler := NewLoginHandler("admin", "secret", "consumer:token:secret")
+	return httptest.NewServer(handler), handler
+}
+
+func TestLogin(t *testing.T) {
+	server, handler := newLoginServer()
+	defer server.Close()
+
+	key, err := Login(context.Background(), server.URL+"/MAAS/", "2.0", Credentials{Username: "admin", Password: "secret"}, Options{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, key, "consumer:token:secret")
+	assert.Equal(t, handler.Created, 1)
+}
+
+func TestLoginBadPassword(t *testing.T) {
+	server, handler := newLoginServer()
+	defer server.Close()
+
+	_, err := Login(context.Background(), server.URL+"/MAAS/", "2.0", Credentials{Username: "admin", Password: "wrong"}, Options{})
+
+	assert.True(t, util.IsPermissionError(err))
+	assert.Equal(t, handler.Created, 0)
+}
+
+func TestLoginReusesNamedToken(t *testing.T) {
+	server, handler := newLoginServer()
+	defer server.Close()
+	handler.Tokens["ci"] = "other:token:key"
+	creds := Credentials{Username: "admin", Password: "secret", TokenName: "ci"}
+
+	key, err := Login(context.Background(), server.URL+"/MAAS/", "2.0", creds, Options{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, key, "other:token:key")
+	assert.Equal(t, handler.Created, 0)
+}
+
+func TestLoginCreatesNamedToken(t *testing.T) {
+	server, handler := newLoginServer()
+	defer server.Close()
+	creds := Credentials{Username: "admin", Password: "secret", TokenName: "ci"}
+
+	key, err := Login(context.Background(), server.URL+"/MAAS/", "2.0", creds, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, key, "consumer:token:secret")
+	assert.Equal(t, handler.Tokens["ci"], "consumer:token:secret")
+
+	// A second login reuses the token.
+	_, err = Login(context.Background(), server.URL+"/MAAS/", "2.0", creds, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, handler.Created, 1)
+}
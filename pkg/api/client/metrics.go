@@ -0,0 +1,240 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets used when Metrics.Buckets is empty.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects counts and latencies of the requests sent to the maas
+// API. Set it on Options or MAASClient to record a client's traffic; one
+// Metrics can be shared by several clients. It serves the collected
+// metrics in the Prometheus text exposition format.
+//
+// Series are labelled by method, op and the resource path with its
+// identifiers replaced, e.g. "machines/{system_id}/", so that their number
+// stays bounded.
+type Metrics struct {
+	// Buckets are the upper bounds, in seconds, of the latency histogram
+	// buckets. Empty means DefaultLatencyBuckets. They must not be changed
+	// once requests have been recorded.
+	Buckets []float64
+	// Clock times the requests. Nil means WallClock.
+	Clock Clock
+
+	mu        sync.Mutex
+	endpoints map[endpoint]*endpointMetrics
+}
+
+// NewMetrics returns an empty Metrics with the default buckets.
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+type endpoint struct {
+	method string
+	path   string
+	op     string
+}
+
+type endpointMetrics struct {
+	requests uint64
+	retries  uint64
+	// errors counts failed attempts by status code, or "transport" for
+	// attempts that got no error response, including those failing while
+	// the body of a 2xx response is read.
+	errors  map[string]uint64
+	buckets []uint64
+	sum     float64
+}
+
+// Middleware returns a Middleware recording every attempt passing through
+// it. Setting MAASClient.Metrics already installs it, so it must not also be
+// added to MAASClient.Middleware.
+func (m *Metrics) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(call *Call) (*Result, error) {
+			clock := m.Clock
+			if clock == nil {
+				clock = WallClock
+			}
+			start := clock.Now()
+			result, err := next.Do(call)
+			m.record(call, result, err, clock.Now().Sub(start))
+			return result, err
+		})
+	}
+}
+
+func (m *Metrics) record(call *Call, result *Result, err error, latency time.Duration) {
+	key := endpoint{method: call.Request.Method, path: NormalizePath(call.Path), op: call.Op}
+	buckets := m.buckets()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.endpoints == nil {
+		m.endpoints = make(map[endpoint]*endpointMetrics)
+	}
+	metrics, ok := m.endpoints[key]
+	if !ok {
+		metrics = &endpointMetrics{
+			errors:  make(map[string]uint64),
+			buckets: make([]uint64, len(buckets)),
+		}
+		m.endpoints[key] = metrics
+	}
+	metrics.requests++
+	if call.Attempt > 1 {
+		metrics.retries++
+	}
+	if err != nil {
+		if result != nil && (result.StatusCode < 200 || result.StatusCode >= 300) {
+			metrics.errors[strconv.Itoa(result.StatusCode)]++
+		} else {
+			metrics.errors["transport"]++
+		}
+	}
+	seconds := latency.Seconds()
+	metrics.sum += seconds
+	for i, bound := range buckets {
+		if seconds <= bound {
+			metrics.buckets[i]++
+		}
+	}
+}
+
+func (m *Metrics) buckets() []float64 {
+	if len(m.Buckets) == 0 {
+		return DefaultLatencyBuckets
+	}
+	return m.Buckets
+}
+
+// WriteText writes the collected metrics to w in the Prometheus text
+// exposition format.
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]endpoint, 0, len(m.endpoints))
+	for key := range m.endpoints {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.op < b.op
+	})
+	buckets := m.buckets()
+
+	out := bufio.NewWriter(w)
+	writeHeader(out, "maas_client_requests_total", "counter", "Attempts at sending requests to the maas API, including retries.")
+	for _, key := range keys {
+		fmt.Fprintf(out, "maas_client_requests_total%s %d\n", key.labels(), m.endpoints[key].requests)
+	}
+	writeHeader(out, "maas_client_retries_total", "counter", "Attempts that retried a failed request.")
+	for _, key := range keys {
+		fmt.Fprintf(out, "maas_client_retries_total%s %d\n", key.labels(), m.endpoints[key].retries)
+	}
+	writeHeader(out, "maas_client_errors_total", "counter", "Failed attempts, by response status code or \"transport\" when there was no error response.")
+	for _, key := range keys {
+		errors := m.endpoints[key].errors
+		codes := make([]string, 0, len(errors))
+		for code := range errors {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			fmt.Fprintf(out, "maas_client_errors_total%s %d\n", key.labels("code", code), errors[code])
+		}
+	}
+	writeHeader(out, "maas_client_request_duration_seconds", "histogram", "Latency of the attempts at sending requests to the maas API.")
+	for _, key := range keys {
+		metrics := m.endpoints[key]
+		for i, bound := range buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(out, "maas_client_request_duration_seconds_bucket%s %d\n", key.labels("le", le), metrics.buckets[i])
+		}
+		fmt.Fprintf(out, "maas_client_request_duration_seconds_bucket%s %d\n", key.labels("le", "+Inf"), metrics.requests)
+		fmt.Fprintf(out, "maas_client_request_duration_seconds_sum%s %s\n", key.labels(), strconv.FormatFloat(metrics.sum, 'g', -1, 64))
+		fmt.Fprintf(out, "maas_client_request_duration_seconds_count%s %d\n", key.labels(), metrics.requests)
+	}
+	return out.Flush()
+}
+
+// ServeHTTP serves the collected metrics in the Prometheus text exposition
+// format, so that Metrics can be registered as a scrape endpoint.
+func (m *Metrics) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteText(writer)
+}
+
+func writeHeader(out io.Writer, name, kind, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labels formats the labels of the endpoint followed by the extra name and
+// value pairs.
+func (e endpoint) labels(extra ...string) string {
+	pairs := append([]string{"method", e.method, "op", e.op, "path", e.path}, extra...)
+	var buf strings.Builder
+	buf.WriteString("{")
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, "%s=\"%s\"", pairs[i], labelEscaper.Replace(pairs[i+1]))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// nodeCollections are the collections whose members are identified by a
+// system ID.
+var nodeCollections = map[string]bool{
+	"machines":           true,
+	"nodes":              true,
+	"devices":            true,
+	"controllers":        true,
+	"rack-controllers":   true,
+	"region-controllers": true,
+}
+
+var versionedPrefix = regexp.MustCompile(`^.*?/api/\d+\.\d+/`)
+
+// NormalizePath returns the path of a request relative to the versioned API
+// URL with the resource identifiers replaced, so that requests for the same
+// kind of resource share it. maas paths alternate between collections and
+// identifiers: "nodes/4y3ha3/interfaces/12/" becomes
+// "nodes/{system_id}/interfaces/{id}/".
+func NormalizePath(path string) string {
+	path = versionedPrefix.ReplaceAllString(path, "")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		return "/"
+	}
+	for i := 1; i < len(segments); i += 2 {
+		if nodeCollections[segments[i-1]] {
+			segments[i] = "{system_id}"
+		} else {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/") + "/"
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// steppingClock moves forward by step each time it is read.
+type steppingClock struct {
+	fakeClock
+	step time.Duration
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+func TestNormalizePath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"":                               "/",
+		"version/":                       "version/",
+		"machines/4y3ha3/":               "machines/{system_id}/",
+		"/MAAS/api/2.0/machines/4y3ha3/": "machines/{system_id}/",
+		"nodes/4y3ha3/interfaces/12/":    "nodes/{system_id}/interfaces/{id}/",
+		"nodes/4y3ha3/blockdevices/3/partition/4": "nodes/{system_id}/blockdevices/{id}/partition/{id}/",
+		"fabrics/1/vlans/2/":                      "fabrics/{id}/vlans/{id}/",
+		"files/":                                  "files/",
+	} {
+		assert.Equal(t, NormalizePath(path), expected, path)
+	}
+}
+
+func TestMetricsRecordsAttempts(t *testing.T) {
+	server, _ := newStatusServer(http.StatusBadGateway)
+	defer server.Close()
+	metrics := &Metrics{Buckets: []float64{0.5, 1}, Clock: &steppingClock{step: time.Second / 2}}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: &fakeClock{}, Metrics: metrics})
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "machines/4y3ha3/"}, "power_parameters", nil)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	err = metrics.WriteText(&buf)
+
+	assert.Nil(t, err)
+	labels := `method="GET",op="power_parameters",path="machines/{system_id}/"`
+	for _, line := range []string{
+		"# TYPE maas_client_requests_total counter",
+		"maas_client_requests_total{" + labels + "} 2",
+		"maas_client_retries_total{" + labels + "} 1",
+		"maas_client_errors_total{" + labels + `,code="502"} 1`,
+		"# TYPE maas_client_request_duration_seconds histogram",
+		"maas_client_request_duration_seconds_bucket{" + labels + `,le="0.5"} 2`,
+		"maas_client_request_duration_seconds_bucket{" + labels + `,le="+Inf"} 2`,
+		"maas_client_request_duration_seconds_sum{" + labels + "} 1",
+		"maas_client_request_duration_seconds_count{" + labels + "} 2",
+	} {
+		assert.Contains(t, buf.String(), line+"\n")
+	}
+}
+
+func TestMetricsTransportErrors(t *testing.T) {
+	metrics := NewMetrics()
+	client, err := NewAnonymousClientWithOptions("http://127.0.0.1:1/", "2.0", Options{
+		RetryPolicy: &BackoffPolicy{MaxAttempts: 1},
+		Metrics:     metrics,
+	})
+	assert.Nil(t, err)
+
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+	assert.NotNil(t, err)
+	var buf bytes.Buffer
+	metrics.WriteText(&buf)
+
+	assert.Contains(t, buf.String(), `maas_client_errors_total{method="POST",op="allocate",path="machines/",code="transport"} 1`)
+}
+
+func TestMetricsBodyErrors(t *testing.T) {
+	metrics := NewMetrics()
+	call := &Call{Request: &http.Request{Method: "GET"}, Path: "files/", Op: "get"}
+	failed := DoerFunc(func(*Call) (*Result, error) {
+		// The download failed after its 200 response started.
+		return &Result{StatusCode: http.StatusOK}, errors.New("unexpected EOF")
+	})
+
+	_, err := metrics.Middleware()(failed).Do(call)
+	assert.NotNil(t, err)
+	var buf bytes.Buffer
+	metrics.WriteText(&buf)
+
+	assert.Contains(t, buf.String(), `maas_client_errors_total{method="GET",op="get",path="files/",code="transport"} 1`)
+	assert.NotContains(t, buf.String(), `code="200"`)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	metrics := NewMetrics()
+	server, _ := newStatusServer()
+	defer server.Close()
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Metrics: metrics})
+	assert.Nil(t, err)
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	assert.Nil(t, err)
+	recorder := httptest.NewRecorder()
+
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.Contains(t, recorder.Body.String(), `maas_client_requests_total{method="GET",op="",path="version/"} 1`)
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	labels := endpoint{method: "GET", path: `a"b\c`, op: "x\ny"}.labels()
+
+	assert.Equal(t, labels, `{method="GET",op="x\ny",path="a\"b\\c"}`)
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Call is a single attempt at sending a request to the maas API, as seen
+// by Middleware.
+type Call struct {
+	// Request is the HTTP request. Middleware may add headers to it; it is
+	// signed after the whole chain has seen it.
+	Request *http.Request
+	// Path is the path of the request relative to the versioned API URL,
+	// e.g. "machines/".
+	Path string
+	// Op is the maas operation of the request, if any.
+	Op string
+	// RequestID identifies the logical request, shared by its attempts.
+	RequestID int64
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int
+}
+
+// Result is the decoded outcome of a Call.
+type Result struct {
+	StatusCode int
+	Header     http.Header
+	// Body is the response body. It is nil for downloads, which stream the
+	// body to their writer.
+	Body []byte
+}
+
+// Doer sends a Call. Non-2xx responses are returned with their Result and
+// a ServerError; failures to get a response return a nil Result.
+type Doer interface {
+	Do(call *Call) (*Result, error)
+}
+
+// DoerFunc adapts a function to the Doer interface.
+type DoerFunc func(call *Call) (*Result, error)
+
+// Do implements Doer.
+func (f DoerFunc) Do(call *Call) (*Result, error) {
+	return f(call)
+}
+
+// Middleware wraps a Doer with cross-cutting behaviour, like logging or
+// header injection. It sees every attempt, including retries.
+type Middleware func(next Doer) Doer
+
+// Current request number. Informational only for logging.
+var requestNumber int64
+
+// NextRequestID returns a new request ID, unique in the process.
+func NextRequestID() int64 {
+	return atomic.AddInt64(&requestNumber, 1)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request ID given to the
+// Calls of requests bound to it.
+func WithRequestID(ctx context.Context, requestID int64) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) (int64, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(int64)
+	return requestID, ok
+}
+
+// newCall returns the Call for an attempt at sending request.
+func (client MAASClient) newCall(request *http.Request, requestID int64, attempt int) *Call {
+	path := request.URL.Path
+	if client.APIURL != nil {
+		path = strings.TrimPrefix(path, client.APIURL.Path)
+	}
+	return &Call{
+		Request:   request,
+		Path:      path,
+		Op:        request.URL.Query().Get("op"),
+		RequestID: requestID,
+		Attempt:   attempt,
+	}
+}
+
+// requestID returns the request ID carried by ctx, or a new one.
+func requestID(ctx context.Context) int64 {
+	if requestID, ok := RequestIDFromContext(ctx); ok {
+		return requestID
+	}
+	return NextRequestID()
+}
+
+// chain wraps inner with the client's middleware, the first being the
+// outermost. Metrics are recorded innermost, so that they measure what is
+// sent to the server, after the wait for the Limiter. The Breaker fails
+// fast before the attempt queues for the Limiter. Outermost, the Endpoints
+// choose where the attempt goes, before its span is started.
+func (client MAASClient) chain(inner Doer) Doer {
+	doer := inner
+	if client.Metrics != nil {
+		doer = client.Metrics.Middleware()(doer)
+	}
+	if client.Limiter != nil {
+		doer = client.Limiter.Middleware()(doer)
+	}
+	if client.Breaker != nil {
+		doer = client.Breaker.Middleware()(doer)
+	}
+	for i := len(client.Middleware) - 1; i >= 0; i-- {
+		doer = client.Middleware[i](doer)
+	}
+	if client.Tracer != nil {
+		doer = traceAttempts(client.Tracer, doer)
+	}
+	if client.Endpoints != nil {
+		doer = client.Endpoints.Middleware()(doer)
+	}
+	return doer
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMiddleware records the calls it sees and their outcome.
+type recordingMiddleware struct {
+	name    string
+	trace   *[]string
+	calls   []Call
+	results []*Result
+	errs    []error
+}
+
+func (m *recordingMiddleware) wrap(next Doer) Doer {
+	return DoerFunc(func(call *Call) (*Result, error) {
+		*m.trace = append(*m.trace, m.name)
+		m.calls = append(m.calls, *call)
+		result, err := next.Do(call)
+		m.results = append(m.results, result)
+		m.errs = append(m.errs, err)
+		return result, err
+	})
+}
+
+func TestMiddlewareSeesCallsAndOutcomes(t *testing.T) {
+	server, _ := newStatusServer(http.StatusBadGateway)
+	defer server.Close()
+	var trace []string
+	outer := &recordingMiddleware{name: "outer", trace: &trace}
+	inner := &recordingMiddleware{name: "inner", trace: &trace}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{
+		Clock:      &fakeClock{},
+		Middleware: []Middleware{outer.wrap, inner.wrap},
+	})
+	assert.Nil(t, err)
+	ctx := WithRequestID(context.Background(), 42)
+
+	_, err = client.GetContext(ctx, &url.URL{Path: "machines/"}, "list_allocated", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, trace, []string{"outer", "inner", "outer", "inner"})
+	assert.Len(t, outer.calls, 2)
+	for i, call := range outer.calls {
+		assert.Equal(t, call.Path, "machines/")
+		assert.Equal(t, call.Op, "list_allocated")
+		assert.EqualValues(t, call.RequestID, 42)
+		assert.Equal(t, call.Attempt, i+1)
+	}
+	assert.Equal(t, outer.results[0].StatusCode, http.StatusBadGateway)
+	_, ok := GetServerError(outer.errs[0])
+	assert.True(t, ok)
+	assert.Equal(t, outer.results[1].StatusCode, http.StatusOK)
+	assert.Nil(t, outer.errs[1])
+}
+
+func TestMiddlewareInjectsHeaders(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received = request.Header.Get("X-Team")
+	}))
+	defer server.Close()
+	addHeader := func(next Doer) Doer {
+		return DoerFunc(func(call *Call) (*Result, error) {
+			call.Request.Header.Set("X-Team", "infra")
+			return next.Do(call)
+		})
+	}
+	client, err := NewAuthenticatedMAASClientWithOptions(server.URL, "a:b:c", Options{Middleware: []Middleware{addHeader}})
+	assert.Nil(t, err)
+
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, received, "infra")
+}
+
+func TestMiddlewareCanShortCircuit(t *testing.T) {
+	canned := func(next Doer) Doer {
+		return DoerFunc(func(call *Call) (*Result, error) {
+			return &Result{StatusCode: http.StatusOK, Body: []byte("cached")}, nil
+		})
+	}
+	client, err := NewAnonymousClientWithOptions("http://maas.invalid/MAAS/", "2.0", Options{Middleware: []Middleware{canned}})
+	assert.Nil(t, err)
+
+	result, err := client.Get(&url.URL{Path: "version/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, string(result), "cached")
+}
+
+func TestMiddlewareSeesDownloads(t *testing.T) {
+	server := newSingleServingServer("/api/2.0/files/?op=get_by_key", "content", http.StatusOK)
+	defer server.Close()
+	var trace []string
+	recorder := &recordingMiddleware{name: "recorder", trace: &trace}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Middleware: []Middleware{recorder.wrap}})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+
+	_, err = client.Download(&url.URL{Path: "files/"}, "get_by_key", nil, &buf, DownloadOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "content")
+	assert.Len(t, recorder.calls, 1)
+	assert.Equal(t, recorder.calls[0].Op, "get_by_key")
+	assert.Equal(t, recorder.results[0].StatusCode, http.StatusOK)
+	assert.Nil(t, recorder.results[0].Body)
+}
+
+func TestNewCallRequestID(t *testing.T) {
+	first := requestID(context.Background())
+	second := requestID(context.Background())
+	assert.NotEqual(t, first, second)
+	assert.EqualValues(t, requestID(WithRequestID(context.Background(), 7)), 7)
+}
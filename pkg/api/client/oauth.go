@@ -4,13 +4,35 @@
 package client
 
 import (
+	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/juju/errors"
+)
+
+// SignatureMethod is the OAuth signature method used to sign requests.
+type SignatureMethod string
+
+const (
+	// SignaturePlaintext sends the token secret with every request. It is
+	// what maas' own clients use, and is only safe over HTTPS.
+	SignaturePlaintext SignatureMethod = "PLAINTEXT"
+
+	// SignatureHMACSHA1 signs every request with HMAC-SHA1 so that the
+	// token secret is never sent.
+	SignatureHMACSHA1 SignatureMethod = "HMAC-SHA1"
 )
 
 // Not a true uuidgen, but at least creates same length random
@@ -75,6 +97,179 @@ func (signer plainTextOAuthSigner) OAuthSign(request *http.Request) error {
 		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, url.QueryEscape(value)))
 	}
 	strHeader := "OAuth " + strings.Join(authHeader, ", ")
-	request.Header.Add("Authorization", strHeader)
+	// Set rather than add, as retried requests are signed again.
+	request.Header.Set("Authorization", strHeader)
 	return nil
 }
+
+// NewOAuthSigner returns the OAuthSigner for the given signature method. An
+// empty method means SignaturePlaintext.
+func NewOAuthSigner(method SignatureMethod, token *OAuthToken, realm string) (OAuthSigner, error) {
+	switch method {
+	case "", SignaturePlaintext:
+		return NewPlainTestOAuthSigner(token, realm)
+	case SignatureHMACSHA1:
+		return NewHMACSHA1OAuthSigner(token, realm)
+	}
+	return nil, errors.NotValidf("signature method %q", method)
+}
+
+// Trick to ensure *HMACSHA1OAuthSigner implements the OAuthSigner interface.
+var _ OAuthSigner = (*HMACSHA1OAuthSigner)(nil)
+
+// HMACSHA1OAuthSigner signs requests using the OAuth 1.0a HMAC-SHA1 method:
+// https://tools.ietf.org/html/rfc5849#section-3.4.2.
+type HMACSHA1OAuthSigner struct {
+	Token *OAuthToken
+	Realm string
+	// Nonce returns the nonce of each signature. It defaults to a random
+	// value, and can be replaced to get deterministic signatures in tests.
+	Nonce func() (string, error)
+	// Timestamp returns the timestamp of each signature. It defaults to the
+	// current Unix time.
+	Timestamp func() string
+}
+
+func NewHMACSHA1OAuthSigner(token *OAuthToken, realm string) (OAuthSigner, error) {
+	return &HMACSHA1OAuthSigner{Token: token, Realm: realm}, nil
+}
+
+// OAuthSign signs the provided request and sets its Authorization header.
+func (signer *HMACSHA1OAuthSigner) OAuthSign(request *http.Request) error {
+	nonce := generateNonce
+	if signer.Nonce != nil {
+		nonce = signer.Nonce
+	}
+	timestamp := generateTimestamp
+	if signer.Timestamp != nil {
+		timestamp = signer.Timestamp
+	}
+	nonceValue, err := nonce()
+	if err != nil {
+		return err
+	}
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     signer.Token.ConsumerKey,
+		"oauth_token":            signer.Token.TokenKey,
+		"oauth_signature_method": string(SignatureHMACSHA1),
+		"oauth_timestamp":        timestamp(),
+		"oauth_nonce":            nonceValue,
+		"oauth_version":          "1.0",
+	}
+	baseString, err := signatureBaseString(request, oauthParams)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	key := percentEncode(signer.Token.ConsumerSecret) + "&" + percentEncode(signer.Token.TokenSecret)
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(baseString))
+	oauthParams["oauth_signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	keys := make([]string, 0, len(oauthParams))
+	for key := range oauthParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	authHeader := []string{fmt.Sprintf(`realm="%s"`, percentEncode(signer.Realm))}
+	for _, key := range keys {
+		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, percentEncode(oauthParams[key])))
+	}
+	request.Header.Set("Authorization", "OAuth "+strings.Join(authHeader, ", "))
+	return nil
+}
+
+// signatureBaseString builds the signature base string of the request, as
+// described in https://tools.ietf.org/html/rfc5849#section-3.4.1. The
+// parameters are those of the query, the form body when the request is a
+// single-part form, and oauthParams. Multipart bodies are not signed.
+func signatureBaseString(request *http.Request, oauthParams map[string]string) (string, error) {
+	var params []encodedParam
+	addParams := func(values url.Values) {
+		for key, list := range values {
+			for _, value := range list {
+				params = append(params, encodedParam{percentEncode(key), percentEncode(value)})
+			}
+		}
+	}
+	query, err := url.ParseQuery(request.URL.RawQuery)
+	if err != nil {
+		return "", errors.Annotate(err, "cannot parse query")
+	}
+	addParams(query)
+
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" && request.Body != nil {
+		body, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return "", errors.Annotate(err, "cannot read form body")
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", errors.Annotate(err, "cannot parse form body")
+		}
+		addParams(form)
+	}
+
+	for key, value := range oauthParams {
+		params = append(params, encodedParam{percentEncode(key), percentEncode(value)})
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].value < params[j].value
+	})
+	pairs := make([]string, len(params))
+	for i, param := range params {
+		pairs[i] = param.key + "=" + param.value
+	}
+
+	return strings.Join([]string{
+		request.Method,
+		percentEncode(baseStringURI(request)),
+		percentEncode(strings.Join(pairs, "&")),
+	}, "&"), nil
+}
+
+// encodedParam is a percent encoded parameter of the signature base string.
+type encodedParam struct {
+	key, value string
+}
+
+// baseStringURI returns the request URL without query, with a lower case
+// scheme and host, and without the default port.
+func baseStringURI(request *http.Request) string {
+	scheme := strings.ToLower(request.URL.Scheme)
+	host := request.URL.Host
+	if host == "" {
+		host = request.Host
+	}
+	host = strings.ToLower(host)
+	if (scheme == "http" && strings.HasSuffix(host, ":80")) || (scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path
+}
+
+// percentEncode encodes s as described in
+// https://tools.ietf.org/html/rfc5849#section-3.6: every byte other than
+// ALPHA, DIGIT, "-", ".", "_" and "~" is encoded.
+func percentEncode(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixedHMACSigner(token *OAuthToken) *HMACSHA1OAuthSigner {
+	return &HMACSHA1OAuthSigner{
+		Token:     token,
+		Realm:     "maas API",
+		Nonce:     func() (string, error) { return "kYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg", nil },
+		Timestamp: func() string { return "1318622958" },
+	}
+}
+
+func TestPercentEncode(t *testing.T) {
+	assert.Equal(t, percentEncode("Ladies + Gentlemen"), "Ladies%20%2B%20Gentlemen")
+	assert.Equal(t, percentEncode("An encoded string!"), "An%20encoded%20string%21")
+	assert.Equal(t, percentEncode("Dogs, Cats & Mice"), "Dogs%2C%20Cats%20%26%20Mice")
+	assert.Equal(t, percentEncode("-._~"), "-._~")
+	assert.Equal(t, percentEncode("☃"), "%E2%98%83")
+}
+
+func TestSignatureBaseString(t *testing.T) {
+	// The example of https://tools.ietf.org/html/rfc5849#section-3.4.1.1.
+	request, err := http.NewRequest("POST", "http://example.com/request?b5=%3D%253D&a3=a&c%40=&a2=r%20b", strings.NewReader("c2&a3=2+q"))
+	assert.Nil(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	baseString, err := signatureBaseString(request, map[string]string{
+		"oauth_consumer_key":     "9djdj82h48djs9d2",
+		"oauth_token":            "kkk9d7dh3k39sjv7",
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        "137131201",
+		"oauth_nonce":            "7d8f3e4a",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, baseString, "POST&http%3A%2F%2Fexample.com%2Frequest&a2%3Dr%2520b%26a3%3D2%2520q%26a3%3Da%26b5%3D%253D%25253D%26c%2540%3D%26c2%3D%26oauth_consumer_key%3D9djdj82h48djs9d2%26oauth_nonce%3D7d8f3e4a%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D137131201%26oauth_token%3Dkkk9d7dh3k39sjv7")
+	// The form body can still be sent.
+	body, err := ioutil.ReadAll(request.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, string(body), "c2&a3=2+q")
+}
+
+func TestSignatureBaseStringExcludesMultipartBody(t *testing.T) {
+	request, err := http.NewRequest("POST", "https://maas.example.com:443/MAAS/api/2.0/files/?op=", strings.NewReader("a=b"))
+	assert.Nil(t, err)
+	request.Header.Set("Content-Type", "multipart/form-data; boundary=xyz")
+
+	baseString, err := signatureBaseString(request, map[string]string{"oauth_nonce": "n"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, baseString, "POST&https%3A%2F%2Fmaas.example.com%2FMAAS%2Fapi%2F2.0%2Ffiles%2F&oauth_nonce%3Dn%26op%3D")
+}
+
+func TestBaseStringURIKeepsOtherPorts(t *testing.T) {
+	request, err := http.NewRequest("GET", "HTTP://MAAS.example.com:5240/MAAS/api/2.0/", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, baseStringURI(request), "http://maas.example.com:5240/MAAS/api/2.0/")
+}
+
+func TestHMACSHA1OAuthSign(t *testing.T) {
+	signer := fixedHMACSigner(&OAuthToken{
+		ConsumerKey:    "xvz1evFS4wEEPTGEFPHBog",
+		ConsumerSecret: "kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw",
+		TokenKey:       "370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb",
+		TokenSecret:    "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE",
+	})
+	request, err := http.NewRequest("POST", "https://api.twitter.com/1.1/statuses/update.json?include_entities=true",
+		strings.NewReader("status=Hello%20Ladies%20%2b%20Gentlemen%2c%20a%20signed%20OAuth%20request%21"))
+	assert.Nil(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err = signer.OAuthSign(request)
+
+	assert.Nil(t, err)
+	header := request.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(header, `OAuth realm="maas%20API", `))
+	baseString := "POST&https%3A%2F%2Fapi.twitter.com%2F1.1%2Fstatuses%2Fupdate.json&include_entities%3Dtrue%26oauth_consumer_key%3Dxvz1evFS4wEEPTGEFPHBog%26oauth_nonce%3DkYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D1318622958%26oauth_token%3D370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb%26oauth_version%3D1.0%26status%3DHello%2520Ladies%2520%252B%2520Gentlemen%252C%2520a%2520signed%2520OAuth%2520request%2521"
+	mac := hmac.New(sha1.New, []byte("kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw&LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE"))
+	mac.Write([]byte(baseString))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	assert.Contains(t, header, `oauth_signature="`+percentEncode(signature)+`"`)
+	assert.Contains(t, header, `oauth_signature_method="HMAC-SHA1"`)
+	assert.NotContains(t, header, "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE")
+}
+
+func TestHMACSHA1OAuthSignPrefixKeys(t *testing.T) {
+	// "a" and "name" are prefixes of other keys, so the pairs must be sorted
+	// by key rather than as "key=value" strings.
+	signer := fixedHMACSigner(&OAuthToken{ConsumerKey: "a", TokenKey: "b", TokenSecret: "c"})
+	request, err := http.NewRequest("GET", "http://maas.example.com/MAAS/api/2.0/machines/?name-2=x&a1=2&name=y&a=1", nil)
+	assert.Nil(t, err)
+
+	baseString, err := signatureBaseString(request, map[string]string{"oauth_nonce": "n"})
+	assert.Nil(t, err)
+	assert.Equal(t, baseString, "GET&http%3A%2F%2Fmaas.example.com%2FMAAS%2Fapi%2F2.0%2Fmachines%2F&a%3D1%26a1%3D2%26name%3Dy%26name-2%3Dx%26oauth_nonce%3Dn")
+
+	assert.Nil(t, signer.OAuthSign(request))
+	assert.Contains(t, request.Header.Get("Authorization"), `oauth_signature="a8TjsPl79ydZkqp2iZAmfX93c1g%3D"`)
+}
+
+func TestHMACSHA1OAuthSignReplacesHeader(t *testing.T) {
+	signer := fixedHMACSigner(&OAuthToken{ConsumerKey: "a", TokenKey: "b", TokenSecret: "c"})
+	request, err := http.NewRequest("GET", "http://maas.example.com/MAAS/api/2.0/machines/", nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, signer.OAuthSign(request))
+	assert.Nil(t, signer.OAuthSign(request))
+
+	assert.Len(t, request.Header["Authorization"], 1)
+}
+
+func TestNewOAuthSigner(t *testing.T) {
+	token := &OAuthToken{ConsumerKey: "a", TokenKey: "b", TokenSecret: "c"}
+
+	signer, err := NewOAuthSigner("", token, "maas API")
+	assert.Nil(t, err)
+	assert.IsType(t, signer, &plainTextOAuthSigner{})
+
+	signer, err = NewOAuthSigner(SignatureHMACSHA1, token, "maas API")
+	assert.Nil(t, err)
+	assert.IsType(t, signer, &HMACSHA1OAuthSigner{})
+
+	_, err = NewOAuthSigner("RSA-SHA1", token, "maas API")
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func TestClientWithHMACSHA1SignsRequests(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		authorization = request.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	client, err := NewAuthenticatedMAASClientWithOptions(server.URL, "a:b:c", Options{SignatureMethod: SignatureHMACSHA1})
+	assert.Nil(t, err)
+
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", url.Values{"name": {"node-1"}}, nil)
+
+	assert.Nil(t, err)
+	assert.Contains(t, authorization, `oauth_signature_method="HMAC-SHA1"`)
+	assert.NotContains(t, authorization, `oauth_signature="%26c"`)
+}
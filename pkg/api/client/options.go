@@ -0,0 +1,150 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultMaxIdleConnsPerHost is the number of idle keep-alive connections
+	// kept per host when Options.MaxIdleConnsPerHost is not set. It is higher
+	// than net/http's default of 2 because a MAASClient typically talks to a
+	// single region controller.
+	DefaultMaxIdleConnsPerHost = 16
+
+	// DefaultIdleConnTimeout is how long an idle keep-alive connection is kept
+	// when Options.IdleConnTimeout is not set.
+	DefaultIdleConnTimeout = 90 * time.Second
+)
+
+// Options configures how a MAASClient talks HTTP to the server. The zero
+// value gives the client its own pooled transport that reuses keep-alive
+// connections across calls.
+type Options struct {
+	// HTTPClient, when set, is used as is for every request and the other
+	// fields of Options are ignored.
+	HTTPClient *http.Client
+
+	// Transport, when set, is the RoundTripper of the http.Client built
+	// from these options. The connection limits below only apply to the
+	// transport built when Transport is nil.
+	Transport http.RoundTripper
+
+	// Timeout limits the time of a single HTTP attempt, including reading
+	// the response body. Zero means no timeout; use a context deadline to
+	// bound a whole call including retries.
+	Timeout time.Duration
+
+	// MaxIdleConnsPerHost is the number of idle connections kept per host.
+	// Zero means DefaultMaxIdleConnsPerHost.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the total number of connections per host,
+	// including those in use. Zero means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept before being
+	// closed. Zero means DefaultIdleConnTimeout.
+	IdleConnTimeout time.Duration
+
+	// TLS configures trust roots, client certificates and pinning for
+	// HTTPS endpoints. When Transport is set, it must be an *http.Transport
+	// for TLS to be applied.
+	TLS *TLSOptions
+
+	// RetryPolicy decides which failed requests are sent again. Nil means
+	// DefaultRetryPolicy.
+	RetryPolicy RetryPolicy
+
+	// Clock times the waits between retries. Nil means WallClock.
+	Clock Clock
+
+	// Middleware wraps every attempt at sending a request, the first
+	// being the outermost.
+	Middleware []Middleware
+
+	// Metrics, when set, records every attempt at sending a request. It
+	// is shared, not copied, by the clients built from the options.
+	Metrics *Metrics
+
+	// Tracer, when set, starts a span for every attempt at sending a
+	// request, and for the operations of controllers using the client.
+	Tracer Tracer
+
+	// Limiter, when set, caps the rate and concurrency of the attempts at
+	// sending requests. It is shared, not copied, by the clients built
+	// from the options.
+	Limiter *Limiter
+
+	// Breaker, when set, stops sending requests to a failing server. It
+	// is shared, not copied, by the clients built from the options.
+	Breaker *Breaker
+
+	// Endpoints, when set, sends the requests to one of several region
+	// controllers, failing over between them. It is shared, not copied,
+	// by the clients built from the options.
+	Endpoints *EndpointPool
+
+	// SignatureMethod is how authenticated clients sign their requests.
+	// Empty means SignaturePlaintext.
+	SignatureMethod SignatureMethod
+}
+
+// Client returns the http.Client described by the options. Each call without
+// HTTPClient set builds a new client with its own connection pool, so the
+// result should be kept and shared.
+func (o Options) Client() (*http.Client, error) {
+	if o.HTTPClient != nil {
+		return o.HTTPClient, nil
+	}
+	transport := o.Transport
+	if transport == nil {
+		transport = o.newTransport()
+	}
+	if o.TLS != nil {
+		httpTransport, ok := transport.(*http.Transport)
+		if !ok {
+			return nil, errors.NotValidf("TLS options with a %T transport", transport)
+		}
+		tlsConfig, err := o.TLS.Config()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if httpTransport == o.Transport {
+			// Don't modify the caller's transport.
+			httpTransport = httpTransport.Clone()
+		}
+		httpTransport.TLSClientConfig = tlsConfig
+		transport = httpTransport
+	}
+	return &http.Client{Transport: transport, Timeout: o.Timeout}, nil
+}
+
+// newTransport builds a keep-alive pooling transport with the same dialing
+// behaviour as http.DefaultTransport and the connection limits of the options.
+func (o Options) newTransport() *http.Transport {
+	maxIdlePerHost := o.MaxIdleConnsPerHost
+	if maxIdlePerHost == 0 {
+		maxIdlePerHost = DefaultMaxIdleConnsPerHost
+	}
+	idleTimeout := o.IdleConnTimeout
+	if idleTimeout == 0 {
+		idleTimeout = DefaultIdleConnTimeout
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxIdlePerHost,
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		IdleConnTimeout:       idleTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	calls int32
+	next  http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	return t.next.RoundTrip(request)
+}
+
+func TestOptionsClientUsesHTTPClient(t *testing.T) {
+	httpClient := &http.Client{}
+	opts := Options{HTTPClient: httpClient, Timeout: time.Second}
+	result, err := opts.Client()
+	assert.Nil(t, err)
+	assert.True(t, result == httpClient)
+}
+
+func TestOptionsClientUsesTransport(t *testing.T) {
+	transport := &countingTransport{next: http.DefaultTransport}
+	httpClient, err := Options{Transport: transport, Timeout: time.Minute}.Client()
+	assert.Nil(t, err)
+	assert.Equal(t, httpClient.Transport, transport)
+	assert.Equal(t, httpClient.Timeout, time.Minute)
+}
+
+func TestOptionsClientBuildsPooledTransport(t *testing.T) {
+	httpClient, err := Options{MaxConnsPerHost: 4}.Client()
+	assert.Nil(t, err)
+	transport, ok := httpClient.Transport.(*http.Transport)
+	assert.True(t, ok)
+	assert.False(t, transport.DisableKeepAlives)
+	assert.Equal(t, transport.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+	assert.Equal(t, transport.MaxConnsPerHost, 4)
+	assert.Equal(t, transport.IdleConnTimeout, DefaultIdleConnTimeout)
+}
+
+func TestClientWithOptionsSendsThroughTransport(t *testing.T) {
+	URI, err := url.Parse("/some/url")
+	assert.Nil(t, err)
+	server := newSingleServingServer(URI.String(), "expected:result", http.StatusOK)
+	defer server.Close()
+	transport := &countingTransport{next: http.DefaultTransport}
+	client, err := NewAuthenticatedMAASClientWithOptions(server.URL, "the:api:key", Options{Transport: transport})
+	assert.Nil(t, err)
+
+	result, err := client.Get(URI, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, string(result), "expected:result")
+	assert.EqualValues(t, atomic.LoadInt32(&transport.calls), 1)
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, "ok")
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+	client, err := NewAnonymousClientWithOptions(server.URL, "1.0", Options{})
+	assert.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(&url.URL{Path: "machines/"}, "", nil)
+		assert.Nil(t, err)
+	}
+
+	assert.EqualValues(t, atomic.LoadInt32(&connections), 1)
+}
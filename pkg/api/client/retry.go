@@ -0,0 +1,262 @@
+package client
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultBaseDelay is the wait before the first retry when the server
+	// does not say how long to wait.
+	DefaultBaseDelay = 100 * time.Millisecond
+
+	// DefaultMaxDelay caps the exponential backoff between attempts.
+	DefaultMaxDelay = 10 * time.Second
+
+	// DefaultJitter is the fraction of each backoff delay that is
+	// randomized by DefaultRetryPolicy.
+	DefaultJitter = 0.2
+)
+
+var (
+	// DefaultRetryableStatus lists the response status codes retried by a
+	// BackoffPolicy that does not set RetryableStatus: the gateway errors
+	// returned by a proxy in front of a restarting region controller, and
+	// 503 which maas uses when it is too busy to serve a request.
+	DefaultRetryableStatus = []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+
+	// DefaultSafePostOps lists the POST operations that can be repeated
+	// without changing their outcome, and so are retried like GET, PUT and
+	// DELETE requests. Other POST operations, like allocate or deploy, are
+	// only retried when the server did not process them. power_on is left
+	// out, as repeating it can act twice on the hardware; callers willing to
+	// retry it add it to SafePostOps.
+	DefaultSafePostOps = []string{
+		"set_owner_data",
+		"power_off",
+		"query_power_state",
+		"clear_default_gateways",
+		"restore_default_configuration",
+		"restore_networking_configuration",
+		"restore_storage_configuration",
+	}
+)
+
+// Clock provides the time to a MAASClient, so that tests can control the
+// waits between retries instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time                         { return time.Now() }
+func (wallClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// WallClock is the Clock backed by the time package.
+var WallClock Clock = wallClock{}
+
+// RetryAttempt describes a failed attempt to send a request.
+type RetryAttempt struct {
+	// Number is the number of the attempt that failed, starting at 1.
+	Number int
+	// Method is the HTTP method of the request.
+	Method string
+	// Op is the maas operation of the request, from its "op" query
+	// parameter, if any.
+	Op string
+	// Err is the error of the attempt. Non-2xx responses are reported as a
+	// ServerError.
+	Err error
+	// Now is the current time of the client's clock, used to interpret
+	// Retry-After dates.
+	Now time.Time
+}
+
+// RetryPolicy decides which failed requests a MAASClient sends again.
+type RetryPolicy interface {
+	// Retry reports whether the failed attempt should be retried, and how
+	// long to wait before doing so.
+	Retry(attempt RetryAttempt) (time.Duration, bool)
+}
+
+// BackoffPolicy is a RetryPolicy with exponential backoff. It retries
+// requests that failed with a retryable status or a connection error when
+// repeating them is safe: GET, HEAD, PUT, DELETE and POST operations listed
+// in SafePostOps. A 503 response carrying a Retry-After header, or a
+// connection that could not be established, means the server did not act on
+// the request, so those are retried for every method.
+//
+// Zero fields take their Default* values.
+type BackoffPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent,
+	// including the first. Zero means NumberOfRetries + 1.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; it doubles on every
+	// following attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the computed backoff. A Retry-After header is honoured
+	// as sent.
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, of the backoff that is
+	// randomly removed so that clients don't retry in lockstep. Zero
+	// disables jitter.
+	Jitter float64
+	// RetryableStatus lists the retried response status codes.
+	RetryableStatus []int
+	// SafePostOps lists the POST operations that are safe to repeat.
+	SafePostOps []string
+	// Rand returns a number in [0, 1) used for jitter. It defaults to
+	// math/rand.Float64.
+	Rand func() float64
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by a MAASClient that does
+// not set one.
+func DefaultRetryPolicy() RetryPolicy {
+	return &BackoffPolicy{Jitter: DefaultJitter}
+}
+
+// Retry implements RetryPolicy.
+func (p *BackoffPolicy) Retry(attempt RetryAttempt) (time.Duration, bool) {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = NumberOfRetries + 1
+	}
+	if attempt.Number >= maxAttempts {
+		return 0, false
+	}
+
+	if svrErr, ok := GetServerError(attempt.Err); ok {
+		if !p.retryableStatus(svrErr.StatusCode) {
+			return 0, false
+		}
+		if delay, ok := retryAfter(svrErr.Header, attempt.Now); ok {
+			if svrErr.StatusCode == http.StatusServiceUnavailable || p.safeToRepeat(attempt) {
+				return delay, true
+			}
+			return 0, false
+		}
+		if !p.safeToRepeat(attempt) {
+			return 0, false
+		}
+		return p.backoff(attempt.Number), true
+	}
+
+	retryable, notSent := classifyTransportError(attempt.Err)
+	if !retryable || !(notSent || p.safeToRepeat(attempt)) {
+		return 0, false
+	}
+	return p.backoff(attempt.Number), true
+}
+
+func (p *BackoffPolicy) retryableStatus(code int) bool {
+	statuses := p.RetryableStatus
+	if statuses == nil {
+		statuses = DefaultRetryableStatus
+	}
+	for _, status := range statuses {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *BackoffPolicy) safeToRepeat(attempt RetryAttempt) bool {
+	switch attempt.Method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	case "POST":
+		ops := p.SafePostOps
+		if ops == nil {
+			ops = DefaultSafePostOps
+		}
+		for _, op := range ops {
+			if op != "" && op == attempt.Op {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before retrying the numbered attempt.
+func (p *BackoffPolicy) backoff(number int) time.Duration {
+	base := p.BaseDelay
+	if base == 0 {
+		base = DefaultBaseDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay == 0 {
+		maxDelay = DefaultMaxDelay
+	}
+	delay := base
+	for i := 1; i < number && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if p.Jitter > 0 {
+		random := p.Rand
+		if random == nil {
+			random = rand.Float64
+		}
+		delay -= time.Duration(p.Jitter * random() * float64(delay))
+	}
+	return delay
+}
+
+// retryAfter parses the Retry-After header, given either as a number of
+// seconds or as an HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get(RetryAfterHeaderName)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	delay := date.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+// classifyTransportError reports whether err is a connection failure worth
+// retrying, and whether the request was certainly not sent.
+func classifyTransportError(err error) (retryable bool, notSent bool) {
+	err = errors.Cause(err)
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// The server closed the connection, e.g. while restarting.
+		return true, false
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		return true, opErr.Op == "dial"
+	}
+	return false, false
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock records the waits asked for and never sleeps.
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now.Add(d)
+	return ch
+}
+
+func serverErrorWithHeader(code int, header http.Header) error {
+	return errors.Trace(ServerError{error: errors.New("flaky"), StatusCode: code, Header: header})
+}
+
+func newStatusServer(codes ...int) (*httptest.Server, *int) {
+	nbRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		code := http.StatusOK
+		if nbRequests < len(codes) {
+			code = codes[nbRequests]
+		}
+		nbRequests++
+		writer.WriteHeader(code)
+	}))
+	return server, &nbRequests
+}
+
+func TestBackoffPolicyExponentialDelays(t *testing.T) {
+	policy := &BackoffPolicy{}
+	var delays []time.Duration
+	for number := 1; ; number++ {
+		delay, ok := policy.Retry(RetryAttempt{Number: number, Method: "GET", Err: serverErrorWithHeader(502, nil)})
+		if !ok {
+			break
+		}
+		delays = append(delays, delay)
+	}
+	assert.Equal(t, delays, []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+	})
+}
+
+func TestBackoffPolicyMaxDelay(t *testing.T) {
+	policy := &BackoffPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	delay, ok := policy.Retry(RetryAttempt{Number: 5, Method: "GET", Err: serverErrorWithHeader(504, nil)})
+	assert.True(t, ok)
+	assert.Equal(t, delay, 3*time.Second)
+}
+
+func TestBackoffPolicyJitter(t *testing.T) {
+	policy := &BackoffPolicy{Jitter: 0.5, Rand: func() float64 { return 0.5 }}
+	delay, ok := policy.Retry(RetryAttempt{Number: 2, Method: "GET", Err: serverErrorWithHeader(502, nil)})
+	assert.True(t, ok)
+	assert.Equal(t, delay, 150*time.Millisecond)
+}
+
+func TestBackoffPolicyRetryAfterSeconds(t *testing.T) {
+	header := http.Header{RetryAfterHeaderName: {"7"}}
+	delay, ok := (&BackoffPolicy{}).Retry(RetryAttempt{Number: 1, Method: "GET", Err: serverErrorWithHeader(503, header)})
+	assert.True(t, ok)
+	assert.Equal(t, delay, 7*time.Second)
+}
+
+func TestBackoffPolicyRetryAfterDate(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	header := http.Header{RetryAfterHeaderName: {now.Add(30 * time.Second).Format(http.TimeFormat)}}
+	delay, ok := (&BackoffPolicy{}).Retry(RetryAttempt{Number: 1, Method: "GET", Err: serverErrorWithHeader(503, header), Now: now})
+	assert.True(t, ok)
+	assert.Equal(t, delay, 30*time.Second)
+}
+
+func TestBackoffPolicyDoesntRetryClientErrors(t *testing.T) {
+	for _, code := range []int{400, 403, 404, 409, 500} {
+		_, ok := (&BackoffPolicy{}).Retry(RetryAttempt{Number: 1, Method: "GET", Err: serverErrorWithHeader(code, nil)})
+		assert.False(t, ok, "status %d", code)
+	}
+}
+
+func TestBackoffPolicyPostOps(t *testing.T) {
+	policy := &BackoffPolicy{}
+	err := serverErrorWithHeader(502, nil)
+
+	_, ok := policy.Retry(RetryAttempt{Number: 1, Method: "POST", Op: "allocate", Err: err})
+	assert.False(t, ok)
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "POST", Err: err})
+	assert.False(t, ok)
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "POST", Op: "set_owner_data", Err: err})
+	assert.True(t, ok)
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "POST", Op: "power_on", Err: err})
+	assert.False(t, ok)
+
+	policy.SafePostOps = []string{"allocate"}
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "POST", Op: "allocate", Err: err})
+	assert.True(t, ok)
+}
+
+func TestBackoffPolicyRetriesUnprocessedPost(t *testing.T) {
+	policy := &BackoffPolicy{}
+
+	// A 503 with Retry-After means maas did not process the request.
+	header := http.Header{RetryAfterHeaderName: {"1"}}
+	_, ok := policy.Retry(RetryAttempt{Number: 1, Method: "POST", Op: "allocate", Err: serverErrorWithHeader(503, header)})
+	assert.True(t, ok)
+
+	dialErr := &url.Error{Op: "Post", URL: "http://maas/", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "POST", Op: "allocate", Err: dialErr})
+	assert.True(t, ok)
+
+	readErr := &url.Error{Op: "Post", URL: "http://maas/", Err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "POST", Op: "allocate", Err: readErr})
+	assert.False(t, ok)
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "GET", Err: readErr})
+	assert.True(t, ok)
+}
+
+func TestBackoffPolicyTransportErrors(t *testing.T) {
+	policy := &BackoffPolicy{}
+	_, ok := policy.Retry(RetryAttempt{Number: 1, Method: "GET", Err: &url.Error{Op: "Get", URL: "http://maas/", Err: io.EOF}})
+	assert.True(t, ok)
+	_, ok = policy.Retry(RetryAttempt{Number: 1, Method: "GET", Err: errors.New("unsupported protocol scheme")})
+	assert.False(t, ok)
+}
+
+func TestClientRetriesGatewayErrorsWithBackoff(t *testing.T) {
+	server, nbRequests := newStatusServer(502, 504)
+	defer server.Close()
+	clock := &fakeClock{}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{
+		RetryPolicy: &BackoffPolicy{},
+		Clock:       clock,
+	})
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, *nbRequests, 3)
+	assert.Equal(t, clock.waits, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond})
+}
+
+func TestClientDoesntRetryUnsafePost(t *testing.T) {
+	server, nbRequests := newStatusServer(502)
+	defer server.Close()
+	clock := &fakeClock{}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: clock})
+	assert.Nil(t, err)
+
+	_, err = client.Post(&url.URL{Path: "machines/"}, "allocate", nil, nil)
+
+	svrErr, ok := GetServerError(err)
+	assert.True(t, ok)
+	assert.Equal(t, svrErr.StatusCode, 502)
+	assert.Equal(t, *nbRequests, 1)
+	assert.Len(t, clock.waits, 0)
+}
+
+func TestClientRetriesConnectionReset(t *testing.T) {
+	nbRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		nbRequests++
+		if nbRequests == 1 {
+			// Drop the connection without a response, like a restarting
+			// region controller.
+			conn, _, err := writer.(http.Hijacker).Hijack()
+			assert.Nil(t, err)
+			conn.Close()
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	clock := &fakeClock{}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: clock})
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, nbRequests, 2)
+	assert.Len(t, clock.waits, 1)
+}
+
+func TestClientMaxAttempts(t *testing.T) {
+	server, nbRequests := newStatusServer(503, 503, 503)
+	defer server.Close()
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{
+		RetryPolicy: &BackoffPolicy{MaxAttempts: 2},
+		Clock:       &fakeClock{},
+	})
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "machines/"}, "", nil)
+
+	svrErr, ok := GetServerError(err)
+	assert.True(t, ok)
+	assert.Equal(t, svrErr.StatusCode, 503)
+	assert.Equal(t, *nbRequests, 2)
+}
@@ -4,10 +4,14 @@
 package client
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 
 	"github.com/lxc/lxd/shared/logger"
 )
@@ -102,6 +106,8 @@ type SimpleTestServer struct {
 	deleteResponseIndex map[string]int
 
 	requests []*http.Request
+
+	login *LoginHandler
 }
 
 func NewSimpleServer() *SimpleTestServer {
@@ -139,6 +145,12 @@ func (s *SimpleTestServer) AddDeleteResponse(path string, status int, body strin
 	s.deleteResponses[path] = append(s.deleteResponses[path], simpleResponse{status: status, body: body})
 }
 
+// SetLoginHandler makes the server answer the login form and account token
+// requests with the given LoginHandler.
+func (s *SimpleTestServer) SetLoginHandler(login *LoginHandler) {
+	s.login = login
+}
+
 func (s *SimpleTestServer) LastRequest() *http.Request {
 	pos := len(s.requests) - 1
 	if pos < 0 {
@@ -164,6 +176,11 @@ func (s *SimpleTestServer) ResetRequests() {
 }
 
 func (s *SimpleTestServer) handler(writer http.ResponseWriter, request *http.Request) {
+	if s.login != nil && s.login.handles(request) {
+		s.requests = append(s.requests, request)
+		s.login.ServeHTTP(writer, request)
+		return
+	}
 	method := request.Method
 	var (
 		err           error
@@ -222,3 +239,175 @@ func (s *SimpleTestServer) handler(writer http.ResponseWriter, request *http.Req
 		fmt.Fprint(writer, response.body)
 	}
 }
+
+// LoginHandler is a stand-in for the maas login form and the account
+// operations managing API tokens, so that Login can be tested without a
+// maas server. It serves any path ending in accounts/login/ or account/.
+type LoginHandler struct {
+	Username string
+	Password string
+	// APIKey is the key of the tokens created by create_authorisation_token,
+	// in the "<consumer key>:<token key>:<token secret>" form.
+	APIKey string
+	// Tokens holds the API keys of the account's named tokens, by name.
+	Tokens map[string]string
+	// Created counts the tokens created.
+	Created int
+
+	mu       sync.Mutex
+	sessions int
+}
+
+const testCSRFToken = "test-csrf-token"
+
+// NewLoginHandler returns a LoginHandler accepting the given credentials.
+func NewLoginHandler(username, password, apiKey string) *LoginHandler {
+	return &LoginHandler{
+		Username: username,
+		Password: password,
+		APIKey:   apiKey,
+		Tokens:   make(map[string]string),
+	}
+}
+
+func (h *LoginHandler) handles(request *http.Request) bool {
+	return strings.HasSuffix(request.URL.Path, "/"+LoginPath) || strings.HasSuffix(request.URL.Path, "/account/")
+}
+
+func (h *LoginHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if strings.HasSuffix(request.URL.Path, "/"+LoginPath) {
+		h.serveLogin(writer, request)
+		return
+	}
+	session, err := request.Cookie(sessionCookieName)
+	if err != nil || !strings.HasPrefix(session.Value, "session-") {
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch op := request.URL.Query().Get("op"); {
+	case request.Method == "GET" && op == "list_authorisation_tokens":
+		tokens := []listedToken{}
+		for name, key := range h.Tokens {
+			tokens = append(tokens, listedToken{Name: name, Token: key})
+		}
+		json.NewEncoder(writer).Encode(tokens)
+	case request.Method == "POST" && op == "create_authorisation_token":
+		if request.Header.Get(csrfHeaderName) != testCSRFToken {
+			http.Error(writer, "CSRF Failed", http.StatusForbidden)
+			return
+		}
+		name := request.FormValue("name")
+		if name != "" {
+			h.Tokens[name] = h.APIKey
+		}
+		h.Created++
+		elements := strings.Split(h.APIKey, ":")
+		json.NewEncoder(writer).Encode(authorisationToken{
+			Name:        name,
+			ConsumerKey: elements[0],
+			TokenKey:    elements[1],
+			TokenSecret: elements[2],
+		})
+	default:
+		http.Error(writer, "unknown operation", http.StatusBadRequest)
+	}
+}
+
+func (h *LoginHandler) serveLogin(writer http.ResponseWriter, request *http.Request) {
+	http.SetCookie(writer, &http.Cookie{Name: csrfCookieName, Value: testCSRFToken, Path: "/"})
+	if request.Method == "POST" {
+		if request.FormValue("csrfmiddlewaretoken") != testCSRFToken {
+			http.Error(writer, "CSRF verification failed", http.StatusForbidden)
+			return
+		}
+		if request.FormValue("username") == h.Username && request.FormValue("password") == h.Password {
+			h.sessions++
+			http.SetCookie(writer, &http.Cookie{Name: sessionCookieName, Value: fmt.Sprintf("session-%d", h.sessions), Path: "/"})
+			http.Redirect(writer, request, "../../", http.StatusFound)
+			return
+		}
+	}
+	// Like maas, render the form again after a failed login.
+	fmt.Fprint(writer, `<form method="post"><input name="username"><input name="password"></form>`)
+}
+
+// RecordingTracer is a Tracer keeping the spans it starts, for tests. Span
+// IDs are allocated in sequence.
+type RecordingTracer struct {
+	mu     sync.Mutex
+	spans  []*RecordedSpan
+	nextID uint64
+}
+
+// RecordedSpan is a span started by a RecordingTracer.
+type RecordedSpan struct {
+	Name    string
+	Context SpanContext
+	// Parent is the context of the parent span; it is not valid for root
+	// spans.
+	Parent     SpanContext
+	Attributes map[string]interface{}
+	Errors     []error
+	Ended      bool
+	tracer     *RecordingTracer
+}
+
+type recordedSpanKey struct{}
+
+// Start implements Tracer.
+func (t *RecordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	span := &RecordedSpan{Name: name, Attributes: make(map[string]interface{}), tracer: t}
+	if parent, ok := ctx.Value(recordedSpanKey{}).(*RecordedSpan); ok {
+		span.Parent = parent.Context
+		span.Context.TraceID = parent.Context.TraceID
+	} else {
+		binary.BigEndian.PutUint64(span.Context.TraceID[8:], t.nextID)
+	}
+	binary.BigEndian.PutUint64(span.Context.SpanID[:], t.nextID)
+	span.Context.Flags = 1
+	for _, attr := range attrs {
+		span.Attributes[attr.Key] = attr.Value
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// Spans returns the spans started so far, in order.
+func (t *RecordingTracer) Spans() []*RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*RecordedSpan(nil), t.spans...)
+}
+
+// SpanContext implements Span.
+func (s *RecordedSpan) SpanContext() SpanContext {
+	return s.Context
+}
+
+// SetAttributes implements Span.
+func (s *RecordedSpan) SetAttributes(attrs ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	for _, attr := range attrs {
+		s.Attributes[attr.Key] = attr.Value
+	}
+}
+
+// RecordError implements Span.
+func (s *RecordedSpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Errors = append(s.Errors, err)
+}
+
+// End implements Span.
+func (s *RecordedSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.Ended = true
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// TLSOptions configures TLS for HTTPS MAAS endpoints, typically region
+// controllers behind a certificate signed by an internal CA.
+type TLSOptions struct {
+	// CAFile is the path of a PEM bundle of trusted root certificates.
+	// When CAFile or CAPEM is set, only those roots are trusted instead of
+	// the system roots.
+	CAFile string
+	// CAPEM holds PEM encoded trusted root certificates.
+	CAPEM []byte
+
+	// CertFile and KeyFile are the paths of a PEM encoded client
+	// certificate and its private key, presented when the server asks for
+	// one.
+	CertFile string
+	KeyFile  string
+	// CertPEM and KeyPEM hold a PEM encoded client certificate and key,
+	// as an alternative to CertFile and KeyFile.
+	CertPEM []byte
+	KeyPEM  []byte
+
+	// ServerName overrides the name sent for SNI and checked against the
+	// server certificate. By default the host of the MAAS URL is used.
+	ServerName string
+
+	// MinVersion is the minimum TLS version accepted, e.g. tls.VersionTLS12.
+	// Zero means TLS 1.2.
+	MinVersion uint16
+
+	// PinnedSHA256 lists hex encoded SHA-256 fingerprints of server
+	// certificates. When set, the certificate presented by the server must
+	// match one of them, in addition to the usual verification. Colons
+	// between the hex bytes are ignored.
+	PinnedSHA256 []string
+
+	// InsecureSkipVerify disables verification of the server certificate
+	// chain and host name. It is meant for lab setups only; pinned
+	// fingerprints are still enforced.
+	InsecureSkipVerify bool
+}
+
+// Config builds the tls.Config described by the options.
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	minVersion := o.MinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	config := &tls.Config{
+		ServerName:         o.ServerName,
+		MinVersion:         minVersion,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	roots, err := o.rootCAs()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	config.RootCAs = roots
+
+	cert, err := o.clientCertificate()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+
+	if len(o.PinnedSHA256) > 0 {
+		pins, err := parseFingerprints(o.PinnedSHA256)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		config.VerifyPeerCertificate = pinnedCertificateVerifier(pins)
+	}
+	return config, nil
+}
+
+func (o *TLSOptions) rootCAs() (*x509.CertPool, error) {
+	if o.CAFile == "" && len(o.CAPEM) == 0 {
+		// Use the system roots.
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if o.CAFile != "" {
+		bundle, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, errors.Annotate(err, "cannot read CA bundle")
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.NotValidf("CA bundle %q without PEM certificates", o.CAFile)
+		}
+	}
+	if len(o.CAPEM) > 0 && !pool.AppendCertsFromPEM(o.CAPEM) {
+		return nil, errors.NotValidf("CAPEM without PEM certificates")
+	}
+	return pool, nil
+}
+
+func (o *TLSOptions) clientCertificate() (*tls.Certificate, error) {
+	switch {
+	case o.CertFile != "" || o.KeyFile != "":
+		if len(o.CertPEM) > 0 || len(o.KeyPEM) > 0 {
+			return nil, errors.NotValidf("specifying both client certificate files and PEM")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, errors.Annotate(err, "cannot load client certificate")
+		}
+		return &cert, nil
+	case len(o.CertPEM) > 0 || len(o.KeyPEM) > 0:
+		cert, err := tls.X509KeyPair(o.CertPEM, o.KeyPEM)
+		if err != nil {
+			return nil, errors.Annotate(err, "cannot parse client certificate")
+		}
+		return &cert, nil
+	}
+	return nil, nil
+}
+
+func parseFingerprints(values []string) ([][]byte, error) {
+	pins := make([][]byte, len(values))
+	for i, value := range values {
+		pin, err := hex.DecodeString(strings.Replace(value, ":", "", -1))
+		if err != nil || len(pin) != sha256.Size {
+			return nil, errors.NotValidf("SHA-256 fingerprint %q", value)
+		}
+		pins[i] = pin
+	}
+	return pins, nil
+}
+
+// pinnedCertificateVerifier returns a tls.Config.VerifyPeerCertificate
+// callback accepting the connection only when the server certificate matches
+// one of the pins.
+func pinnedCertificateVerifier(pins [][]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server presented no certificate")
+		}
+		fingerprint := sha256.Sum256(rawCerts[0])
+		for _, pin := range pins {
+			if bytes.Equal(pin, fingerprint[:]) {
+				return nil
+			}
+		}
+		return errors.Errorf("server certificate fingerprint %x is not pinned", fingerprint)
+	}
+}
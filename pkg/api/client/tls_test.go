@@ -0,0 +1,241 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTLSTestServer() *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, "ok")
+	}))
+}
+
+func serverCAPEM(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func serverFingerprint(server *httptest.Server) string {
+	sum := sha256.Sum256(server.Certificate().Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func getWithTLS(t *testing.T, server *httptest.Server, opts *TLSOptions) error {
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{TLS: opts})
+	assert.Nil(t, err)
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	return err
+}
+
+// newClientCertificate returns a self-signed client certificate and key,
+// both PEM encoded.
+func newClientCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "maas-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func TestTLSUntrustedServerFails(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+
+	err := getWithTLS(t, server, &TLSOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestTLSTrustsCAPEM(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+
+	err := getWithTLS(t, server, &TLSOptions{CAPEM: serverCAPEM(server)})
+	assert.Nil(t, err)
+}
+
+func TestTLSTrustsCAFile(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "maas-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(caFile, serverCAPEM(server), 0600))
+
+	err = getWithTLS(t, server, &TLSOptions{CAFile: caFile})
+	assert.Nil(t, err)
+}
+
+func TestTLSBadCAPEM(t *testing.T) {
+	_, err := NewAnonymousClientWithOptions("https://maas.example.com/MAAS/", "2.0", Options{
+		TLS: &TLSOptions{CAPEM: []byte("not a certificate")},
+	})
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func TestTLSServerName(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+
+	// The httptest certificate is valid for example.com.
+	err := getWithTLS(t, server, &TLSOptions{CAPEM: serverCAPEM(server), ServerName: "example.com"})
+	assert.Nil(t, err)
+	err = getWithTLS(t, server, &TLSOptions{CAPEM: serverCAPEM(server), ServerName: "maas.internal"})
+	assert.NotNil(t, err)
+}
+
+func TestTLSInsecureSkipVerify(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+
+	err := getWithTLS(t, server, &TLSOptions{InsecureSkipVerify: true})
+	assert.Nil(t, err)
+}
+
+func TestTLSPinnedFingerprint(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+
+	err := getWithTLS(t, server, &TLSOptions{
+		CAPEM:        serverCAPEM(server),
+		PinnedSHA256: []string{serverFingerprint(server)},
+	})
+	assert.Nil(t, err)
+}
+
+func TestTLSPinnedFingerprintWithColons(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+	fingerprint := serverFingerprint(server)
+	var withColons string
+	for i := 0; i < len(fingerprint); i += 2 {
+		if i > 0 {
+			withColons += ":"
+		}
+		withColons += fingerprint[i : i+2]
+	}
+
+	err := getWithTLS(t, server, &TLSOptions{InsecureSkipVerify: true, PinnedSHA256: []string{withColons}})
+	assert.Nil(t, err)
+}
+
+func TestTLSPinnedFingerprintMismatch(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+	other := sha256.Sum256([]byte("some other certificate"))
+
+	// Pins are enforced even when verification is skipped.
+	err := getWithTLS(t, server, &TLSOptions{
+		InsecureSkipVerify: true,
+		PinnedSHA256:       []string{hex.EncodeToString(other[:])},
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not pinned")
+}
+
+func TestTLSBadFingerprint(t *testing.T) {
+	_, err := NewAnonymousClientWithOptions("https://maas.example.com/MAAS/", "2.0", Options{
+		TLS: &TLSOptions{PinnedSHA256: []string{"abcd"}},
+	})
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	err := getWithTLS(t, server, &TLSOptions{CAPEM: serverCAPEM(server), MinVersion: tls.VersionTLS13})
+	assert.NotNil(t, err)
+	err = getWithTLS(t, server, &TLSOptions{CAPEM: serverCAPEM(server), MinVersion: tls.VersionTLS12})
+	assert.Nil(t, err)
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	certPEM, keyPEM := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	assert.True(t, clientCAs.AppendCertsFromPEM(certPEM))
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		fmt.Fprint(writer, request.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	err := getWithTLS(t, server, &TLSOptions{CAPEM: serverCAPEM(server)})
+	assert.NotNil(t, err)
+	err = getWithTLS(t, server, &TLSOptions{CAPEM: serverCAPEM(server), CertPEM: certPEM, KeyPEM: keyPEM})
+	assert.Nil(t, err)
+}
+
+func TestTLSClientCertificateFiles(t *testing.T) {
+	certPEM, keyPEM := newClientCertificate(t)
+	dir, err := ioutil.TempDir("", "maas-tls")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.Nil(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	config, err := (&TLSOptions{CertFile: certFile, KeyFile: keyFile}).Config()
+	assert.Nil(t, err)
+	assert.Len(t, config.Certificates, 1)
+
+	_, err = (&TLSOptions{CertFile: certFile, KeyFile: keyFile, CertPEM: certPEM}).Config()
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func TestTLSDoesNotModifyCallerTransport(t *testing.T) {
+	server := newTLSTestServer()
+	defer server.Close()
+	transport := &http.Transport{}
+
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{
+		Transport: transport,
+		TLS:       &TLSOptions{CAPEM: serverCAPEM(server)},
+	})
+	assert.Nil(t, err)
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+	assert.Nil(t, err)
+	// Cloning may set up HTTP/2 defaults, but no trust roots.
+	if transport.TLSClientConfig != nil {
+		assert.Nil(t, transport.TLSClientConfig.RootCAs)
+	}
+}
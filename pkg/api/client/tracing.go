@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// TraceParentHeader is the W3C Trace Context header carrying the span of an
+// attempt to the server.
+const TraceParentHeader = "traceparent"
+
+// Attribute is a key/value pair describing a Span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// SpanContext identifies a span across process boundaries, as in the W3C
+// Trace Context specification.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	// Flags are the trace flags; bit 0 is set for sampled traces.
+	Flags byte
+}
+
+// IsValid reports whether the trace and span IDs are both set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent returns the value of the traceparent header for the span.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.Flags)
+}
+
+// ParseTraceParent parses the value of a version 00 traceparent header.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errors.NotValidf("traceparent %q", value)
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, errors.NotValidf("traceparent %q", value)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, errors.NotValidf("traceparent %q", value)
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, errors.NotValidf("traceparent %q", value)
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, errors.NotValidf("traceparent %q", value)
+	}
+	return sc, nil
+}
+
+// Span is a timed operation of a trace. Its methods mirror those of an
+// OpenTelemetry span, so that a Tracer can be implemented by a thin adapter.
+type Span interface {
+	// SpanContext returns the identity of the span, propagated to the server
+	// when it is valid.
+	SpanContext() SpanContext
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts the spans of a MAASClient and of the controllers using it.
+type Tracer interface {
+	// Start starts a span named name, a child of the span carried by ctx
+	// if any, and returns a context carrying the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Names of the attributes set on the spans of attempts.
+const (
+	AttributeMethod      = "http.request.method"
+	AttributeURL         = "url.full"
+	AttributeStatusCode  = "http.response.status_code"
+	AttributeResendCount = "http.request.resend_count"
+	AttributePath        = "maas.path"
+	AttributeOp          = "maas.op"
+	AttributeRequestID   = "maas.request_id"
+	AttributeAttempt     = "maas.attempt"
+)
+
+// traceAttempts wraps next with a span for every attempt, and propagates
+// the span to the server in the traceparent header.
+func traceAttempts(tracer Tracer, next Doer) Doer {
+	return DoerFunc(func(call *Call) (*Result, error) {
+		ctx, span := tracer.Start(call.Request.Context(), call.Request.Method,
+			Attribute{AttributeMethod, call.Request.Method},
+			Attribute{AttributeURL, call.Request.URL.String()},
+			Attribute{AttributePath, NormalizePath(call.Path)},
+			Attribute{AttributeOp, call.Op},
+			Attribute{AttributeRequestID, call.RequestID},
+			Attribute{AttributeAttempt, call.Attempt},
+		)
+		defer span.End()
+		if call.Attempt > 1 {
+			span.SetAttributes(Attribute{AttributeResendCount, call.Attempt - 1})
+		}
+		call.Request = call.Request.WithContext(ctx)
+		if sc := span.SpanContext(); sc.IsValid() {
+			call.Request.Header.Set(TraceParentHeader, sc.TraceParent())
+		}
+		result, err := next.Do(call)
+		if result != nil {
+			span.SetAttributes(Attribute{AttributeStatusCode, result.StatusCode})
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		return result, err
+	})
+}
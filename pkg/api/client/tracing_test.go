@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceParent(value)
+
+	assert.Nil(t, err)
+	assert.True(t, sc.IsValid())
+	assert.Equal(t, sc.Flags, byte(1))
+	assert.Equal(t, sc.TraceParent(), value)
+}
+
+func TestParseTraceParentInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceParent(value)
+		assert.True(t, errors.IsNotValid(err), value)
+	}
+}
+
+func TestTracerSpansEveryAttempt(t *testing.T) {
+	var traceParents []string
+	codes := []int{http.StatusBadGateway, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		traceParents = append(traceParents, request.Header.Get(TraceParentHeader))
+		writer.WriteHeader(codes[len(traceParents)-1])
+	}))
+	defer server.Close()
+	tracer := &RecordingTracer{}
+	client, err := NewAnonymousClientWithOptions(server.URL, "2.0", Options{Clock: &fakeClock{}, Tracer: tracer})
+	assert.Nil(t, err)
+	ctx, parent := tracer.Start(context.Background(), "Machines")
+
+	_, err = client.GetContext(ctx, &url.URL{Path: "machines/4y3ha3/"}, "details", nil)
+
+	assert.Nil(t, err)
+	spans := tracer.Spans()
+	assert.Len(t, spans, 3)
+	for i, span := range spans[1:] {
+		assert.Equal(t, span.Name, "GET")
+		assert.True(t, span.Ended)
+		assert.Equal(t, span.Parent, parent.SpanContext())
+		assert.Equal(t, span.Attributes[AttributePath], "machines/{system_id}/")
+		assert.Equal(t, span.Attributes[AttributeOp], "details")
+		assert.Equal(t, span.Attributes[AttributeAttempt], i+1)
+		assert.Equal(t, span.Attributes[AttributeStatusCode], codes[i])
+		assert.Equal(t, traceParents[i], span.Context.TraceParent())
+	}
+	assert.Len(t, spans[1].Errors, 1)
+	assert.Len(t, spans[2].Errors, 0)
+	assert.Nil(t, spans[1].Attributes[AttributeResendCount])
+	assert.Equal(t, spans[2].Attributes[AttributeResendCount], 1)
+}
+
+func TestNoTracerNoTraceParent(t *testing.T) {
+	server := newSingleServingServer("/api/2.0/version/", "{}", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+
+	_, err = client.Get(&url.URL{Path: "version/"}, "", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, (*server.requestHeader).Get(TraceParentHeader), "")
+}
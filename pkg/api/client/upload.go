@@ -0,0 +1,298 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// UploadFile is a named file part of a streamed multipart upload.
+type UploadFile struct {
+	// Name is the form field and file name of the part.
+	Name string
+	// Size is the length of the content. The upload fails if Open returns
+	// a different number of bytes.
+	Size int64
+	// Open returns the content of the file. It is called for every attempt
+	// to send the upload, so that retries start again from the beginning.
+	Open func() (io.ReadCloser, error)
+}
+
+// BytesUploadFile returns an UploadFile sending content.
+func BytesUploadFile(name string, content []byte) UploadFile {
+	return UploadFile{
+		Name: name,
+		Size: int64(len(content)),
+		Open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		},
+	}
+}
+
+// SeekerUploadFile returns an UploadFile sending size bytes read from
+// source, starting at its current offset. The source is rewound to that
+// offset when the upload is retried.
+func SeekerUploadFile(name string, source io.ReadSeeker, size int64) (UploadFile, error) {
+	start, err := source.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return UploadFile{}, errors.Annotate(err, "cannot get offset of upload source")
+	}
+	return UploadFile{
+		Name: name,
+		Size: size,
+		Open: func() (io.ReadCloser, error) {
+			if _, err := source.Seek(start, io.SeekStart); err != nil {
+				return nil, errors.Annotate(err, "cannot rewind upload source")
+			}
+			return ioutil.NopCloser(io.LimitReader(source, size)), nil
+		},
+	}, nil
+}
+
+// ReaderUploadFile returns an UploadFile sending size bytes read from
+// source. As the source cannot be rewound, the upload is not retried: it
+// fails with the error of the first attempt.
+func ReaderUploadFile(name string, source io.Reader, size int64) UploadFile {
+	opened := false
+	return UploadFile{
+		Name: name,
+		Size: size,
+		Open: func() (io.ReadCloser, error) {
+			if opened {
+				return nil, errors.New("cannot retry upload from a reader that cannot be rewound")
+			}
+			opened = true
+			return ioutil.NopCloser(io.LimitReader(source, size)), nil
+		},
+	}
+}
+
+// ProgressFunc is called as an upload is sent, with the number of bytes of
+// file content sent so far and the total to send. When an upload is retried
+// the count starts again from zero.
+type ProgressFunc func(sent, total int64)
+
+// Upload is a multipart request whose file parts are streamed from their
+// source rather than held in memory.
+type Upload struct {
+	Params url.Values
+	Files  []UploadFile
+	// Progress, when set, is called as file content is sent. It is called
+	// from the goroutine writing the body, not the one sending the upload,
+	// but calls never overlap, even when the upload is retried.
+	Progress ProgressFunc
+}
+
+// PostStream performs an HTTP "POST" to the API, streaming the upload as
+// a multipart body. See Post.
+func (client MAASClient) PostStream(uri *url.URL, operation string, upload Upload) ([]byte, error) {
+	return client.PostStreamContext(context.Background(), uri, operation, upload)
+}
+
+// PostStreamContext is like PostStream but the request is bound to ctx.
+func (client MAASClient) PostStreamContext(ctx context.Context, uri *url.URL, operation string, upload Upload) ([]byte, error) {
+	queryParams := url.Values{"op": {operation}}
+	uri.RawQuery = queryParams.Encode()
+	return client.streamRequest(ctx, "POST", uri, upload)
+}
+
+// streamRequest sends the upload as a multipart body that is written while
+// it is sent, rather than buffered.
+func (client MAASClient) streamRequest(ctx context.Context, method string, uri *url.URL, upload Upload) ([]byte, error) {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	length, err := upload.contentLength(boundary)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	url := client.GetURL(uri)
+	request, err := http.NewRequest(method, url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	bodies := &uploadBodies{upload: upload, boundary: boundary}
+	// dispatchRequest gets a new body for every attempt.
+	request.GetBody = bodies.next
+	request.ContentLength = length
+	request.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	defer bodies.stop()
+	return client.dispatchRequest(request.WithContext(ctx))
+}
+
+// uploadBodies hands out the body of each attempt to send an upload. Only
+// one body is written at a time: the writer of the previous attempt must
+// stop reading the sources before they are reopened, as reopening rewinds
+// them.
+type uploadBodies struct {
+	upload   Upload
+	boundary string
+
+	mu       sync.Mutex
+	previous *uploadBody
+}
+
+// next stops the writer of the previous body and returns a new one.
+func (b *uploadBodies) next() (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopPrevious()
+	body, err := b.upload.body(b.boundary)
+	if err != nil {
+		return nil, err
+	}
+	b.previous = body
+	return body, nil
+}
+
+// stop waits for the writer of the last body to finish.
+func (b *uploadBodies) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopPrevious()
+}
+
+func (b *uploadBodies) stopPrevious() {
+	if b.previous == nil {
+		return
+	}
+	// Closing the reader fails any write blocked on the pipe, so that the
+	// writer returns even if the transport stopped reading the body.
+	b.previous.Close()
+	<-b.previous.done
+	b.previous = nil
+}
+
+// uploadBody is a multipart body written by a goroutine into a pipe. done
+// is closed when the goroutine has returned.
+type uploadBody struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+// body opens the file contents and returns a reader streaming the multipart
+// body as it is written.
+func (upload Upload) body(boundary string) (*uploadBody, error) {
+	contents := make([]io.ReadCloser, len(upload.Files))
+	for i, file := range upload.Files {
+		content, err := file.Open()
+		if err != nil {
+			for _, opened := range contents[:i] {
+				opened.Close()
+			}
+			return nil, errors.Annotatef(err, "cannot open %q", file.Name)
+		}
+		contents[i] = content
+	}
+	reader, writer := io.Pipe()
+	body := &uploadBody{PipeReader: reader, done: make(chan struct{})}
+	go func() {
+		defer close(body.done)
+		err := upload.write(writer, boundary, contents)
+		for _, content := range contents {
+			content.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	return body, nil
+}
+
+func (upload Upload) write(w io.Writer, boundary string, contents []io.ReadCloser) error {
+	var total, sent int64
+	for _, file := range upload.Files {
+		total += file.Size
+	}
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return errors.Trace(err)
+	}
+	for i, file := range upload.Files {
+		part, err := writer.CreateFormFile(file.Name, file.Name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		progress := &progressWriter{Writer: part, sent: &sent, total: total, report: upload.Progress}
+		n, err := io.Copy(progress, contents[i])
+		if err != nil {
+			return errors.Annotatef(err, "cannot send %q", file.Name)
+		}
+		if n != file.Size {
+			return errors.Errorf("%q is %d bytes long, expected %d", file.Name, n, file.Size)
+		}
+	}
+	if err := writeMultiPartParams(writer, upload.Params); err != nil {
+		return errors.Trace(err)
+	}
+	return writer.Close()
+}
+
+// contentLength returns the length of the multipart body, computed by
+// writing it without file content.
+func (upload Upload) contentLength(boundary string) (int64, error) {
+	var counter countingWriter
+	writer := multipart.NewWriter(&counter)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return 0, errors.Trace(err)
+	}
+	var length int64
+	for _, file := range upload.Files {
+		if file.Size < 0 {
+			return 0, errors.NotValidf("negative size of %q", file.Name)
+		}
+		if _, err := writer.CreateFormFile(file.Name, file.Name); err != nil {
+			return 0, errors.Trace(err)
+		}
+		length += file.Size
+	}
+	if err := writeMultiPartParams(writer, upload.Params); err != nil {
+		return 0, errors.Trace(err)
+	}
+	if err := writer.Close(); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return length + counter.n, nil
+}
+
+func randomBoundary() (string, error) {
+	var buf [30]byte
+	if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", buf[:]), nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// progressWriter reports the bytes written through it.
+type progressWriter struct {
+	io.Writer
+	sent   *int64
+	total  int64
+	report ProgressFunc
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	*w.sent += int64(n)
+	if w.report != nil && n > 0 {
+		w.report(*w.sent, w.total)
+	}
+	return n, err
+}
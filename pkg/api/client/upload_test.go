@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type uploadedRequest struct {
+	contentLength int64
+	received      int64
+	files         map[string]string
+	params        url.Values
+	// err is the error reading the body, when the client gave up on the
+	// upload.
+	err error
+}
+
+// newUploadServer returns a server recording the multipart uploads it
+// receives, answering them with the given statuses and then 200. The uploads
+// the client gave up on are recorded with the error reading them.
+func newUploadServer(t *testing.T, codes ...int) (*httptest.Server, *[]uploadedRequest) {
+	var uploads []uploadedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		upload := uploadedRequest{
+			contentLength: request.ContentLength,
+			received:      int64(len(body)),
+			files:         make(map[string]string),
+			err:           err,
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err := request.ParseMultipartForm(1 << 20); err == nil {
+			upload.params = request.MultipartForm.Value
+			for name, headers := range request.MultipartForm.File {
+				file, err := headers[0].Open()
+				assert.Nil(t, err)
+				content, err := ioutil.ReadAll(file)
+				assert.Nil(t, err)
+				upload.files[name] = string(content)
+			}
+		}
+		uploads = append(uploads, upload)
+		if len(uploads) <= len(codes) {
+			writer.WriteHeader(codes[len(uploads)-1])
+		}
+	}))
+	return server, &uploads
+}
+
+func TestPostStreamSendsFilesAndParams(t *testing.T) {
+	server, uploads := newUploadServer(t)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+	seeker, err := SeekerUploadFile("second", strings.NewReader("second content"), 6)
+	assert.Nil(t, err)
+
+	_, err = client.PostStream(&url.URL{Path: "files/"}, "", Upload{
+		Params: url.Values{"filename": {"image"}},
+		Files:  []UploadFile{BytesUploadFile("first", []byte("first content")), seeker},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, *uploads, 1)
+	upload := (*uploads)[0]
+	assert.Nil(t, upload.err)
+	assert.Equal(t, upload.contentLength, upload.received)
+	assert.Equal(t, upload.files, map[string]string{"first": "first content", "second": "second"})
+	assert.Equal(t, upload.params.Get("filename"), "image")
+}
+
+func TestPostStreamReportsProgress(t *testing.T) {
+	server, _ := newUploadServer(t)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+	content := bytes.Repeat([]byte("x"), 100000)
+	var reports int
+	var sent, total int64
+
+	_, err = client.PostStream(&url.URL{Path: "files/"}, "", Upload{
+		Files: []UploadFile{BytesUploadFile("file", content)},
+		Progress: func(s, t int64) {
+			reports++
+			sent, total = s, t
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.True(t, reports > 0)
+	assert.EqualValues(t, sent, len(content))
+	assert.EqualValues(t, total, len(content))
+}
+
+func TestPostStreamRewindsSeekerOnRetry(t *testing.T) {
+	server, uploads := newUploadServer(t, http.StatusBadGateway)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+	client.RetryPolicy = &BackoffPolicy{BaseDelay: time.Millisecond}
+	source := strings.NewReader("skipped:content")
+	_, err = source.Seek(8, 0)
+	assert.Nil(t, err)
+	file, err := SeekerUploadFile("file", source, 7)
+	assert.Nil(t, err)
+
+	_, err = client.PostStream(&url.URL{Path: "files/"}, "set_owner_data", Upload{Files: []UploadFile{file}})
+
+	assert.Nil(t, err)
+	assert.Len(t, *uploads, 2)
+	for _, upload := range *uploads {
+		assert.Nil(t, upload.err)
+		assert.Equal(t, upload.files["file"], "content")
+	}
+}
+
+// overlapSeeker is a ReadSeeker recording whether it is used by two
+// goroutines at the same time.
+type overlapSeeker struct {
+	io.ReadSeeker
+	active  int32
+	overlap int32
+}
+
+func (s *overlapSeeker) enter() func() {
+	if atomic.AddInt32(&s.active, 1) != 1 {
+		atomic.StoreInt32(&s.overlap, 1)
+	}
+	return func() { atomic.AddInt32(&s.active, -1) }
+}
+
+func (s *overlapSeeker) Read(p []byte) (int, error) {
+	defer s.enter()()
+	time.Sleep(time.Millisecond)
+	return s.ReadSeeker.Read(p)
+}
+
+func (s *overlapSeeker) Seek(offset int64, whence int) (int64, error) {
+	defer s.enter()()
+	return s.ReadSeeker.Seek(offset, whence)
+}
+
+func TestPostStreamRetryWaitsForPreviousWriter(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<14)
+	var attempts int32
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			// Fail without reading the body, so that the client is still
+			// writing it when the retry starts.
+			writer.Header().Set("Connection", "close")
+			writer.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if err := request.ParseMultipartForm(1 << 20); !assert.Nil(t, err) {
+			return
+		}
+		file, err := request.MultipartForm.File["file"][0].Open()
+		assert.Nil(t, err)
+		received, err = ioutil.ReadAll(file)
+		assert.Nil(t, err)
+	}))
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+	client.RetryPolicy = &BackoffPolicy{BaseDelay: time.Millisecond}
+	source := &overlapSeeker{ReadSeeker: bytes.NewReader(content)}
+	file, err := SeekerUploadFile("file", source, int64(len(content)))
+	assert.Nil(t, err)
+
+	_, err = client.PostStream(&url.URL{Path: "files/"}, "set_owner_data", Upload{Files: []UploadFile{file}})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, atomic.LoadInt32(&attempts), 2)
+	assert.EqualValues(t, atomic.LoadInt32(&source.overlap), 0)
+	assert.True(t, bytes.Equal(received, content))
+}
+
+func TestPostStreamReaderIsNotRetried(t *testing.T) {
+	server, uploads := newUploadServer(t, http.StatusBadGateway)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+	client.RetryPolicy = &BackoffPolicy{BaseDelay: time.Millisecond}
+	file := ReaderUploadFile("file", strings.NewReader("content"), 7)
+
+	_, err = client.PostStream(&url.URL{Path: "files/"}, "set_owner_data", Upload{Files: []UploadFile{file}})
+
+	svrErr, ok := errors.Cause(err).(ServerError)
+	assert.True(t, ok, "%v", err)
+	assert.Equal(t, svrErr.StatusCode, http.StatusBadGateway)
+	assert.Len(t, *uploads, 1)
+}
+
+func TestPostStreamShortFile(t *testing.T) {
+	server, uploads := newUploadServer(t)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "2.0")
+	assert.Nil(t, err)
+	file := ReaderUploadFile("file", strings.NewReader("short"), 10)
+
+	_, err = client.PostStream(&url.URL{Path: "files/"}, "", Upload{Files: []UploadFile{file}})
+
+	assert.NotNil(t, err)
+	// Wait for the server to be done with the upload the client gave up on.
+	server.Close()
+	for _, upload := range *uploads {
+		assert.NotNil(t, upload.err)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 
@@ -11,12 +12,32 @@ import (
 	"github.com/juju/version"
 )
 
+// MAAS is a connection to a maas server, independent of the API version
+// it speaks. Its typed operations are dispatched to the implementation for
+// that version.
 type MAAS struct {
 	controller client.ControllerInterface
+	services   services
 	major      int
 }
 
 func NewMASS(baseURL string, apiVersion string, apiKey string) (*MAAS, error) {
+	return NewMASSContext(context.Background(), baseURL, apiVersion, apiKey)
+}
+
+// NewMASSContext is like NewMASS but the version and credential checks made
+// against the server are bound to ctx.
+func NewMASSContext(ctx context.Context, baseURL string, apiVersion string, apiKey string) (*MAAS, error) {
+	return newMAAS(ctx, baseURL, apiVersion, apiKey, client.Options{})
+}
+
+// NewMASSWithOptions is like NewMASS but talks to the server with the HTTP
+// client described by opts.
+func NewMASSWithOptions(baseURL string, apiVersion string, apiKey string, opts client.Options) (*MAAS, error) {
+	return newMAAS(context.Background(), baseURL, apiVersion, apiKey, opts)
+}
+
+func newMAAS(ctx context.Context, baseURL string, apiVersion string, apiKey string, opts client.Options) (*MAAS, error) {
 	if apiVersion == "" {
 		return nil, fmt.Errorf("api version must not be empty")
 	}
@@ -27,11 +48,16 @@ func NewMASS(baseURL string, apiVersion string, apiKey string) (*MAAS, error) {
 
 	switch major {
 	case 2:
-		c, err := v2.NewControllerWithVersion(baseURL, apiVersion, apiKey)
+		c, err := v2.NewControllerContext(ctx, v2.ControllerArgs{
+			BaseURL:       baseURL,
+			APIKey:        apiKey,
+			APIVersion:    apiVersion,
+			ClientOptions: opts,
+		})
 		if err != nil {
 			return nil, err
 		}
-		return &MAAS{controller: c, major: major}, nil
+		return &MAAS{controller: c, services: c, major: major}, nil
 
 	default:
 		return nil, util.NewUnsupportedVersionError("version is not supported: %s", apiVersion)
@@ -50,6 +76,10 @@ func (m *MAAS) PostFile(path string, op string, params url.Values, fc []byte) ([
 	return m.controller.PostFile(path, op, params, fc)
 }
 
+func (m *MAAS) PostStream(path string, op string, upload client.Upload) ([]byte, error) {
+	return m.controller.PostStream(path, op, upload)
+}
+
 func (m *MAAS) Put(path string, params url.Values) ([]byte, error) {
 	return m.controller.Put(path, params)
 }
@@ -61,3 +91,31 @@ func (m *MAAS) Delete(path string) error {
 func (m *MAAS) GetAPIVersionInfo() (set.Strings, error) {
 	return m.controller.GetAPIVersionInfo()
 }
+
+func (m *MAAS) GetContext(ctx context.Context, path string, op string, params url.Values) ([]byte, error) {
+	return m.controller.GetContext(ctx, path, op, params)
+}
+
+func (m *MAAS) PostContext(ctx context.Context, path string, op string, params url.Values) ([]byte, error) {
+	return m.controller.PostContext(ctx, path, op, params)
+}
+
+func (m *MAAS) PostFileContext(ctx context.Context, path string, op string, params url.Values, fc []byte) ([]byte, error) {
+	return m.controller.PostFileContext(ctx, path, op, params, fc)
+}
+
+func (m *MAAS) PostStreamContext(ctx context.Context, path string, op string, upload client.Upload) ([]byte, error) {
+	return m.controller.PostStreamContext(ctx, path, op, upload)
+}
+
+func (m *MAAS) PutContext(ctx context.Context, path string, params url.Values) ([]byte, error) {
+	return m.controller.PutContext(ctx, path, params)
+}
+
+func (m *MAAS) DeleteContext(ctx context.Context, path string) error {
+	return m.controller.DeleteContext(ctx, path)
+}
+
+func (m *MAAS) GetAPIVersionInfoContext(ctx context.Context) (set.Strings, error) {
+	return m.controller.GetAPIVersionInfoContext(ctx)
+}
@@ -1 +1,183 @@
 package api
+
+import (
+	"context"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+// services are the typed operations a version-specific controller provides
+// to MAAS.
+type services interface {
+	MachinesContext(ctx context.Context, args MachinesArgs) ([]Machine, error)
+	AllocateMachineContext(ctx context.Context, args AllocateMachineArgs) (*Machine, ConstraintMatches, error)
+	DeployContext(ctx context.Context, m *Machine, args DeployMachineArgs) error
+	ReleaseMachinesContext(ctx context.Context, args ReleaseMachinesArgs) error
+	WaitForStatus(ctx context.Context, systemID string, targets []NodeStatus, opts WaitOptions) (*Machine, error)
+
+	GetFileContext(ctx context.Context, filename string) (*File, error)
+	ReadFileContentContext(ctx context.Context, f *File) ([]byte, error)
+	DownloadFileContext(ctx context.Context, args DownloadFileArgs) (int64, error)
+	AddFileContext(ctx context.Context, args AddFileArgs) error
+
+	FabricsContext(ctx context.Context) ([]Fabric, error)
+	SpacesContext(ctx context.Context) ([]Space, error)
+	StaticRoutesContext(ctx context.Context) ([]StaticRoute, error)
+	ZonesContext(ctx context.Context) ([]Zone, error)
+}
+
+// Machines returns the machines matching args.
+func (m *MAAS) Machines(args MachinesArgs) ([]Machine, error) {
+	return m.MachinesContext(context.Background(), args)
+}
+
+// MachinesContext is like Machines but the request is bound to ctx.
+func (m *MAAS) MachinesContext(ctx context.Context, args MachinesArgs) ([]Machine, error) {
+	return m.services.MachinesContext(ctx, args)
+}
+
+// Machine returns the machine with the given system ID.
+// Returns
+//   - NoMatchError if there is no such machine
+func (m *MAAS) Machine(systemID string) (*Machine, error) {
+	return m.MachineContext(context.Background(), systemID)
+}
+
+// MachineContext is like Machine but the request is bound to ctx.
+func (m *MAAS) MachineContext(ctx context.Context, systemID string) (*Machine, error) {
+	if systemID == "" {
+		return nil, errors.NotValidf("missing system ID")
+	}
+	machines, err := m.services.MachinesContext(ctx, MachinesArgs{SystemIDs: []string{systemID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(machines) == 0 {
+		return nil, util.NewNoMatchError("no machine with system ID " + systemID)
+	}
+	return &machines[0], nil
+}
+
+// AllocateMachine allocates a machine matching args, and returns it with
+// the parts of it matching the interface and storage constraints.
+func (m *MAAS) AllocateMachine(args AllocateMachineArgs) (*Machine, ConstraintMatches, error) {
+	return m.AllocateMachineContext(context.Background(), args)
+}
+
+// AllocateMachineContext is like AllocateMachine but the request is bound to
+// ctx.
+func (m *MAAS) AllocateMachineContext(ctx context.Context, args AllocateMachineArgs) (*Machine, ConstraintMatches, error) {
+	return m.services.AllocateMachineContext(ctx, args)
+}
+
+// Deploy deploys an allocated machine, and updates it with its new state.
+func (m *MAAS) Deploy(machine *Machine, args DeployMachineArgs) error {
+	return m.DeployContext(context.Background(), machine, args)
+}
+
+// DeployContext is like Deploy but the request is bound to ctx.
+func (m *MAAS) DeployContext(ctx context.Context, machine *Machine, args DeployMachineArgs) error {
+	return m.services.DeployContext(ctx, machine, args)
+}
+
+// ReleaseMachines releases the machines of args back to the pool.
+func (m *MAAS) ReleaseMachines(args ReleaseMachinesArgs) error {
+	return m.ReleaseMachinesContext(context.Background(), args)
+}
+
+// ReleaseMachinesContext is like ReleaseMachines but the request is bound to
+// ctx.
+func (m *MAAS) ReleaseMachinesContext(ctx context.Context, args ReleaseMachinesArgs) error {
+	return m.services.ReleaseMachinesContext(ctx, args)
+}
+
+// WaitForStatus polls the machine with the given system ID until it is in
+// one of the targets statuses, and returns it. It returns early when the
+// machine reaches a failed status that is not one of the targets.
+func (m *MAAS) WaitForStatus(ctx context.Context, systemID string, targets []NodeStatus, opts WaitOptions) (*Machine, error) {
+	return m.services.WaitForStatus(ctx, systemID, targets, opts)
+}
+
+// GetFile returns the file with the given name.
+func (m *MAAS) GetFile(filename string) (*File, error) {
+	return m.GetFileContext(context.Background(), filename)
+}
+
+// GetFileContext is like GetFile but the request is bound to ctx.
+func (m *MAAS) GetFileContext(ctx context.Context, filename string) (*File, error) {
+	return m.services.GetFileContext(ctx, filename)
+}
+
+// ReadFileContent returns the content of a file.
+func (m *MAAS) ReadFileContent(f *File) ([]byte, error) {
+	return m.ReadFileContentContext(context.Background(), f)
+}
+
+// ReadFileContentContext is like ReadFileContent but the request is bound to
+// ctx.
+func (m *MAAS) ReadFileContentContext(ctx context.Context, f *File) ([]byte, error) {
+	return m.services.ReadFileContentContext(ctx, f)
+}
+
+// DownloadFile streams the content of a file to args.Writer, and returns the
+// number of bytes written.
+func (m *MAAS) DownloadFile(args DownloadFileArgs) (int64, error) {
+	return m.DownloadFileContext(context.Background(), args)
+}
+
+// DownloadFileContext is like DownloadFile but the requests are bound to
+// ctx.
+func (m *MAAS) DownloadFileContext(ctx context.Context, args DownloadFileArgs) (int64, error) {
+	return m.services.DownloadFileContext(ctx, args)
+}
+
+// AddFile uploads a file.
+func (m *MAAS) AddFile(args AddFileArgs) error {
+	return m.AddFileContext(context.Background(), args)
+}
+
+// AddFileContext is like AddFile but the request is bound to ctx.
+func (m *MAAS) AddFileContext(ctx context.Context, args AddFileArgs) error {
+	return m.services.AddFileContext(ctx, args)
+}
+
+// Fabrics returns the fabrics of the maas.
+func (m *MAAS) Fabrics() ([]Fabric, error) {
+	return m.FabricsContext(context.Background())
+}
+
+// FabricsContext is like Fabrics but the request is bound to ctx.
+func (m *MAAS) FabricsContext(ctx context.Context) ([]Fabric, error) {
+	return m.services.FabricsContext(ctx)
+}
+
+// Spaces returns the spaces of the maas.
+func (m *MAAS) Spaces() ([]Space, error) {
+	return m.SpacesContext(context.Background())
+}
+
+// SpacesContext is like Spaces but the request is bound to ctx.
+func (m *MAAS) SpacesContext(ctx context.Context) ([]Space, error) {
+	return m.services.SpacesContext(ctx)
+}
+
+// StaticRoutes returns the static routes of the maas.
+func (m *MAAS) StaticRoutes() ([]StaticRoute, error) {
+	return m.StaticRoutesContext(context.Background())
+}
+
+// StaticRoutesContext is like StaticRoutes but the request is bound to ctx.
+func (m *MAAS) StaticRoutesContext(ctx context.Context) ([]StaticRoute, error) {
+	return m.services.StaticRoutesContext(ctx)
+}
+
+// Zones returns the availability zones of the maas.
+func (m *MAAS) Zones() ([]Zone, error) {
+	return m.ZonesContext(context.Background())
+}
+
+// ZonesContext is like Zones but the request is bound to ctx.
+func (m *MAAS) ZonesContext(ctx context.Context) ([]Zone, error) {
+	return m.services.ZonesContext(ctx)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/maastest"
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServerAndMAAS(t *testing.T) (*maastest.Server, *MAAS) {
+	server := maastest.NewServer()
+	server.APIKey = "fake:as:key"
+	server.Start()
+	m, err := NewMASS(server.URL+"/MAAS/", "2.0", server.APIKey)
+	assert.Nil(t, err)
+	return server, m
+}
+
+func TestMAASMachineLifecycle(t *testing.T) {
+	server, m := newServerAndMAAS(t)
+	defer server.Close()
+	systemID := server.AddMachine(maastest.Machine{Hostname: "quiet-fox"})
+
+	machines, err := m.Machines(MachinesArgs{Hostnames: []string{"quiet-fox"}})
+	assert.Nil(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, machines[0].SystemID, systemID)
+
+	machine, _, err := m.AllocateMachine(AllocateMachineArgs{Hostname: "quiet-fox"})
+	assert.Nil(t, err)
+	assert.Equal(t, machine.SystemID, systemID)
+	assert.Nil(t, m.Deploy(machine, DeployMachineArgs{DistroSeries: "bionic"}))
+	assert.Equal(t, machine.StatusName, "Deploying")
+
+	assert.Nil(t, m.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{systemID}}))
+	machine, err = m.Machine(systemID)
+	assert.Nil(t, err)
+	assert.Equal(t, machine.StatusName, "Ready")
+}
+
+func TestMAASMachineNotFound(t *testing.T) {
+	server, m := newServerAndMAAS(t)
+	defer server.Close()
+
+	_, err := m.Machine("4y3ha3")
+
+	assert.True(t, util.IsNoMatchError(err), "%v", err)
+}
+
+func TestMAASFiles(t *testing.T) {
+	server, m := newServerAndMAAS(t)
+	defer server.Close()
+
+	err := m.AddFile(AddFileArgs{Filename: "myfile", Content: []byte("content")})
+	assert.Nil(t, err)
+	file, err := m.GetFile("myfile")
+	assert.Nil(t, err)
+	content, err := m.ReadFileContent(file)
+
+	assert.Nil(t, err)
+	assert.Equal(t, string(content), "content")
+}
+
+func TestMAASNetworking(t *testing.T) {
+	server, m := newServerAndMAAS(t)
+	defer server.Close()
+	server.AddZone("rack-2", "second rack")
+	server.AddFabric("fabric-1")
+
+	zones, err := m.Zones()
+	assert.Nil(t, err)
+	assert.Len(t, zones, 2)
+	fabrics, err := m.Fabrics()
+	assert.Nil(t, err)
+	assert.Len(t, fabrics, 1)
+	assert.Equal(t, fabrics[0].Name, "fabric-1")
+}
+
+// serverClock is a Clock whose waits advance the clock of a maastest
+// server, so that the transitions of its machines complete without sleeping.
+type serverClock struct {
+	server *maastest.Server
+}
+
+func (c serverClock) Now() time.Time {
+	return c.server.Now()
+}
+
+func (c serverClock) After(d time.Duration) <-chan time.Time {
+	c.server.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.server.Now()
+	return ch
+}
+
+func TestMAASWaitForStatus(t *testing.T) {
+	server, m := newServerAndMAAS(t)
+	defer server.Close()
+	deployed := server.AddMachine(maastest.Machine{Hostname: "deployed"})
+	failed := server.AddMachine(maastest.Machine{Hostname: "failed"})
+	server.FailDeployment(failed)
+	opts := WaitOptions{Clock: serverClock{server}}
+	for _, hostname := range []string{"deployed", "failed"} {
+		machine, _, err := m.AllocateMachine(AllocateMachineArgs{Hostname: hostname})
+		assert.Nil(t, err)
+		assert.Nil(t, m.Deploy(machine, DeployMachineArgs{}))
+	}
+
+	machine, err := m.WaitForStatus(context.Background(), deployed, []NodeStatus{NodeStatusDeployed}, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, machine.Status, NodeStatusDeployed)
+
+	_, err = m.WaitForStatus(context.Background(), failed, []NodeStatus{NodeStatusDeployed}, opts)
+	assert.True(t, IsFailedStatusError(err), "%v", err)
+}
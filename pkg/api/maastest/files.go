@@ -0,0 +1,141 @@
+package maastest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type file struct {
+	name    string
+	content []byte
+	// key is used to download the file anonymously.
+	key string
+}
+
+// AddFile adds a file to the server's file store, replacing any file with
+// the same name.
+func (s *Server) AddFile(name string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addFile(name, content)
+}
+
+// File returns the content of the named file of the store.
+func (s *Server) File(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), f.content...), true
+}
+
+func (s *Server) addFile(name string, content []byte) {
+	s.files[name] = &file{name: name, content: content, key: fmt.Sprintf("key-%d", s.id())}
+}
+
+func (s *Server) serveFiles(writer http.ResponseWriter, request *apiRequest) {
+	if len(request.segments) > 1 {
+		name := strings.Join(request.segments[1:], "/")
+		f, ok := s.files[name]
+		switch {
+		case !ok:
+			http.NotFound(writer, request.Request)
+		case request.Method == "GET" && request.op == "":
+			writeJSON(writer, http.StatusOK, s.renderFile(request, f, true))
+		case request.Method == "DELETE":
+			delete(s.files, name)
+			writer.WriteHeader(http.StatusNoContent)
+		default:
+			badOperation(writer, request)
+		}
+		return
+	}
+	switch {
+	case request.Method == "GET" && request.op == "":
+		prefix := request.param("prefix")
+		var names []string
+		for name := range s.files {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		files := []interface{}{}
+		for _, name := range names {
+			files = append(files, s.renderFile(request, s.files[name], false))
+		}
+		writeJSON(writer, http.StatusOK, files)
+	case request.Method == "GET" && request.op == "get":
+		f, ok := s.files[request.param("filename")]
+		if !ok {
+			http.NotFound(writer, request.Request)
+			return
+		}
+		serveContent(writer, request, f)
+	case request.Method == "GET" && request.op == "get_by_key":
+		for _, f := range s.files {
+			if f.key == request.param("key") {
+				serveContent(writer, request, f)
+				return
+			}
+		}
+		http.NotFound(writer, request.Request)
+	case request.Method == "POST" && (request.op == "" || request.op == "add"):
+		s.uploadFile(writer, request)
+	default:
+		badOperation(writer, request)
+	}
+}
+
+func (s *Server) uploadFile(writer http.ResponseWriter, request *apiRequest) {
+	name := request.param("filename")
+	if name == "" {
+		http.Error(writer, "filename: This field is required.", http.StatusBadRequest)
+		return
+	}
+	if request.MultipartForm == nil || len(request.MultipartForm.File["file"]) != 1 {
+		http.Error(writer, "Exactly one file must be supplied.", http.StatusBadRequest)
+		return
+	}
+	part, err := request.MultipartForm.File["file"][0].Open()
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer part.Close()
+	content, err := ioutil.ReadAll(part)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.addFile(name, content)
+	writeJSON(writer, http.StatusCreated, s.renderFile(request, s.files[name], false))
+}
+
+// serveContent writes the content of f, honouring range requests so that
+// interrupted downloads can be resumed.
+func serveContent(writer http.ResponseWriter, request *apiRequest, f *file) {
+	writer.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(writer, request.Request, "", time.Time{}, bytes.NewReader(f.content))
+}
+
+func (s *Server) renderFile(request *apiRequest, f *file, withContent bool) map[string]string {
+	rendered := map[string]string{
+		"filename":          f.name,
+		"resource_uri":      request.root + "files/" + f.name + "/",
+		"anon_resource_uri": request.root + "files/?" + url.Values{"op": {"get_by_key"}, "key": {f.key}}.Encode(),
+	}
+	if withContent {
+		rendered["content"] = base64.StdEncoding.EncodeToString(f.content)
+	}
+	return rendered
+}
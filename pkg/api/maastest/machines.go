@@ -0,0 +1,531 @@
+package maastest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	v2 "github.com/alejandroEsc/golang-maas-client/pkg/api/v2"
+)
+
+// DefaultDistroSeries is deployed when the deploy request doesn't name one.
+const DefaultDistroSeries = "bionic"
+
+// Machine is a machine of a Server.
+type Machine struct {
+	// SystemID is allocated by AddMachine when empty.
+	SystemID string
+	// Hostname defaults to node-<n>.
+	Hostname string
+	// Architecture defaults to amd64/generic.
+	Architecture string
+	CPUCount     int
+	// Memory is in MiB.
+	Memory int
+	Tags   []string
+	// Zone defaults to "default".
+	Zone         string
+	MACAddresses []string
+	// Status is one of the v2.NodeStatus values, and defaults to
+	// v2.NodeStatusReady.
+	Status v2.NodeStatus
+	// PowerState defaults to "off".
+	PowerState      string
+	Owner           string
+	AgentName       string
+	OwnerData       map[string]string
+	OperatingSystem string
+	DistroSeries    string
+	Kernel          string
+}
+
+// machine is the state of a Machine.
+type machine struct {
+	Machine
+	// pending is the transition in progress, if any.
+	pending *transition
+	// failDeployment makes the next deployment fail.
+	failDeployment bool
+}
+
+// transition is a status change completing at a given time.
+type transition struct {
+	at         time.Time
+	status     v2.NodeStatus
+	powerState string
+}
+
+// AddMachine adds a machine to the server, and returns its system ID.
+func (s *Server) AddMachine(m Machine) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.id()
+	if m.SystemID == "" {
+		m.SystemID = fmt.Sprintf("m%05x", id)
+	}
+	if m.Hostname == "" {
+		m.Hostname = fmt.Sprintf("node-%d", id)
+	}
+	if m.Architecture == "" {
+		m.Architecture = "amd64/generic"
+	}
+	if m.Zone == "" {
+		m.Zone = "default"
+	}
+	if m.Status == "" {
+		m.Status = v2.NodeStatusReady
+	}
+	if m.PowerState == "" {
+		m.PowerState = "off"
+	}
+	s.machines = append(s.machines, &machine{Machine: m.copy()})
+	return m.SystemID
+}
+
+// Machine returns the current state of the machine with the given system
+// ID.
+func (s *Server) Machine(systemID string) (Machine, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.machine(systemID)
+	if m == nil {
+		return Machine{}, false
+	}
+	return m.Machine.copy(), true
+}
+
+// FailDeployment makes the next deployment of the machine end in
+// v2.NodeStatusFailedDeployment instead of v2.NodeStatusDeployed.
+func (s *Server) FailDeployment(systemID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m := s.machine(systemID); m != nil {
+		m.failDeployment = true
+	}
+}
+
+func (m Machine) copy() Machine {
+	m.Tags = append([]string(nil), m.Tags...)
+	m.MACAddresses = append([]string(nil), m.MACAddresses...)
+	ownerData := make(map[string]string, len(m.OwnerData))
+	for key, value := range m.OwnerData {
+		ownerData[key] = value
+	}
+	m.OwnerData = ownerData
+	return m
+}
+
+func (s *Server) machine(systemID string) *machine {
+	for _, m := range s.machines {
+		if m.SystemID == systemID {
+			return m
+		}
+	}
+	return nil
+}
+
+// settle completes the transitions due by now.
+func (s *Server) settle() {
+	for _, m := range s.machines {
+		if m.pending != nil && !m.pending.at.After(s.now) {
+			m.Status = m.pending.status
+			m.PowerState = m.pending.powerState
+			m.pending = nil
+		}
+	}
+}
+
+// start puts the machine in status until duration has passed, when it moves
+// to then with the given power state.
+func (s *Server) start(m *machine, status v2.NodeStatus, duration time.Duration, then v2.NodeStatus, powerState string) {
+	m.Status = status
+	m.pending = &transition{at: s.now.Add(duration), status: then, powerState: powerState}
+}
+
+func (s *Server) serveMachines(writer http.ResponseWriter, request *apiRequest) {
+	if len(request.segments) == 1 {
+		switch {
+		case request.Method == "GET" && request.op == "":
+			s.listMachines(writer, request)
+		case request.Method == "POST" && request.op == "allocate":
+			s.allocate(writer, request)
+		case request.Method == "POST" && request.op == "release":
+			s.releaseMachines(writer, request)
+		default:
+			badOperation(writer, request)
+		}
+		return
+	}
+	m := s.machine(request.segments[1])
+	if m == nil || len(request.segments) > 2 {
+		http.NotFound(writer, request.Request)
+		return
+	}
+	switch request.Method {
+	case "GET":
+		if request.op != "" {
+			badOperation(writer, request)
+			return
+		}
+		writeJSON(writer, http.StatusOK, s.renderMachine(request, m))
+		return
+	case "DELETE":
+		s.deleteMachine(m)
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	case "POST":
+	default:
+		methodNotAllowed(writer)
+		return
+	}
+	var err *opError
+	switch request.op {
+	case "deploy":
+		err = s.deploy(m, request)
+	case "release":
+		err = s.release(m, request)
+	case "commission":
+		err = s.commission(m)
+	case "abort":
+		err = s.abort(m)
+	case "mark_broken":
+		m.Status = v2.NodeStatusBroken
+		m.pending = nil
+	case "mark_fixed":
+		err = s.markFixed(m)
+	case "power_on":
+		m.PowerState = "on"
+	case "power_off":
+		m.PowerState = "off"
+	case "set_owner_data":
+		s.setOwnerData(m, request)
+	default:
+		badOperation(writer, request)
+		return
+	}
+	if err != nil {
+		http.Error(writer, err.message, err.code)
+		return
+	}
+	writeJSON(writer, http.StatusOK, s.renderMachine(request, m))
+}
+
+// opError is an operation failing with an HTTP error.
+type opError struct {
+	code    int
+	message string
+}
+
+func conflict(m *machine, action string) *opError {
+	return &opError{
+		code:    http.StatusConflict,
+		message: fmt.Sprintf("%s: Cannot %s node because the node is in %s state.", m.Hostname, action, m.Status),
+	}
+}
+
+func (s *Server) listMachines(writer http.ResponseWriter, request *apiRequest) {
+	filters := map[string]func(m *machine) string{
+		"hostname":   func(m *machine) string { return m.Hostname },
+		"id":         func(m *machine) string { return m.SystemID },
+		"zone":       func(m *machine) string { return m.Zone },
+		"agent_name": func(m *machine) string { return m.AgentName },
+	}
+	result := []interface{}{}
+	for _, m := range s.machines {
+		matches := true
+		for name, field := range filters {
+			if values := request.params(name); len(values) > 0 && !contains(values, field(m)) {
+				matches = false
+			}
+		}
+		if macs := request.params("mac_address"); len(macs) > 0 && !containsAny(macs, m.MACAddresses) {
+			matches = false
+		}
+		if matches {
+			result = append(result, s.renderMachine(request, m))
+		}
+	}
+	writeJSON(writer, http.StatusOK, result)
+}
+
+// allocate allocates the first ready machine matching the constraints.
+// Storage, interface and subnet constraints are not supported.
+func (s *Server) allocate(writer http.ResponseWriter, request *apiRequest) {
+	for _, name := range []string{"storage", "interfaces", "not_subnets", "subnets"} {
+		if request.param(name) != "" {
+			http.Error(writer, fmt.Sprintf("maastest does not support the %s constraint", name), http.StatusBadRequest)
+			return
+		}
+	}
+	var minCPU, minMemory int
+	for name, value := range map[string]*int{"cpu_count": &minCPU, "mem": &minMemory} {
+		if param := request.param(name); param != "" {
+			parsed, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				http.Error(writer, fmt.Sprintf("invalid %s: %q", name, param), http.StatusBadRequest)
+				return
+			}
+			*value = int(parsed)
+		}
+	}
+	var tags, notTags []string
+	for _, value := range request.params("tags") {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	for _, value := range request.params("not_tags") {
+		notTags = append(notTags, strings.Split(value, ",")...)
+	}
+	name := request.param("name")
+	if name == "" {
+		name = request.param("hostname")
+	}
+	systemID := request.param("system_id")
+	arch := request.param("arch")
+	zone := request.param("zone")
+	notInZone := request.params("not_in_zone")
+
+	for _, m := range s.machines {
+		switch {
+		case m.Status != v2.NodeStatusReady,
+			name != "" && m.Hostname != name && !strings.HasPrefix(m.Hostname, name+"."),
+			systemID != "" && m.SystemID != systemID,
+			arch != "" && m.Architecture != arch && !strings.HasPrefix(m.Architecture, arch+"/"),
+			m.CPUCount < minCPU,
+			m.Memory < minMemory,
+			!containsAll(m.Tags, tags),
+			containsAny(notTags, m.Tags),
+			zone != "" && m.Zone != zone,
+			contains(notInZone, m.Zone):
+			continue
+		}
+		if request.param("dry_run") != "true" {
+			m.Status = v2.NodeStatusAllocated
+			m.Owner = s.User
+			m.AgentName = request.param("agent_name")
+		}
+		rendered := s.renderMachine(request, m)
+		rendered["constraints_by_type"] = map[string]interface{}{}
+		writeJSON(writer, http.StatusOK, rendered)
+		return
+	}
+	http.Error(writer, "No available machine matches constraints", http.StatusConflict)
+}
+
+// releasable reports whether a machine in status can be released.
+func releasable(status v2.NodeStatus) bool {
+	switch status {
+	case v2.NodeStatusAllocated, v2.NodeStatusDeploying, v2.NodeStatusDeployed,
+		v2.NodeStatusFailedDeployment, v2.NodeStatusFailedReleasing, v2.NodeStatusFailedDiskErasing:
+		return true
+	}
+	return false
+}
+
+func (s *Server) releaseMachines(writer http.ResponseWriter, request *apiRequest) {
+	var unknown []string
+	var machines []*machine
+	for _, systemID := range request.params("machines") {
+		m := s.machine(systemID)
+		if m == nil {
+			unknown = append(unknown, systemID)
+			continue
+		}
+		machines = append(machines, m)
+	}
+	if len(unknown) > 0 {
+		http.Error(writer, "Unknown machine(s): "+strings.Join(unknown, ", "), http.StatusBadRequest)
+		return
+	}
+	for _, m := range machines {
+		if !releasable(m.Status) && m.Status != v2.NodeStatusReady {
+			err := conflict(m, "release")
+			http.Error(writer, err.message, err.code)
+			return
+		}
+	}
+	released := []string{}
+	for _, m := range machines {
+		if m.Status != v2.NodeStatusReady {
+			s.release(m, request)
+			released = append(released, m.SystemID)
+		}
+	}
+	writeJSON(writer, http.StatusOK, released)
+}
+
+func (s *Server) release(m *machine, request *apiRequest) *opError {
+	if !releasable(m.Status) {
+		return conflict(m, "release")
+	}
+	status := v2.NodeStatusReleasing
+	if request.param("erase") == "true" || request.param("secure_erase") == "true" || request.param("quick_erase") == "true" {
+		status = v2.NodeStatusDiskErasing
+	}
+	s.start(m, status, s.ReleaseDuration, v2.NodeStatusReady, "off")
+	m.Owner = ""
+	m.AgentName = ""
+	m.OwnerData = nil
+	m.OperatingSystem = ""
+	m.DistroSeries = ""
+	m.Kernel = ""
+	return nil
+}
+
+func (s *Server) deploy(m *machine, request *apiRequest) *opError {
+	if m.Status != v2.NodeStatusAllocated {
+		return conflict(m, "deploy")
+	}
+	m.OperatingSystem = "ubuntu"
+	m.DistroSeries = request.param("distro_series")
+	if m.DistroSeries == "" {
+		m.DistroSeries = DefaultDistroSeries
+	}
+	m.Kernel = request.param("hwe_kernel")
+	if agentName := request.param("agent_name"); agentName != "" {
+		m.AgentName = agentName
+	}
+	then, powerState := v2.NodeStatusDeployed, "on"
+	if m.failDeployment {
+		then, powerState = v2.NodeStatusFailedDeployment, "off"
+		m.failDeployment = false
+	}
+	s.start(m, v2.NodeStatusDeploying, s.DeployDuration, then, powerState)
+	m.PowerState = "on"
+	return nil
+}
+
+func (s *Server) commission(m *machine) *opError {
+	switch m.Status {
+	case v2.NodeStatusDeclared, v2.NodeStatusReady, v2.NodeStatusBroken, v2.NodeStatusFailedTests:
+	default:
+		return conflict(m, "commission")
+	}
+	s.start(m, v2.NodeStatusCommissioning, s.CommissionDuration, v2.NodeStatusReady, "off")
+	m.PowerState = "on"
+	return nil
+}
+
+func (s *Server) abort(m *machine) *opError {
+	switch m.Status {
+	case v2.NodeStatusDeploying:
+		m.Status = v2.NodeStatusAllocated
+	case v2.NodeStatusCommissioning:
+		m.Status = v2.NodeStatusDeclared
+	case v2.NodeStatusDiskErasing:
+		m.Status = v2.NodeStatusFailedDiskErasing
+	default:
+		return conflict(m, "abort")
+	}
+	m.pending = nil
+	m.PowerState = "off"
+	return nil
+}
+
+func (s *Server) markFixed(m *machine) *opError {
+	if m.Status != v2.NodeStatusBroken {
+		return conflict(m, "mark fixed")
+	}
+	m.Status = v2.NodeStatusReady
+	return nil
+}
+
+// setOwnerData sets the owner data from the request parameters. An empty
+// value removes the key.
+func (s *Server) setOwnerData(m *machine, request *apiRequest) {
+	if m.OwnerData == nil {
+		m.OwnerData = make(map[string]string)
+	}
+	for key, values := range request.Form {
+		if key == "op" || len(values) == 0 {
+			continue
+		}
+		if values[0] == "" {
+			delete(m.OwnerData, key)
+		} else {
+			m.OwnerData[key] = values[0]
+		}
+	}
+}
+
+func (s *Server) deleteMachine(m *machine) {
+	for i, other := range s.machines {
+		if other == m {
+			s.machines = append(s.machines[:i:i], s.machines[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Server) renderMachine(request *apiRequest, m *machine) map[string]interface{} {
+	resourceURI := request.root + "machines/" + m.SystemID + "/"
+	interfaces := []interface{}{}
+	for i, mac := range m.MACAddresses {
+		interfaces = append(interfaces, map[string]interface{}{
+			"id":           i + 1,
+			"name":         fmt.Sprintf("eth%d", i),
+			"type":         "physical",
+			"enabled":      true,
+			"mac_address":  mac,
+			"resource_uri": fmt.Sprintf("%sinterfaces/%d/", resourceURI, i+1),
+		})
+	}
+	ownerData := m.OwnerData
+	if ownerData == nil {
+		ownerData = map[string]string{}
+	}
+	rendered := map[string]interface{}{
+		"resource_uri":  resourceURI,
+		"system_id":     m.SystemID,
+		"hostname":      m.Hostname,
+		"fqdn":          m.Hostname + ".maas",
+		"architecture":  m.Architecture,
+		"cpu_count":     m.CPUCount,
+		"memory":        m.Memory,
+		"tag_names":     append([]string{}, m.Tags...),
+		"status":        m.Status.Number(),
+		"status_name":   m.Status.String(),
+		"power_state":   m.PowerState,
+		"owner":         m.Owner,
+		"owner_data":    ownerData,
+		"osystem":       m.OperatingSystem,
+		"distro_series": m.DistroSeries,
+		"hwe_kernel":    m.Kernel,
+		"interface_set": interfaces,
+		"zone":          s.renderZone(request, s.zone(m.Zone)),
+	}
+	if len(interfaces) > 0 {
+		rendered["boot_interface"] = interfaces[0]
+	}
+	return rendered
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether values contains any of others.
+func containsAny(values, others []string) bool {
+	for _, other := range others {
+		if contains(values, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAll reports whether values contains all of others.
+func containsAll(values, others []string) bool {
+	for _, other := range others {
+		if !contains(values, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package maastest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Zone is an availability zone of a Server.
+type Zone struct {
+	Name        string
+	Description string
+}
+
+type fabric struct {
+	id   int
+	name string
+}
+
+type vlan struct {
+	id     int
+	fabric *fabric
+	vid    int
+	name   string
+	mtu    int
+	dhcp   bool
+}
+
+// Subnet is a subnet of a Server.
+type Subnet struct {
+	Name string
+	CIDR string
+	// VLAN is the ID of the VLAN of the subnet, as returned by AddFabric
+	// or AddVLAN.
+	VLAN       int
+	Gateway    string
+	Space      string
+	DNSServers []string
+}
+
+type subnet struct {
+	Subnet
+	id int
+}
+
+// AddZone adds a zone to the server.
+func (s *Server) AddZone(name, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zones = append(s.zones, &Zone{Name: name, Description: description})
+}
+
+// AddFabric adds a fabric to the server, and returns its ID and the ID of
+// its untagged VLAN.
+func (s *Server) AddFabric(name string) (fabricID, untaggedVLAN int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := &fabric{id: s.id(), name: name}
+	s.fabrics = append(s.fabrics, f)
+	v := &vlan{id: s.id(), fabric: f, name: "untagged", mtu: 1500}
+	s.vlans = append(s.vlans, v)
+	return f.id, v.id
+}
+
+// AddVLAN adds a tagged VLAN to a fabric, and returns its ID. It panics if
+// there is no such fabric.
+func (s *Server) AddVLAN(fabricID, vid int, name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fabric(fabricID)
+	if f == nil {
+		panic(fmt.Sprintf("no fabric %d", fabricID))
+	}
+	v := &vlan{id: s.id(), fabric: f, vid: vid, name: name, mtu: 1500}
+	s.vlans = append(s.vlans, v)
+	return v.id
+}
+
+// AddSubnet adds a subnet to the server, and returns its ID. It panics if
+// there is no such VLAN.
+func (s *Server) AddSubnet(sn Subnet) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.vlan(sn.VLAN) == nil {
+		panic(fmt.Sprintf("no VLAN %d", sn.VLAN))
+	}
+	if sn.Name == "" {
+		sn.Name = sn.CIDR
+	}
+	created := &subnet{Subnet: sn, id: s.id()}
+	s.subnets = append(s.subnets, created)
+	return created.id
+}
+
+func (s *Server) zone(name string) *Zone {
+	for _, zone := range s.zones {
+		if zone.Name == name {
+			return zone
+		}
+	}
+	return &Zone{Name: name}
+}
+
+func (s *Server) fabric(id int) *fabric {
+	for _, f := range s.fabrics {
+		if f.id == id {
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *Server) vlan(id int) *vlan {
+	for _, v := range s.vlans {
+		if v.id == id {
+			return v
+		}
+	}
+	return nil
+}
+
+func (s *Server) serveZones(writer http.ResponseWriter, request *apiRequest) {
+	if request.Method != "GET" || request.op != "" {
+		badOperation(writer, request)
+		return
+	}
+	switch len(request.segments) {
+	case 1:
+		zones := []interface{}{}
+		for _, zone := range s.zones {
+			zones = append(zones, s.renderZone(request, zone))
+		}
+		writeJSON(writer, http.StatusOK, zones)
+	case 2:
+		for _, zone := range s.zones {
+			if zone.Name == request.segments[1] {
+				writeJSON(writer, http.StatusOK, s.renderZone(request, zone))
+				return
+			}
+		}
+		http.NotFound(writer, request.Request)
+	default:
+		http.NotFound(writer, request.Request)
+	}
+}
+
+func (s *Server) serveFabrics(writer http.ResponseWriter, request *apiRequest) {
+	if request.Method != "GET" || request.op != "" {
+		badOperation(writer, request)
+		return
+	}
+	if len(request.segments) == 1 {
+		fabrics := []interface{}{}
+		for _, f := range s.fabrics {
+			fabrics = append(fabrics, s.renderFabric(request, f))
+		}
+		writeJSON(writer, http.StatusOK, fabrics)
+		return
+	}
+	id, err := strconv.Atoi(request.segments[1])
+	f := s.fabric(id)
+	if err != nil || f == nil {
+		http.NotFound(writer, request.Request)
+		return
+	}
+	switch {
+	case len(request.segments) == 2:
+		writeJSON(writer, http.StatusOK, s.renderFabric(request, f))
+	case len(request.segments) == 3 && request.segments[2] == "vlans":
+		writeJSON(writer, http.StatusOK, s.renderVLANs(request, f))
+	case len(request.segments) == 4 && request.segments[2] == "vlans":
+		for _, v := range s.vlans {
+			if v.fabric == f && strconv.Itoa(v.vid) == request.segments[3] {
+				writeJSON(writer, http.StatusOK, s.renderVLAN(request, v))
+				return
+			}
+		}
+		http.NotFound(writer, request.Request)
+	default:
+		http.NotFound(writer, request.Request)
+	}
+}
+
+func (s *Server) serveSubnets(writer http.ResponseWriter, request *apiRequest) {
+	if request.Method != "GET" || request.op != "" {
+		badOperation(writer, request)
+		return
+	}
+	switch len(request.segments) {
+	case 1:
+		subnets := []interface{}{}
+		for _, sn := range s.subnets {
+			subnets = append(subnets, s.renderSubnet(request, sn))
+		}
+		writeJSON(writer, http.StatusOK, subnets)
+	case 2:
+		for _, sn := range s.subnets {
+			if strconv.Itoa(sn.id) == request.segments[1] {
+				writeJSON(writer, http.StatusOK, s.renderSubnet(request, sn))
+				return
+			}
+		}
+		http.NotFound(writer, request.Request)
+	default:
+		http.NotFound(writer, request.Request)
+	}
+}
+
+func (s *Server) renderZone(request *apiRequest, zone *Zone) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         zone.Name,
+		"description":  zone.Description,
+		"resource_uri": request.root + "zones/" + zone.Name + "/",
+	}
+}
+
+func (s *Server) renderFabric(request *apiRequest, f *fabric) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           f.id,
+		"name":         f.name,
+		"class_type":   nil,
+		"vlans":        s.renderVLANs(request, f),
+		"resource_uri": fmt.Sprintf("%sfabrics/%d/", request.root, f.id),
+	}
+}
+
+func (s *Server) renderVLANs(request *apiRequest, f *fabric) []interface{} {
+	vlans := []interface{}{}
+	for _, v := range s.vlans {
+		if v.fabric == f {
+			vlans = append(vlans, s.renderVLAN(request, v))
+		}
+	}
+	return vlans
+}
+
+func (s *Server) renderVLAN(request *apiRequest, v *vlan) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           v.id,
+		"name":         v.name,
+		"vid":          v.vid,
+		"mtu":          v.mtu,
+		"dhcp_on":      v.dhcp,
+		"fabric":       v.fabric.name,
+		"fabric_id":    v.fabric.id,
+		"resource_uri": fmt.Sprintf("%svlans/%d/", request.root, v.id),
+	}
+}
+
+func (s *Server) renderSubnet(request *apiRequest, sn *subnet) map[string]interface{} {
+	dnsServers := append([]string{}, sn.DNSServers...)
+	return map[string]interface{}{
+		"id":           sn.id,
+		"name":         sn.Name,
+		"cidr":         sn.CIDR,
+		"gateway_ip":   sn.Gateway,
+		"space":        sn.Space,
+		"dns_servers":  dnsServers,
+		"vlan":         s.renderVLAN(request, s.vlan(sn.VLAN)),
+		"resource_uri": fmt.Sprintf("%ssubnets/%d/", request.root, sn.id),
+	}
+}
@@ -0,0 +1,292 @@
+// Package maastest provides a stateful, in-memory fake of the maas 2.0 API,
+// for testing code using a v2.Controller without a maas server.
+package maastest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIVersion is the version of the API served.
+const APIVersion = "2.0"
+
+// DefaultCapabilities are the capabilities reported by the version endpoint.
+var DefaultCapabilities = []string{
+	"networks-management",
+	"static-ipaddresses",
+	"ipv6-deployment-ubuntu",
+	"devices-management",
+	"storage-deployment-ubuntu",
+	"network-deployment-ubuntu",
+}
+
+// Server is a fake maas keeping machines, files, zones, fabrics, VLANs and
+// subnets in memory. Machines move through the v2.NodeStatus values as
+// they are allocated, deployed, commissioned and released; the transitions
+// that take time on a real maas complete when the server's clock is
+// advanced past their duration.
+//
+// The API is served under any path ending in /api/2.0/, so that the URL of
+// the server can be used as the BaseURL of a v2.Controller.
+type Server struct {
+	*httptest.Server
+
+	// User is the name of the user the API key belongs to. It owns the
+	// machines allocated through the API.
+	User string
+	// APIKey, when set, is the only key whose requests are accepted;
+	// other requests fail with 401 Unauthorized. Empty means any request
+	// is accepted.
+	APIKey string
+	// Version is reported by the version endpoint.
+	Version string
+
+	// DeployDuration is how long a machine stays deploying.
+	DeployDuration time.Duration
+	// CommissionDuration is how long a machine stays commissioning.
+	CommissionDuration time.Duration
+	// ReleaseDuration is how long a machine stays releasing or erasing its
+	// disks.
+	ReleaseDuration time.Duration
+
+	mu       sync.Mutex
+	now      time.Time
+	nextID   int
+	machines []*machine
+	files    map[string]*file
+	zones    []*Zone
+	fabrics  []*fabric
+	vlans    []*vlan
+	subnets  []*subnet
+	failures []*Failure
+}
+
+// NewServer returns an unstarted Server with a single zone, "default", and
+// no machines.
+func NewServer() *Server {
+	server := &Server{
+		User:    "admin",
+		Version: "2.4.0",
+		now:     time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		files:   make(map[string]*file),
+		zones:   []*Zone{{Name: "default"}},
+	}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.serve))
+	return server
+}
+
+// Now returns the time of the server's clock.
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Advance moves the server's clock forward by d, completing the machine
+// transitions due by then.
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+	s.settle()
+}
+
+// Failure makes the server answer the matching requests with an error
+// instead of serving them. Empty fields match any request.
+type Failure struct {
+	Method string
+	// Path is relative to the API root, e.g. "machines/".
+	Path string
+	Op   string
+	// StatusCode and Body are the response given.
+	StatusCode int
+	Body       string
+	// Count is the number of requests failed, after which the failure is
+	// removed. Zero means every matching request fails until
+	// ClearFailures is called.
+	Count int
+}
+
+// InjectFailure adds a failure. The failures are checked in the order they
+// were added, and the first matching one is used.
+func (s *Server) InjectFailure(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure)
+}
+
+// ClearFailures removes all the failures.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+func (s *Server) failure(request *apiRequest) *Failure {
+	for i, failure := range s.failures {
+		if (failure.Method != "" && failure.Method != request.Method) ||
+			(failure.Path != "" && failure.Path != request.path) ||
+			(failure.Op != "" && failure.Op != request.op) {
+			continue
+		}
+		if failure.Count > 0 {
+			failure.Count--
+			if failure.Count == 0 {
+				s.failures = append(s.failures[:i:i], s.failures[i+1:]...)
+			}
+		}
+		return failure
+	}
+	return nil
+}
+
+// apiRequest is a request to the API, with its path relative to the API
+// root split into segments.
+type apiRequest struct {
+	*http.Request
+	// root is the path of the API root, e.g. /MAAS/api/2.0/.
+	root     string
+	path     string
+	segments []string
+	op       string
+}
+
+// param returns the first value of the named request parameter.
+func (r *apiRequest) param(name string) string {
+	values := r.params(name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// params returns all the values of the named request parameter.
+func (r *apiRequest) params(name string) []string {
+	return r.Form[name]
+}
+
+func (s *Server) serve(writer http.ResponseWriter, request *http.Request) {
+	prefix := "/api/" + APIVersion + "/"
+	index := strings.Index(request.URL.Path, prefix)
+	if index < 0 {
+		http.NotFound(writer, request)
+		return
+	}
+	var err error
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "multipart/form-data") {
+		err = request.ParseMultipartForm(32 << 20)
+	} else {
+		err = request.ParseForm()
+	}
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	api := &apiRequest{
+		Request: request,
+		root:    request.URL.Path[:index+len(prefix)],
+		path:    request.URL.Path[index+len(prefix):],
+		op:      request.URL.Query().Get("op"),
+	}
+	for _, segment := range strings.Split(api.path, "/") {
+		if segment != "" {
+			api.segments = append(api.segments, segment)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settle()
+	if failure := s.failure(api); failure != nil {
+		writer.WriteHeader(failure.StatusCode)
+		fmt.Fprint(writer, failure.Body)
+		return
+	}
+	if !s.authorized(api) {
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if len(api.segments) == 0 {
+		http.NotFound(writer, request)
+		return
+	}
+	switch api.segments[0] {
+	case "version":
+		s.serveVersion(writer, api)
+	case "users":
+		s.serveUsers(writer, api)
+	case "machines":
+		s.serveMachines(writer, api)
+	case "files":
+		s.serveFiles(writer, api)
+	case "zones":
+		s.serveZones(writer, api)
+	case "fabrics":
+		s.serveFabrics(writer, api)
+	case "subnets":
+		s.serveSubnets(writer, api)
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+// authorized reports whether the request is signed with the APIKey, if one
+// is set. The version and anonymous file endpoints need no key.
+func (s *Server) authorized(request *apiRequest) bool {
+	if s.APIKey == "" || request.path == "version/" || request.op == "get_by_key" {
+		return true
+	}
+	elements := strings.Split(s.APIKey, ":")
+	if len(elements) != 3 {
+		return false
+	}
+	authorization := request.Header.Get("Authorization")
+	return strings.Contains(authorization, fmt.Sprintf(`oauth_consumer_key="%s"`, elements[0])) &&
+		strings.Contains(authorization, fmt.Sprintf(`oauth_token="%s"`, elements[1]))
+}
+
+func (s *Server) serveVersion(writer http.ResponseWriter, request *apiRequest) {
+	if request.Method != "GET" {
+		methodNotAllowed(writer)
+		return
+	}
+	writeJSON(writer, http.StatusOK, map[string]interface{}{
+		"version":      s.Version,
+		"subversion":   "",
+		"capabilities": DefaultCapabilities,
+	})
+}
+
+func (s *Server) serveUsers(writer http.ResponseWriter, request *apiRequest) {
+	if request.Method != "GET" || request.op != "whoami" {
+		badOperation(writer, request)
+		return
+	}
+	writeJSON(writer, http.StatusOK, s.User)
+}
+
+// id returns a new unique number.
+func (s *Server) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+func writeJSON(writer http.ResponseWriter, code int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
+	json.NewEncoder(writer).Encode(value)
+}
+
+func methodNotAllowed(writer http.ResponseWriter) {
+	http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// badOperation answers like maas does to an operation it doesn't know.
+func badOperation(writer http.ResponseWriter, request *apiRequest) {
+	http.Error(writer, fmt.Sprintf("Unrecognised signature: method=%s op=%s", request.Method, request.op), http.StatusBadRequest)
+}
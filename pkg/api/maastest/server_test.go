@@ -0,0 +1,212 @@
+package maastest
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	v2 "github.com/alejandroEsc/golang-maas-client/pkg/api/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAPIKey = "fake:as:key"
+
+func newServerAndController(t *testing.T) (*Server, *v2.Controller) {
+	server := NewServer()
+	server.APIKey = testAPIKey
+	server.Start()
+	controller, err := v2.NewController(v2.ControllerArgs{
+		BaseURL: server.URL + "/MAAS/",
+		APIKey:  testAPIKey,
+	})
+	assert.Nil(t, err)
+	return server, controller
+}
+
+func machineStatus(t *testing.T, server *Server, systemID string) v2.NodeStatus {
+	m, ok := server.Machine(systemID)
+	assert.True(t, ok)
+	return m.Status
+}
+
+func TestServerVersionAndWhoami(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+
+	assert.True(t, controller.Capabilities.Contains("network-deployment-ubuntu"))
+}
+
+func TestServerRejectsOtherKeys(t *testing.T) {
+	server := NewServer()
+	server.APIKey = testAPIKey
+	server.Start()
+	defer server.Close()
+
+	_, err := v2.NewController(v2.ControllerArgs{BaseURL: server.URL, APIKey: "other:as:key"})
+
+	assert.True(t, util.IsPermissionError(err), "%v", err)
+}
+
+func TestServerMachineLifecycle(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+	server.DeployDuration = time.Minute
+	server.ReleaseDuration = time.Minute
+	server.AddZone("zone-2", "second zone")
+	server.AddMachine(Machine{Hostname: "small", CPUCount: 1, Memory: 1024, Zone: "zone-2"})
+	big := server.AddMachine(Machine{Hostname: "big", CPUCount: 8, Memory: 16384, Tags: []string{"ssd"}, Zone: "zone-2"})
+
+	machine, _, err := controller.AllocateMachine(v2.AllocateMachineArgs{MinCPUCount: 4, Tags: []string{"ssd"}, Zone: "zone-2", AgentName: "juju"})
+	assert.Nil(t, err)
+	assert.Equal(t, machine.SystemID, big)
+	assert.Equal(t, machine.StatusName, "Allocated")
+	assert.Equal(t, machine.Zone.Name, "zone-2")
+	assert.Equal(t, machineStatus(t, server, big), v2.NodeStatusAllocated)
+
+	err = controller.Deploy(machine, v2.DeployMachineArgs{DistroSeries: "xenial"})
+	assert.Nil(t, err)
+	assert.Equal(t, machine.StatusName, "Deploying")
+	assert.Equal(t, machine.DistroSeries, "xenial")
+	server.Advance(time.Minute)
+	machines, err := controller.Machines(v2.MachinesArgs{AgentName: "juju"})
+	assert.Nil(t, err)
+	assert.Len(t, machines, 1)
+	assert.Equal(t, machines[0].StatusName, "Deployed")
+	assert.Equal(t, machines[0].PowerState, "on")
+
+	err = controller.ReleaseMachines(v2.ReleaseMachinesArgs{SystemIDs: []string{big}})
+	assert.Nil(t, err)
+	assert.Equal(t, machineStatus(t, server, big), v2.NodeStatusReleasing)
+	server.Advance(time.Minute)
+
+	released, _ := server.Machine(big)
+	assert.Equal(t, released.Status, v2.NodeStatusReady)
+	assert.Equal(t, released.Owner, "")
+	assert.Equal(t, released.PowerState, "off")
+}
+
+func TestServerAllocateNoMatch(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+	server.AddMachine(Machine{CPUCount: 1})
+	server.AddMachine(Machine{CPUCount: 8, Status: v2.NodeStatusDeployed})
+
+	_, _, err := controller.AllocateMachine(v2.AllocateMachineArgs{MinCPUCount: 4})
+
+	assert.True(t, util.IsNoMatchError(err), "%v", err)
+}
+
+func TestServerFailedDeployment(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+	systemID := server.AddMachine(Machine{})
+	server.FailDeployment(systemID)
+	machine, _, err := controller.AllocateMachine(v2.AllocateMachineArgs{})
+	assert.Nil(t, err)
+
+	err = controller.Deploy(machine, v2.DeployMachineArgs{})
+	assert.Nil(t, err)
+	server.Advance(time.Second)
+
+	assert.Equal(t, machineStatus(t, server, systemID), v2.NodeStatusFailedDeployment)
+	err = controller.Deploy(machine, v2.DeployMachineArgs{})
+	assert.True(t, util.IsBadRequestError(err), "%v", err)
+}
+
+func TestServerReleaseErrors(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+	systemID := server.AddMachine(Machine{Status: v2.NodeStatusCommissioning})
+
+	err := controller.ReleaseMachines(v2.ReleaseMachinesArgs{SystemIDs: []string{"unknown"}})
+	assert.True(t, util.IsBadRequestError(err), "%v", err)
+	err = controller.ReleaseMachines(v2.ReleaseMachinesArgs{SystemIDs: []string{systemID}})
+	assert.True(t, util.IsCannotCompleteError(err), "%v", err)
+}
+
+func TestServerOwnerData(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+	systemID := server.AddMachine(Machine{OwnerData: map[string]string{"keep": "me", "drop": "me"}})
+	machines, err := controller.Machines(v2.MachinesArgs{SystemIDs: []string{systemID}})
+	assert.Nil(t, err)
+
+	err = controller.SetOwnerData(&machines[0], map[string]string{"drop": "", "add": "value"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, machines[0].OwnerData, map[string]string{"keep": "me", "add": "value"})
+	m, _ := server.Machine(systemID)
+	assert.Equal(t, m.OwnerData, map[string]string{"keep": "me", "add": "value"})
+}
+
+func TestServerFiles(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+
+	err := controller.AddFile(v2.AddFileArgs{Filename: "tools.tgz", Content: []byte("content")})
+	assert.Nil(t, err)
+	content, ok := server.File("tools.tgz")
+	assert.True(t, ok)
+	assert.Equal(t, string(content), "content")
+
+	file, err := controller.GetFile("tools.tgz")
+	assert.Nil(t, err)
+	assert.Equal(t, file.Filename, "tools.tgz")
+	assert.Equal(t, file.Content, "content")
+	var buf bytes.Buffer
+	_, err = controller.DownloadFile(v2.DownloadFileArgs{AnonymousURI: file.AnonymousURI, Writer: &buf})
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "content")
+
+	_, err = controller.GetFile("missing")
+	assert.True(t, util.IsNoMatchError(err), "%v", err)
+}
+
+func TestServerNetworking(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+	fabricID, untagged := server.AddFabric("fabric-0")
+	tagged := server.AddVLAN(fabricID, 10, "storage")
+	server.AddSubnet(Subnet{CIDR: "10.0.0.0/24", VLAN: untagged, Gateway: "10.0.0.1"})
+	server.AddSubnet(Subnet{CIDR: "10.0.10.0/24", VLAN: tagged, Space: "storage"})
+
+	fabrics, err := controller.Fabrics()
+	assert.Nil(t, err)
+	assert.Len(t, fabrics, 1)
+	assert.Equal(t, fabrics[0].Name, "fabric-0")
+	assert.Len(t, fabrics[0].VLANs, 2)
+	assert.Equal(t, fabrics[0].VLANs[1].VID, 10)
+
+	zones, err := controller.Zones()
+	assert.Nil(t, err)
+	assert.Len(t, zones, 1)
+	assert.Equal(t, zones[0].Name, "default")
+
+	source, err := controller.Get("subnets", "", nil)
+	assert.Nil(t, err)
+	assert.Contains(t, string(source), `"cidr":"10.0.10.0/24"`)
+	assert.Contains(t, string(source), `"fabric":"fabric-0"`)
+}
+
+func TestServerInjectFailure(t *testing.T) {
+	server, controller := newServerAndController(t)
+	defer server.Close()
+	server.AddMachine(Machine{})
+	server.InjectFailure(Failure{Method: "POST", Path: "machines/", Op: "allocate", StatusCode: http.StatusInternalServerError, Body: "boom", Count: 1})
+
+	_, _, err := controller.AllocateMachine(v2.AllocateMachineArgs{})
+	assert.True(t, util.IsUnexpectedError(err), "%v", err)
+	_, _, err = controller.AllocateMachine(v2.AllocateMachineArgs{})
+	assert.Nil(t, err)
+
+	server.InjectFailure(Failure{Path: "zones/", StatusCode: http.StatusForbidden})
+	for i := 0; i < 2; i++ {
+		_, err = controller.Zones()
+		assert.NotNil(t, err)
+	}
+	server.ClearFailures()
+	_, err = controller.Zones()
+	assert.Nil(t, err)
+}
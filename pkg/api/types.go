@@ -0,0 +1,63 @@
+package api
+
+import (
+	v2 "github.com/alejandroEsc/golang-maas-client/pkg/api/v2"
+)
+
+// The types of the typed MAAS operations. They are those of the newest
+// supported API version; the implementations for other versions convert to
+// and from them, so that callers do not change when a version is added.
+type (
+	Machine           = v2.Machine
+	MachinesArgs      = v2.MachinesArgs
+	ConstraintMatches = v2.ConstraintMatches
+
+	AllocateMachineArgs = v2.AllocateMachineArgs
+	DeployMachineArgs   = v2.DeployMachineArgs
+	ReleaseMachinesArgs = v2.ReleaseMachinesArgs
+
+	NodeStatus  = v2.NodeStatus
+	WaitOptions = v2.WaitOptions
+
+	File             = v2.File
+	AddFileArgs      = v2.AddFileArgs
+	DownloadFileArgs = v2.DownloadFileArgs
+
+	Fabric      = v2.Fabric
+	Space       = v2.Space
+	StaticRoute = v2.StaticRoute
+	Zone        = v2.Zone
+)
+
+// The node statuses, for WaitForStatus.
+const (
+	NodeStatusDeclared                 = v2.NodeStatusDeclared
+	NodeStatusCommissioning            = v2.NodeStatusCommissioning
+	NodeStatusFailedTests              = v2.NodeStatusFailedTests
+	NodeStatusMissing                  = v2.NodeStatusMissing
+	NodeStatusReady                    = v2.NodeStatusReady
+	NodeStatusReserved                 = v2.NodeStatusReserved
+	NodeStatusDeployed                 = v2.NodeStatusDeployed
+	NodeStatusRetired                  = v2.NodeStatusRetired
+	NodeStatusBroken                   = v2.NodeStatusBroken
+	NodeStatusDeploying                = v2.NodeStatusDeploying
+	NodeStatusAllocated                = v2.NodeStatusAllocated
+	NodeStatusFailedDeployment         = v2.NodeStatusFailedDeployment
+	NodeStatusReleasing                = v2.NodeStatusReleasing
+	NodeStatusFailedReleasing          = v2.NodeStatusFailedReleasing
+	NodeStatusDiskErasing              = v2.NodeStatusDiskErasing
+	NodeStatusFailedDiskErasing        = v2.NodeStatusFailedDiskErasing
+	NodeStatusRescueMode               = v2.NodeStatusRescueMode
+	NodeStatusEnteringRescueMode       = v2.NodeStatusEnteringRescueMode
+	NodeStatusFailedEnteringRescueMode = v2.NodeStatusFailedEnteringRescueMode
+	NodeStatusExitingRescueMode        = v2.NodeStatusExitingRescueMode
+	NodeStatusFailedExitingRescueMode  = v2.NodeStatusFailedExitingRescueMode
+	NodeStatusTesting                  = v2.NodeStatusTesting
+	NodeStatusFailedTesting            = v2.NodeStatusFailedTesting
+)
+
+// IsFailedStatusError returns true if err is the error WaitForStatus returns
+// when the machine reaches a failed status it was not waited for.
+func IsFailedStatusError(err error) bool {
+	return v2.IsFailedStatusError(err)
+}
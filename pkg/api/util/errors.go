@@ -167,3 +167,34 @@ func IsCannotCompleteError(err error) bool {
 	_, ok := errors.Cause(err).(*CannotCompleteError)
 	return ok
 }
+
+// CircuitOpenError is returned without contacting the server when a circuit
+// breaker has stopped requests to it after repeated failures.
+type CircuitOpenError struct {
+	errors.Err
+}
+
+// NewCircuitOpenError constructs a new CircuitOpenError and sets the location.
+func NewCircuitOpenError(message string) error {
+	err := &CircuitOpenError{Err: errors.NewErr(message)}
+	err.SetLocation(1)
+	return err
+}
+
+// IsCircuitOpenError returns true if err is a CircuitOpenError, or wraps
+// one, as the UnexpectedError returned by controller calls does.
+func IsCircuitOpenError(err error) bool {
+	for err != nil {
+		if _, ok := errors.Cause(err).(*CircuitOpenError); ok {
+			return true
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			return false
+		}
+		err = wrapper.Underlying()
+	}
+	return false
+}
@@ -79,3 +79,14 @@ func TestCannotCompleteError(t *testing.T) {
 	assert.True(t, IsCannotCompleteError(err))
 	assert.Equal(t, err.Error(), "server says no")
 }
+
+func TestCircuitOpenError(t *testing.T) {
+	err := NewCircuitOpenError("circuit open")
+	assert.NotNil(t, err)
+	assert.True(t, IsCircuitOpenError(err))
+	assert.Equal(t, err.Error(), "circuit open")
+	wrapped := NewUnexpectedError(errors.Trace(err))
+	assert.True(t, IsUnexpectedError(wrapped))
+	assert.True(t, IsCircuitOpenError(wrapped))
+	assert.False(t, IsCircuitOpenError(NewUnexpectedError(errors.New("boom"))))
+}
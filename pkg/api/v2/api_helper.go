@@ -1,9 +1,9 @@
 package v2
 
 import (
+	"context"
 	"encoding/json"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 
@@ -16,10 +16,17 @@ import (
 
 // GetFile returns a single File by its Filename.
 func (c *Controller) GetFile(filename string) (*File, error) {
+	return c.GetFileContext(context.Background(), filename)
+}
+
+// GetFileContext is like GetFile but the request is bound to ctx.
+func (c *Controller) GetFileContext(ctx context.Context, filename string) (_ *File, err error) {
+	ctx, end := c.startSpan(ctx, "GetFile")
+	defer end(&err)
 	if filename == "" {
 		return nil, errors.NotValidf("missing Filename")
 	}
-	source, err := c.Get("files/"+filename, "", nil)
+	source, err := c.GetContext(ctx, "files/"+filename, "", nil)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			if svrErr.StatusCode == http.StatusNotFound {
@@ -37,10 +44,17 @@ func (c *Controller) GetFile(filename string) (*File, error) {
 }
 
 func (c *Controller) ReadFileContent(f *File) ([]byte, error) {
+	return c.ReadFileContentContext(context.Background(), f)
+}
+
+// ReadFileContentContext is like ReadFileContent but the request is bound to ctx.
+func (c *Controller) ReadFileContentContext(ctx context.Context, f *File) (_ []byte, err error) {
+	ctx, end := c.startSpan(ctx, "ReadFileContent")
+	defer end(&err)
 	// If the Content is available, it is base64 encoded, so
 	args := make(url.Values)
-	args.Add("Filename", f.Filename)
-	bytes, err := c.Get("files", "Get", args)
+	args.Add("filename", f.Filename)
+	bytes, err := c.GetContext(ctx, "files", "get", args)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			switch svrErr.StatusCode {
@@ -55,11 +69,67 @@ func (c *Controller) ReadFileContent(f *File) ([]byte, error) {
 	return bytes, nil
 }
 
+// DownloadFile streams the Content of a File to args.Writer, and returns
+// the number of bytes written. Interrupted downloads are resumed, and the
+// Content is checked against the expected Size and SHA256 if given.
+// Returns
+//  - NoMatchError if the File cannot be found
+//  - PermissionError if the user does not have permission to read the File
+//  - NotValid error if the Content does not match the expected Size or SHA256
+func (c *Controller) DownloadFile(args DownloadFileArgs) (int64, error) {
+	return c.DownloadFileContext(context.Background(), args)
+}
+
+// DownloadFileContext is like DownloadFile but the requests are bound to ctx.
+func (c *Controller) DownloadFileContext(ctx context.Context, args DownloadFileArgs) (_ int64, err error) {
+	ctx, end := c.startSpan(ctx, "DownloadFile")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return 0, err
+	}
+	opts := client.DownloadOptions{Size: args.Size, SHA256: args.SHA256}
+	var written int64
+	if args.AnonymousURI != nil {
+		// The anonymous URI carries its own operation and key.
+		anonymous := *c.Client
+		anonymous.Signer = client.AnonymousSigner()
+		params := args.AnonymousURI.Query()
+		op := params.Get("op")
+		params.Del("op")
+		uri := *args.AnonymousURI
+		uri.RawQuery = ""
+		written, err = anonymous.DownloadContext(ctx, &uri, op, params, args.Writer, opts)
+	} else {
+		params := url.Values{"filename": {args.Filename}}
+		written, err = c.Client.DownloadContext(ctx, &url.URL{Path: "files/"}, "get", params, args.Writer, opts)
+	}
+	if err != nil {
+		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusNotFound:
+				return written, errors.Wrap(err, util.NewNoMatchError(svrErr.BodyMessage))
+			case http.StatusForbidden:
+				return written, errors.Wrap(err, util.NewPermissionError(svrErr.BodyMessage))
+			}
+		}
+		if errors.IsNotValid(err) {
+			return written, err
+		}
+		return written, util.NewUnexpectedError(err)
+	}
+	return written, nil
+}
+
 // getFiles returns all the files that match the specified prefix.
 func (c *Controller) getFiles(prefix string) ([]File, error) {
+	return c.getFilesContext(context.Background(), prefix)
+}
+
+// getFilesContext is like getFiles but the request is bound to ctx.
+func (c *Controller) getFilesContext(ctx context.Context, prefix string) ([]File, error) {
 	params := util.NewURLParams()
 	params.MaybeAdd("prefix", prefix)
-	source, err := c.Get("files", "", params.Values)
+	source, err := c.GetContext(ctx, "files", "", params.Values)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -74,7 +144,14 @@ func (c *Controller) getFiles(prefix string) ([]File, error) {
 
 // Fabrics returns the list of Fabrics defined in the maas ControllerInterface.
 func (c *Controller) Fabrics() ([]Fabric, error) {
-	source, err := c.Get("fabrics", "", nil)
+	return c.FabricsContext(context.Background())
+}
+
+// FabricsContext is like Fabrics but the request is bound to ctx.
+func (c *Controller) FabricsContext(ctx context.Context) (_ []Fabric, err error) {
+	ctx, end := c.startSpan(ctx, "Fabrics")
+	defer end(&err)
+	source, err := c.GetContext(ctx, "fabrics", "", nil)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -90,7 +167,14 @@ func (c *Controller) Fabrics() ([]Fabric, error) {
 
 // Spaces returns the list of Spaces defined in the maas ControllerInterface.
 func (c *Controller) Spaces() ([]Space, error) {
-	source, err := c.Get("spaces", "", nil)
+	return c.SpacesContext(context.Background())
+}
+
+// SpacesContext is like Spaces but the request is bound to ctx.
+func (c *Controller) SpacesContext(ctx context.Context) (_ []Space, err error) {
+	ctx, end := c.startSpan(ctx, "Spaces")
+	defer end(&err)
+	source, err := c.GetContext(ctx, "spaces", "", nil)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -106,7 +190,14 @@ func (c *Controller) Spaces() ([]Space, error) {
 
 // StaticRoutes returns the list of StaticRoutes defined in the maas ControllerInterface.
 func (c *Controller) StaticRoutes() ([]StaticRoute, error) {
-	source, err := c.Get("static-routes", "", nil)
+	return c.StaticRoutesContext(context.Background())
+}
+
+// StaticRoutesContext is like StaticRoutes but the request is bound to ctx.
+func (c *Controller) StaticRoutesContext(ctx context.Context) (_ []StaticRoute, err error) {
+	ctx, end := c.startSpan(ctx, "StaticRoutes")
+	defer end(&err)
+	source, err := c.GetContext(ctx, "static-routes", "", nil)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -121,7 +212,14 @@ func (c *Controller) StaticRoutes() ([]StaticRoute, error) {
 
 // Zones lists all the zones known to the maas ControllerInterface.
 func (c *Controller) Zones() ([]Zone, error) {
-	source, err := c.Get("zones", "", nil)
+	return c.ZonesContext(context.Background())
+}
+
+// ZonesContext is like Zones but the request is bound to ctx.
+func (c *Controller) ZonesContext(ctx context.Context) (_ []Zone, err error) {
+	ctx, end := c.startSpan(ctx, "Zones")
+	defer end(&err)
+	source, err := c.GetContext(ctx, "zones", "", nil)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -135,8 +233,15 @@ func (c *Controller) Zones() ([]Zone, error) {
 
 // Nodes returns a list of devices that match the params.
 func (c *Controller) Nodes(args NodesArgs) ([]Node, error) {
+	return c.NodesContext(context.Background(), args)
+}
+
+// NodesContext is like Nodes but the request is bound to ctx.
+func (c *Controller) NodesContext(ctx context.Context, args NodesArgs) (_ []Node, err error) {
+	ctx, end := c.startSpan(ctx, "Nodes")
+	defer end(&err)
 	params := NodesParams(args)
-	source, err := c.Get("nodes", "", params.Values)
+	source, err := c.GetContext(ctx, "nodes", "", params.Values)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -152,12 +257,19 @@ func (c *Controller) Nodes(args NodesArgs) ([]Node, error) {
 
 // CreateNode creates and returns a new NodeInterface.
 func (c *Controller) CreateNode(args CreateNodeArgs) (*Node, error) {
+	return c.CreateNodeContext(context.Background(), args)
+}
+
+// CreateNodeContext is like CreateNode but the request is bound to ctx.
+func (c *Controller) CreateNodeContext(ctx context.Context, args CreateNodeArgs) (_ *Node, err error) {
+	ctx, end := c.startSpan(ctx, "CreateNode")
+	defer end(&err)
 	// There must be at least one mac address.
 	if len(args.MACAddresses) == 0 {
 		return nil, util.NewBadRequestError("at least one MAC address must be specified")
 	}
 	params := CreateNodesParams(args)
-	source, err := c.Post("nodes", "", params.Values)
+	source, err := c.PostContext(ctx, "nodes", "", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			if svrErr.StatusCode == http.StatusBadRequest {
@@ -178,8 +290,15 @@ func (c *Controller) CreateNode(args CreateNodeArgs) (*Node, error) {
 
 // Machines returns a list of machines that match the params.
 func (c *Controller) Machines(args MachinesArgs) ([]Machine, error) {
+	return c.MachinesContext(context.Background(), args)
+}
+
+// MachinesContext is like Machines but the request is bound to ctx.
+func (c *Controller) MachinesContext(ctx context.Context, args MachinesArgs) (_ []Machine, err error) {
+	ctx, end := c.startSpan(ctx, "Machines")
+	defer end(&err)
 	params := MachinesParams(args)
-	source, err := c.Get("machines", "", params.Values)
+	source, err := c.GetContext(ctx, "machines", "", params.Values)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -198,19 +317,35 @@ func (c *Controller) Machines(args MachinesArgs) ([]Machine, error) {
 // File without sending the Content of the File, we can return a FileInterface
 // instance here too.
 func (c *Controller) AddFile(args AddFileArgs) error {
+	return c.AddFileContext(context.Background(), args)
+}
+
+// AddFileContext is like AddFile but the request is bound to ctx.
+func (c *Controller) AddFileContext(ctx context.Context, args AddFileArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "AddFile")
+	defer end(&err)
 	if err := args.Validate(); err != nil {
 		return err
 	}
-	fileContent := args.Content
-	if fileContent == nil {
-		content, err := ioutil.ReadAll(io.LimitReader(args.Reader, args.Length))
+	var file client.UploadFile
+	switch source := args.Reader.(type) {
+	case nil:
+		file = client.BytesUploadFile("file", args.Content)
+	case io.ReadSeeker:
+		var err error
+		file, err = client.SeekerUploadFile("file", source, args.Length)
 		if err != nil {
-			return errors.Annotatef(err, "cannot read File Content")
+			return errors.Trace(err)
 		}
-		fileContent = content
+	default:
+		file = client.ReaderUploadFile("file", source, args.Length)
 	}
-	params := url.Values{"Filename": {args.Filename}}
-	_, err := c.PostFile("files", "", params, fileContent)
+	upload := client.Upload{
+		Params:   url.Values{"filename": {args.Filename}},
+		Files:    []client.UploadFile{file},
+		Progress: args.Progress,
+	}
+	_, err = c.PostStreamContext(ctx, "files", "", upload)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			if svrErr.StatusCode == http.StatusBadRequest {
@@ -233,7 +368,14 @@ func ownerDataMatches(ownerData, filter map[string]string) bool {
 
 // BootResources implements ControllerInterface.
 func (c *Controller) BootResources() ([]*BootResource, error) {
-	source, err := c.Get("boot-resources", "", nil)
+	return c.BootResourcesContext(context.Background())
+}
+
+// BootResourcesContext is like BootResources but the request is bound to ctx.
+func (c *Controller) BootResourcesContext(ctx context.Context) (_ []*BootResource, err error) {
+	ctx, end := c.startSpan(ctx, "BootResources")
+	defer end(&err)
+	source, err := c.GetContext(ctx, "boot-resources", "", nil)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -252,9 +394,16 @@ func (c *Controller) BootResources() ([]*BootResource, error) {
 // Returns an error that satisfies IsNoMatchError if the requested
 // constraints cannot be met.
 func (c *Controller) AllocateMachine(args AllocateMachineArgs) (*Machine, ConstraintMatches, error) {
+	return c.AllocateMachineContext(context.Background(), args)
+}
+
+// AllocateMachineContext is like AllocateMachine but the request is bound to ctx.
+func (c *Controller) AllocateMachineContext(ctx context.Context, args AllocateMachineArgs) (_ *Machine, _ ConstraintMatches, err error) {
+	ctx, end := c.startSpan(ctx, "AllocateMachine")
+	defer end(&err)
 	var matches ConstraintMatches
 	params := AllocateMachinesParams(args)
-	result, err := c.Post("machines", "allocate", params.Values)
+	result, err := c.PostContext(ctx, "machines", "allocate", params.Values)
 	if err != nil {
 		// A 409 Status code is "No Matching Machines"
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
@@ -293,8 +442,15 @@ func (c *Controller) AllocateMachine(args AllocateMachineArgs) (*Machine, Constr
 //  - PermissionError if the user does not have permission to release any of the machines
 //  - CannotCompleteError if any of the machines could not be released due to their current state
 func (c *Controller) ReleaseMachines(args ReleaseMachinesArgs) error {
+	return c.ReleaseMachinesContext(context.Background(), args)
+}
+
+// ReleaseMachinesContext is like ReleaseMachines but the request is bound to ctx.
+func (c *Controller) ReleaseMachinesContext(ctx context.Context, args ReleaseMachinesArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "ReleaseMachines")
+	defer end(&err)
 	params := ReleaseMachinesParams(args)
-	_, err := c.Post("machines", "release", params.Values)
+	_, err = c.PostContext(ctx, "machines", "release", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			switch svrErr.StatusCode {
@@ -314,8 +470,15 @@ func (c *Controller) ReleaseMachines(args ReleaseMachinesArgs) error {
 
 // Deploy implements Machine.
 func (c *Controller) Deploy(m *Machine, args DeployMachineArgs) error {
+	return c.DeployContext(context.Background(), m, args)
+}
+
+// DeployContext is like Deploy but the request is bound to ctx.
+func (c *Controller) DeployContext(ctx context.Context, m *Machine, args DeployMachineArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "Deploy")
+	defer end(&err)
 	params := DeploytMachineParams(args)
-	result, err := c.Post(m.ResourceURI, "deploy", params.Values)
+	result, err := c.PostContext(ctx, m.ResourceURI, "deploy", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			switch svrErr.StatusCode {
@@ -341,8 +504,15 @@ func (c *Controller) Deploy(m *Machine, args DeployMachineArgs) error {
 
 // Devices implements Controller.
 func (c *Controller) Devices(args DevicesArgs) ([]Device, error) {
+	return c.DevicesContext(context.Background(), args)
+}
+
+// DevicesContext is like Devices but the request is bound to ctx.
+func (c *Controller) DevicesContext(ctx context.Context, args DevicesArgs) (_ []Device, err error) {
+	ctx, end := c.startSpan(ctx, "Devices")
+	defer end(&err)
 	params := GetDeviceParams(args)
-	source, err := c.Get("devices", "", params.Values)
+	source, err := c.GetContext(ctx, "devices", "", params.Values)
 	if err != nil {
 		return nil, util.NewUnexpectedError(err)
 	}
@@ -362,11 +532,18 @@ func (c *Controller) Devices(args DevicesArgs) ([]Device, error) {
 // its value to "". All Owner data is cleared when the object is
 // released.
 func (c *Controller) SetOwnerData(m *Machine, ownerData map[string]string) error {
+	return c.SetOwnerDataContext(context.Background(), m, ownerData)
+}
+
+// SetOwnerDataContext is like SetOwnerData but the request is bound to ctx.
+func (c *Controller) SetOwnerDataContext(ctx context.Context, m *Machine, ownerData map[string]string) (err error) {
+	ctx, end := c.startSpan(ctx, "SetOwnerData")
+	defer end(&err)
 	params := make(url.Values)
 	for key, value := range ownerData {
 		params.Add(key, value)
 	}
-	result, err := c.Post(m.ResourceURI, "set_owner_data", params)
+	result, err := c.PostContext(ctx, m.ResourceURI, "set_owner_data", params)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -383,8 +560,15 @@ func (c *Controller) SetOwnerData(m *Machine, ownerData map[string]string) error
 
 // CreateInterface implements NodeInterface.
 func (c *Controller) CreateInterface(d *Node, args CreateNodeNetworkInterfaceArgs) (*NetworkInterface, error) {
+	return c.CreateInterfaceContext(context.Background(), d, args)
+}
+
+// CreateInterfaceContext is like CreateInterface but the request is bound to ctx.
+func (c *Controller) CreateInterfaceContext(ctx context.Context, d *Node, args CreateNodeNetworkInterfaceArgs) (_ *NetworkInterface, err error) {
+	ctx, end := c.startSpan(ctx, "CreateInterface")
+	defer end(&err)
 	params := CreateNodeNetworkInterfaceParams(args)
-	result, err := c.Post(d.ResourceURI+"interfaces/", "create_physical", params.Values)
+	result, err := c.PostContext(ctx, d.ResourceURI+"interfaces/", "create_physical", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			switch svrErr.StatusCode {
@@ -411,6 +595,13 @@ func (c *Controller) CreateInterface(d *Node, args CreateNodeNetworkInterfaceArg
 // UnlinkSubnet will remove the Link to the Subnet, and release the IP
 // address associated if there is one.
 func (c *Controller) UnlinkSubnet(i *NetworkInterface, s *Subnet) error {
+	return c.UnlinkSubnetContext(context.Background(), i, s)
+}
+
+// UnlinkSubnetContext is like UnlinkSubnet but the request is bound to ctx.
+func (c *Controller) UnlinkSubnetContext(ctx context.Context, i *NetworkInterface, s *Subnet) (err error) {
+	ctx, end := c.startSpan(ctx, "UnlinkSubnet")
+	defer end(&err)
 	if s == nil {
 		return errors.NotValidf("missing Subnet")
 	}
@@ -420,7 +611,7 @@ func (c *Controller) UnlinkSubnet(i *NetworkInterface, s *Subnet) error {
 	}
 	params := util.NewURLParams()
 	params.Values.Add("ID", fmt.Sprint(link.ID))
-	source, err := c.Post(i.ResourceURI, "unlink_subnet", params.Values)
+	source, err := c.PostContext(ctx, i.ResourceURI, "unlink_subnet", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			switch svrErr.StatusCode {
@@ -447,6 +638,13 @@ func (c *Controller) UnlinkSubnet(i *NetworkInterface, s *Subnet) error {
 // LinkSubnet will attempt to make this interface available on the specified
 // Subnet.
 func (c *Controller) LinkSubnet(i *NetworkInterface, args LinkSubnetArgs) error {
+	return c.LinkSubnetContext(context.Background(), i, args)
+}
+
+// LinkSubnetContext is like LinkSubnet but the request is bound to ctx.
+func (c *Controller) LinkSubnetContext(ctx context.Context, i *NetworkInterface, args LinkSubnetArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "LinkSubnet")
+	defer end(&err)
 	if err := args.Validate(); err != nil {
 		return errors.Trace(err)
 	}
@@ -455,7 +653,7 @@ func (c *Controller) LinkSubnet(i *NetworkInterface, args LinkSubnetArgs) error
 	params.Values.Add("Subnet", fmt.Sprint(args.Subnet.ID))
 	params.MaybeAdd("ip_address", args.IPAddress)
 	params.MaybeAddBool("default_gateway", args.DefaultGateway)
-	source, err := c.Post(i.ResourceURI, "link_subnet", params.Values)
+	source, err := c.PostContext(ctx, i.ResourceURI, "link_subnet", params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			switch svrErr.StatusCode {
@@ -482,6 +680,13 @@ func (c *Controller) LinkSubnet(i *NetworkInterface, args LinkSubnetArgs) error
 
 // Update the Name, mac address or VLAN.
 func (c *Controller) UpdateNetworkInterface(i *NetworkInterface, args UpdateInterfaceArgs) error {
+	return c.UpdateNetworkInterfaceContext(context.Background(), i, args)
+}
+
+// UpdateNetworkInterfaceContext is like UpdateNetworkInterface but the request is bound to ctx.
+func (c *Controller) UpdateNetworkInterfaceContext(ctx context.Context, i *NetworkInterface, args UpdateInterfaceArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "UpdateNetworkInterface")
+	defer end(&err)
 	var empty UpdateInterfaceArgs
 	if args == empty {
 		return fmt.Errorf("params are empty, and are required.")
@@ -489,7 +694,7 @@ func (c *Controller) UpdateNetworkInterface(i *NetworkInterface, args UpdateInte
 
 	params := UpdateInterfaceParams(args)
 
-	source, err := c.Put(i.ResourceURI, params.Values)
+	source, err := c.PutContext(ctx, i.ResourceURI, params.Values)
 	if err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			switch svrErr.StatusCode {
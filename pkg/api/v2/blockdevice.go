@@ -3,10 +3,12 @@
 
 package v2
 
+// BlockDevice is a physical or virtual disk of a machine.
 type BlockDevice struct {
 	ResourceURI string       `json:"resource_uri,omitempty"`
 	ID          int          `json:"ID,omitempty"`
 	Name        string       `json:"Name,omitempty"`
+	Type        string       `json:"type,omitempty"`
 	Model       string       `json:"Model,omitempty"`
 	IDPath      string       `json:"id_path,omitempty"`
 	Path        string       `json:"Path,omitempty"`
@@ -16,4 +18,5 @@ type BlockDevice struct {
 	UsedSize    uint64       `json:"used_size,omitempty"`
 	Size        uint64       `json:"Size,omitempty"`
 	Partitions  []*Partition `json:"Partitions,omitempty"`
+	FileSystem  *Filesystem  `json:"Filesystem,omitempty"`
 }
@@ -4,26 +4,22 @@
 package v2
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
-	"sync/atomic"
 
 	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
 	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
 	"github.com/juju/errors"
-	"github.com/juju/loggo"
 	"github.com/juju/utils/set"
 	"github.com/juju/version"
 )
 
 var (
-	logger = loggo.GetLogger("maas")
 	// The supported versions should be ordered from most desirable version to
 	// least as they will be tried in order.
 	supportedAPIVersions = []string{"2.0", "2.1", "2.3", "2.4"}
-	// Current request number. Informational only for logging.
-	requestNumber int64
 )
 
 // Controller represents an API connection to a maas. Since the API
@@ -35,13 +31,39 @@ type Controller struct {
 	// Capabilities returns a set of Capabilities as defined by the string
 	// constants.
 	Capabilities set.Strings
+	// Logger receives the requests and responses of the controller. When
+	// nil, DefaultLogger is used.
+	Logger Logger
+	// Redaction selects what is removed from the logged requests and
+	// responses. When nil, DefaultRedaction is used.
+	Redaction *Redaction
 }
 
 // ControllerArgs is an argument struct for passing the required parameters
 // to the NewController method.
 type ControllerArgs struct {
 	BaseURL string
-	APIKey  string
+	// BaseURLs lists the region controllers of the same maas to fail over
+	// between; see client.EndpointPool. BaseURL, when set, is used first
+	// and need not be repeated. The API version is taken from BaseURL, or
+	// the first of BaseURLs when BaseURL is empty. BaseURLs cannot be used
+	// with ClientOptions.Endpoints.
+	BaseURLs []string
+	APIKey   string
+	// APIVersion selects the API version when BaseURL does not include one.
+	// Like NewControllerWithVersion, it is used without being checked
+	// against the supported versions. When empty, the highest supported
+	// version available is used.
+	APIVersion string
+	// ClientOptions configures the HTTP client used to talk to the server.
+	// The controller takes ownership of ClientOptions.Endpoints: it sets the
+	// HTTPClient of the pool when unset, and points its endpoints at each
+	// API version probed in turn.
+	ClientOptions client.Options
+	// Logger and Redaction configure the logging of the controller; see
+	// Controller.
+	Logger    Logger
+	Redaction *Redaction
 }
 
 // NewController creates an authenticated Client to the maas API, and
@@ -53,14 +75,100 @@ type ControllerArgs struct {
 // If the APIKey is not valid, a NotValid error is returned.
 // If the credentials are incorrect, a PermissionError is returned.
 func NewController(args ControllerArgs) (*Controller, error) {
+	return NewControllerContext(context.Background(), args)
+}
+
+// NewControllerContext is like NewController but the version and credential
+// checks made against the server are bound to ctx.
+func NewControllerContext(ctx context.Context, args ControllerArgs) (*Controller, error) {
+	if args.BaseURL == "" && len(args.BaseURLs) > 0 {
+		args.BaseURL = args.BaseURLs[0]
+	}
 	base, apiVersion, includesVersion := client.SplitVersionedURL(args.BaseURL)
 	if includesVersion {
 		if !SupportedVersion(apiVersion) {
 			return nil, util.NewUnsupportedVersionError("version %s", apiVersion)
 		}
-		return NewControllerWithVersion(base, apiVersion, args.APIKey)
+		return newControllerWithVersion(ctx, base, apiVersion, args)
+	}
+	if args.APIVersion != "" {
+		return newControllerWithVersion(ctx, args.BaseURL, args.APIVersion, args)
 	}
-	return NewControllerUnknownVersion(args)
+	return NewControllerUnknownVersionContext(ctx, args)
+}
+
+// LoginArgs is an argument struct for passing the required parameters to
+// the NewControllerWithLogin method.
+type LoginArgs struct {
+	// BaseURL is the maas root URL, optionally including the API version
+	// as for ControllerArgs.
+	BaseURL  string
+	Username string
+	Password string
+	// TokenName names the API token obtained for the controller; see
+	// client.Credentials.
+	TokenName string
+	// APIVersion selects the API version when BaseURL does not include
+	// one. When empty, the API token is obtained with version 2.0 and the
+	// controller uses the highest supported version available.
+	APIVersion string
+	// ClientOptions configures the HTTP client used to talk to the server.
+	// The controller takes ownership of ClientOptions.Endpoints: it sets the
+	// HTTPClient of the pool when unset, and points its endpoints at each
+	// API version probed in turn.
+	ClientOptions client.Options
+	// Logger and Redaction configure the logging of the controller; see
+	// Controller.
+	Logger    Logger
+	Redaction *Redaction
+}
+
+// NewControllerWithLogin logs into maas with a username and password,
+// obtains an API token for the account and returns a Controller
+// authenticated with it.
+//
+// If the username or password is wrong, a PermissionError is returned.
+func NewControllerWithLogin(args LoginArgs) (*Controller, error) {
+	return NewControllerWithLoginContext(context.Background(), args)
+}
+
+// NewControllerWithLoginContext is like NewControllerWithLogin but the login
+// and the requests made against the server are bound to ctx.
+func NewControllerWithLoginContext(ctx context.Context, args LoginArgs) (*Controller, error) {
+	base, apiVersion, includesVersion := client.SplitVersionedURL(args.BaseURL)
+	if !includesVersion {
+		apiVersion = args.APIVersion
+	}
+	if apiVersion == "" {
+		apiVersion = supportedAPIVersions[0]
+	}
+	// Log in and talk to the controller with the same connection pool.
+	opts := args.ClientOptions
+	httpClient, err := opts.Client()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	opts.HTTPClient = httpClient
+	creds := client.Credentials{
+		Username:  args.Username,
+		Password:  args.Password,
+		TokenName: args.TokenName,
+	}
+	apiKey, err := client.Login(ctx, base, apiVersion, creds, opts)
+	if err != nil {
+		if util.IsPermissionError(err) {
+			return nil, err
+		}
+		return nil, util.NewUnexpectedError(err)
+	}
+	return NewControllerContext(ctx, ControllerArgs{
+		BaseURL:       args.BaseURL,
+		APIKey:        apiKey,
+		APIVersion:    args.APIVersion,
+		ClientOptions: opts,
+		Logger:        args.Logger,
+		Redaction:     args.Redaction,
+	})
 }
 
 func SupportedVersion(value string) bool {
@@ -73,12 +181,34 @@ func SupportedVersion(value string) bool {
 }
 
 func NewControllerWithVersion(baseURL, apiVersion, apiKey string) (*Controller, error) {
+	return NewControllerWithVersionContext(context.Background(), baseURL, apiVersion, apiKey)
+}
+
+// NewControllerWithVersionContext is like NewControllerWithVersion but the
+// requests made against the server are bound to ctx.
+func NewControllerWithVersionContext(ctx context.Context, baseURL, apiVersion, apiKey string) (*Controller, error) {
+	return newControllerWithVersion(ctx, baseURL, apiVersion, ControllerArgs{APIKey: apiKey})
+}
+
+// newControllerWithVersion creates a Controller for apiVersion at baseURL,
+// configured by the rest of args.
+func newControllerWithVersion(ctx context.Context, baseURL, apiVersion string, args ControllerArgs) (*Controller, error) {
+	args, err := args.withEndpoints(ctx, apiVersion)
+	if err != nil {
+		return nil, err
+	}
+	return newController(ctx, baseURL, apiVersion, args)
+}
+
+// newController is like newControllerWithVersion, for args returned by
+// withEndpoints.
+func newController(ctx context.Context, baseURL, apiVersion string, args ControllerArgs) (*Controller, error) {
 	major, minor, err := version.ParseMajorMinor(apiVersion)
 	// We should not Get an error here. See the test.
 	if err != nil {
 		return nil, errors.Errorf("bad version defined in supported versions: %q", apiVersion)
 	}
-	client, err := client.NewAuthenticatedMAASClient(client.AddAPIVersionToURL(baseURL, apiVersion), apiKey)
+	client, err := client.NewAuthenticatedMAASClientWithOptions(client.AddAPIVersionToURL(baseURL, apiVersion), args.APIKey, args.ClientOptions)
 	if err != nil {
 		// If the credentials aren't valid, return now.
 		if errors.IsNotValid(err) {
@@ -92,25 +222,120 @@ func NewControllerWithVersion(baseURL, apiVersion, apiKey string) (*Controller,
 		Major: major,
 		Minor: minor,
 	}
-	controller := &Controller{Client: client, APIVersion: controllerVersion}
-	controller.Capabilities, err = controller.GetAPIVersionInfo()
+	controller := &Controller{
+		Client:     client,
+		APIVersion: controllerVersion,
+		Logger:     args.Logger,
+		Redaction:  args.Redaction,
+	}
+	controller.Capabilities, err = controller.GetAPIVersionInfoContext(ctx)
 	if err != nil {
-		logger.Debugf("nread version failed: %#v", err)
+		controller.logger().Log(ctx, LevelDebug, "read version failed", Field{"error", err.Error()})
 		return nil, err
 	}
 
-	if err := controller.checkCreds(); err != nil {
+	if err := controller.checkCreds(ctx); err != nil {
 		return nil, err
 	}
 	return controller, nil
 }
 
+// withEndpoints returns args with the HTTP client and the pool of endpoints
+// to use for apiVersion, shared by every controller created from them. The
+// health of the endpoints is checked once, so that the first requests go to
+// a healthy one. When none is, those requests report why.
+func (args ControllerArgs) withEndpoints(ctx context.Context, apiVersion string) (ControllerArgs, error) {
+	opts := args.ClientOptions
+	httpClient, err := opts.Client()
+	if err != nil {
+		return args, errors.Trace(err)
+	}
+	opts.HTTPClient = httpClient
+	opts.Endpoints, err = args.endpointPool(apiVersion)
+	if err != nil {
+		return args, err
+	}
+	args.ClientOptions = opts
+	args.BaseURLs = nil
+	if pool := opts.Endpoints; pool != nil {
+		if pool.HTTPClient == nil {
+			pool.HTTPClient = httpClient
+		}
+		if err := pool.CheckHealth(ctx); err != nil {
+			args.logger().Log(ctx, LevelDebug, "endpoint health check failed", Field{"error", err.Error()})
+		}
+	}
+	return args, nil
+}
+
+func (args ControllerArgs) logger() Logger {
+	if args.Logger == nil {
+		return DefaultLogger()
+	}
+	return args.Logger
+}
+
+// endpointPool returns the pool of endpoints for apiVersion: the one of
+// ClientOptions, or a new one of BaseURL and BaseURLs.
+func (args ControllerArgs) endpointPool(apiVersion string) (*client.EndpointPool, error) {
+	if len(args.BaseURLs) == 0 {
+		return args.ClientOptions.Endpoints, nil
+	}
+	if args.ClientOptions.Endpoints != nil {
+		return nil, errors.NotValidf("BaseURLs with ClientOptions.Endpoints")
+	}
+	urls := args.BaseURLs
+	if args.BaseURL != "" {
+		urls = append([]string{args.BaseURL}, urls...)
+	}
+	return newEndpointPool(apiVersion, urls)
+}
+
+// newEndpointPool returns the pool of the region controllers at baseURLs,
+// for apiVersion. Duplicate URLs are only used once.
+func newEndpointPool(apiVersion string, baseURLs []string) (*client.EndpointPool, error) {
+	seen := set.NewStrings()
+	var versionedURLs []string
+	for _, baseURL := range baseURLs {
+		if base, _, includesVersion := client.SplitVersionedURL(baseURL); includesVersion {
+			baseURL = base
+		}
+		versionedURL := client.AddAPIVersionToURL(baseURL, apiVersion)
+		if !seen.Contains(versionedURL) {
+			seen.Add(versionedURL)
+			versionedURLs = append(versionedURLs, versionedURL)
+		}
+	}
+	pool, err := client.NewEndpointPool(versionedURLs...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return pool, nil
+}
+
 func NewControllerUnknownVersion(args ControllerArgs) (*Controller, error) {
+	return NewControllerUnknownVersionContext(context.Background(), args)
+}
+
+// NewControllerUnknownVersionContext is like NewControllerUnknownVersion but
+// the version probes made against the server are bound to ctx.
+func NewControllerUnknownVersionContext(ctx context.Context, args ControllerArgs) (*Controller, error) {
 	// For now we don't need to test multiple versions. It is expected that at
 	// some time in the future, we will try the most up to date version and then
 	// work our way backwards.
+	// Every version probed shares one http.Client, and so one connection pool,
+	// and one pool of endpoints, pointed at each version in turn.
+	args, err := args.withEndpoints(ctx, supportedAPIVersions[0])
+	if err != nil {
+		return nil, err
+	}
 	for _, apiVersion := range supportedAPIVersions {
-		controller, err := NewControllerWithVersion(args.BaseURL, apiVersion, args.APIKey)
+		if pool := args.ClientOptions.Endpoints; pool != nil {
+			if err := pool.SetAPIVersion(apiVersion); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		controller, err := newController(ctx, args.BaseURL, apiVersion, args)
 		switch {
 		case err == nil:
 			return controller, nil
@@ -125,8 +350,8 @@ func NewControllerUnknownVersion(args ControllerArgs) (*Controller, error) {
 	return nil, util.NewUnsupportedVersionError("ControllerInterface at %s does not support any of %s", args.BaseURL, supportedAPIVersions)
 }
 
-func (c *Controller) checkCreds() error {
-	if _, err := c.Get("users", "whoami", nil); err != nil {
+func (c *Controller) checkCreds(ctx context.Context) error {
+	if _, err := c.GetContext(ctx, "users", "whoami", nil); err != nil {
 		if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
 			if svrErr.StatusCode == http.StatusUnauthorized {
 				return errors.Wrap(err, util.NewPermissionError(svrErr.BodyMessage))
@@ -138,20 +363,29 @@ func (c *Controller) checkCreds() error {
 }
 
 func (c Controller) Put(path string, params url.Values) ([]byte, error) {
+	return c.PutContext(context.Background(), path, params)
+}
+
+// PutContext is like Put but the request is bound to ctx.
+func (c *Controller) PutContext(ctx context.Context, path string, params url.Values) ([]byte, error) {
 	path = util.EnsureTrailingSlash(path)
 	requestID := nextRequestID()
-	logger.Tracef("request %x: PUT %s%s, params: %s", requestID, c.Client.APIURL, path, params.Encode())
-	bytes, err := c.Client.Put(&url.URL{Path: path}, params)
+	c.logRequest(ctx, requestID, "PUT", path, "", params)
+	bytes, err := c.Client.PutContext(client.WithRequestID(ctx, requestID), &url.URL{Path: path}, params)
+	c.logResponse(ctx, requestID, bytes, err)
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
 		return nil, err
 	}
 	return bytes, nil
 }
 
 func (c *Controller) Post(path, op string, params url.Values) ([]byte, error) {
-	bytes, err := c.postRaw(path, op, params, nil)
+	return c.PostContext(context.Background(), path, op, params)
+}
+
+// PostContext is like Post but the request is bound to ctx.
+func (c *Controller) PostContext(ctx context.Context, path, op string, params url.Values) ([]byte, error) {
+	bytes, err := c.postRaw(ctx, path, op, params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -159,70 +393,146 @@ func (c *Controller) Post(path, op string, params url.Values) ([]byte, error) {
 }
 
 func (c *Controller) PostFile(path, op string, params url.Values, fileContent []byte) ([]byte, error) {
+	return c.PostFileContext(context.Background(), path, op, params, fileContent)
+}
+
+// PostFileContext is like PostFile but the request is bound to ctx.
+func (c *Controller) PostFileContext(ctx context.Context, path, op string, params url.Values, fileContent []byte) ([]byte, error) {
 	// Only one File is ever sent at a time.
 	files := map[string][]byte{"file": fileContent}
-	return c.postRaw(path, op, params, files)
+	return c.postRaw(ctx, path, op, params, files)
+}
+
+// PostStream posts a multipart upload whose files are streamed from their
+// sources instead of being held in memory.
+func (c *Controller) PostStream(path, op string, upload client.Upload) ([]byte, error) {
+	return c.PostStreamContext(context.Background(), path, op, upload)
 }
 
-func (c *Controller) postRaw(path, op string, params url.Values, files map[string][]byte) ([]byte, error) {
+// PostStreamContext is like PostStream but the request is bound to ctx.
+func (c *Controller) PostStreamContext(ctx context.Context, path, op string, upload client.Upload) ([]byte, error) {
 	path = util.EnsureTrailingSlash(path)
-	url := &url.URL{Path: path}
 	requestID := nextRequestID()
-	if logger.IsTraceEnabled() {
-		opArg := ""
-		if op != "" {
-			opArg = "?op=" + op
+	if c.logger().Enabled(ctx, LevelTrace) {
+		names := make([]string, len(upload.Files))
+		for i, file := range upload.Files {
+			names[i] = file.Name
 		}
-		logger.Tracef("request %x: Post %s%s%s, params=%s", requestID, c.Client.APIURL, path, opArg, params.Encode())
+		c.logRequest(ctx, requestID, "POST", path, op, upload.Params, Field{"files", names})
 	}
-	bytes, err := c.Client.Post(url, op, params, files)
+	bytes, err := c.Client.PostStreamContext(client.WithRequestID(ctx, requestID), &url.URL{Path: path}, op, upload)
+	c.logResponse(ctx, requestID, bytes, err)
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
 		return nil, err
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
 	return bytes, nil
 }
 
-func (c *Controller) Delete(path string) error {
+func (c *Controller) postRaw(ctx context.Context, path, op string, params url.Values, files map[string][]byte) ([]byte, error) {
 	path = util.EnsureTrailingSlash(path)
 	url := &url.URL{Path: path}
 	requestID := nextRequestID()
-	logger.Tracef("request %x: DELETE %s%s", requestID, c.Client.APIURL, path)
-	err := c.Client.Delete(url)
+	c.logRequest(ctx, requestID, "POST", path, op, params)
+	bytes, err := c.Client.PostContext(client.WithRequestID(ctx, requestID), url, op, params, files)
+	c.logResponse(ctx, requestID, bytes, err)
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
-		return err
+		return nil, err
 	}
-	logger.Tracef("response %x: complete", requestID)
-	return nil
+	return bytes, nil
+}
+
+func (c *Controller) Delete(path string) error {
+	return c.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext is like Delete but the request is bound to ctx.
+func (c *Controller) DeleteContext(ctx context.Context, path string) error {
+	path = util.EnsureTrailingSlash(path)
+	url := &url.URL{Path: path}
+	requestID := nextRequestID()
+	c.logRequest(ctx, requestID, "DELETE", path, "", nil)
+	err := c.Client.DeleteContext(client.WithRequestID(ctx, requestID), url)
+	c.logResponse(ctx, requestID, nil, err)
+	return err
 }
 
 func (c *Controller) Get(path string, op string, params url.Values) ([]byte, error) {
+	return c.GetContext(context.Background(), path, op, params)
+}
+
+// GetContext is like Get but the request is bound to ctx.
+func (c *Controller) GetContext(ctx context.Context, path string, op string, params url.Values) ([]byte, error) {
 	path = util.EnsureTrailingSlash(path)
 	url := &url.URL{Path: path}
 	requestID := nextRequestID()
-	if logger.IsTraceEnabled() {
-		var query string
-		if params != nil {
-			query = "?" + params.Encode()
-		}
-		logger.Tracef("request %x: Get %s%s%s", requestID, c.Client.APIURL, path, query)
-	}
-	bytes, err := c.Client.Get(url, op, params)
+	c.logRequest(ctx, requestID, "GET", path, op, params)
+	bytes, err := c.Client.GetContext(client.WithRequestID(ctx, requestID), url, op, params)
+	c.logResponse(ctx, requestID, bytes, err)
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
 		return nil, err
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
 	return bytes, nil
 }
 
+func (c *Controller) logger() Logger {
+	if c.Logger == nil {
+		return DefaultLogger()
+	}
+	return c.Logger
+}
+
+func (c *Controller) redaction() *Redaction {
+	if c.Redaction == nil {
+		return DefaultRedaction()
+	}
+	return c.Redaction
+}
+
+// logRequest logs a request at trace level, with its parameters redacted.
+func (c *Controller) logRequest(ctx context.Context, requestID int64, method, path, op string, params url.Values, extra ...Field) {
+	logger := c.logger()
+	if !logger.Enabled(ctx, LevelTrace) {
+		return
+	}
+	fields := []Field{
+		{"request_id", requestID},
+		{"method", method},
+		{"url", c.Client.APIURL.String() + path},
+	}
+	if op != "" {
+		fields = append(fields, Field{"op", op})
+	}
+	if len(params) > 0 {
+		fields = append(fields, Field{"params", c.redaction().RedactParams(op, params)})
+	}
+	logger.Log(ctx, LevelTrace, "request", append(fields, extra...)...)
+}
+
+// logResponse logs the outcome of a request at trace level, with the body
+// redacted and truncated. The body of an error response is logged the same
+// way, with its status code, rather than the message of the error that
+// quotes it.
+func (c *Controller) logResponse(ctx context.Context, requestID int64, body []byte, err error) {
+	logger := c.logger()
+	if !logger.Enabled(ctx, LevelTrace) {
+		return
+	}
+	fields := []Field{{"request_id", requestID}}
+	if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
+		fields = append(fields,
+			Field{"status", svrErr.StatusCode},
+			Field{"error", c.redaction().RedactBody([]byte(svrErr.BodyMessage))},
+		)
+	} else if err != nil {
+		fields = append(fields, Field{"error", err.Error()})
+	} else if body != nil {
+		fields = append(fields, Field{"body", c.redaction().RedactBody(body)})
+	}
+	logger.Log(ctx, LevelTrace, "response", fields...)
+}
+
 func nextRequestID() int64 {
-	return atomic.AddInt64(&requestNumber, 1)
+	return client.NextRequestID()
 }
 
 func indicatesUnsupportedVersion(err error) bool {
@@ -244,7 +554,15 @@ func indicatesUnsupportedVersion(err error) bool {
 }
 
 func (c *Controller) GetAPIVersionInfo() (set.Strings, error) {
-	parsedBytes, err := c.Get("version", "", nil)
+	return c.GetAPIVersionInfoContext(context.Background())
+}
+
+// GetAPIVersionInfoContext is like GetAPIVersionInfo but the request is bound
+// to ctx.
+func (c *Controller) GetAPIVersionInfoContext(ctx context.Context) (_ set.Strings, err error) {
+	ctx, end := c.startSpan(ctx, "GetAPIVersionInfo")
+	defer end(&err)
+	parsedBytes, err := c.GetContext(ctx, "version", "", nil)
 	if indicatesUnsupportedVersion(err) {
 		return nil, util.WrapWithUnsupportedVersionError(err)
 	} else if err != nil {
@@ -5,9 +5,14 @@ package v2
 
 import (
 	"bytes"
+	"context"
+	"encoding/pem"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
 	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
@@ -71,6 +76,20 @@ func TestNewControllerNoSupport(t *testing.T) {
 	assert.True(t, util.IsUnsupportedVersionError(err))
 }
 
+func TestNewControllerMalformedVersion(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.InjectFaults(1).Add(client.Fault{Kind: client.FaultMalformedJSON})
+	server.Start()
+	defer server.Close()
+
+	_, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	assert.True(t, util.IsDeserializationError(err), "%v", err)
+}
+
 func TestNewControllerBadCreds(t *testing.T) {
 	server := client.NewSimpleServer()
 	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusUnauthorized, "naughty")
@@ -118,6 +137,243 @@ func TestNewControllerKnownVersion(t *testing.T) {
 	})
 }
 
+func TestNewControllerClientOptions(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+	httpClient := &http.Client{}
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{HTTPClient: httpClient},
+	})
+	assert.Nil(t, err)
+	assert.True(t, controller.Client.HTTPClient == httpClient)
+}
+
+func TestNewControllerTLS(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.StartTLS()
+	defer server.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	_, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	assert.NotNil(t, err)
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{TLS: &client.TLSOptions{CAPEM: caPEM}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, controller.APIVersion, version.Number{Major: 2, Minor: 0})
+}
+
+func TestNewControllerAPIVersionArg(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.1/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.1/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:    server.URL,
+		APIKey:     "fake:as:key",
+		APIVersion: "2.1",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, controller.APIVersion, version.Number{Major: 2, Minor: 1})
+}
+
+func TestNewControllerBaseURLs(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	server := client.NewSimpleServer()
+	// One version response is for the health check of the endpoints.
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	server.Start()
+	defer server.Close()
+
+	controller, err := NewController(ControllerArgs{
+		BaseURLs:      []string{dead.URL + "/api/2.0/", server.URL, dead.URL},
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{RetryPolicy: &client.BackoffPolicy{BaseDelay: time.Millisecond}},
+	})
+	assert.Nil(t, err)
+	machines, err := controller.Machines(MachinesArgs{})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, machines)
+	assert.Equal(t, controller.Client.Endpoints.Status(), []client.EndpointStatus{
+		{URL: dead.URL + "/api/2.0/", Healthy: false, Active: false},
+		{URL: server.URL + "/api/2.0/", Healthy: true, Active: true},
+	})
+}
+
+func TestNewControllerBaseURLsChecksHealth(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	// No retry policy: the dead endpoint is skipped because the health
+	// check found it unhealthy, not because a request failed on it.
+	controller, err := NewController(ControllerArgs{
+		BaseURL:  dead.URL + "/api/2.0/",
+		BaseURLs: []string{server.URL},
+		APIKey:   "fake:as:key",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, controller.Client.Endpoints.Status(), []client.EndpointStatus{
+		{URL: dead.URL + "/api/2.0/", Healthy: false, Active: false},
+		{URL: server.URL + "/api/2.0/", Healthy: true, Active: true},
+	})
+}
+
+func TestNewControllerBaseURLsWithEndpoints(t *testing.T) {
+	pool, err := client.NewEndpointPool("http://region-1.example.com/MAAS/api/2.0/")
+	assert.Nil(t, err)
+
+	_, err = NewController(ControllerArgs{
+		BaseURLs:      []string{"http://region-2.example.com/MAAS/api/2.0/"},
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{Endpoints: pool},
+	})
+
+	assert.True(t, errors.IsNotValid(err), "%v", err)
+}
+
+func TestNewControllerEndpointsKeepsHTTPClient(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+	pool, err := client.NewEndpointPool(server.URL + "/api/2.0/")
+	assert.Nil(t, err)
+	httpClient := &http.Client{}
+	pool.HTTPClient = httpClient
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:       server.URL + "/api/2.0/",
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{Endpoints: pool},
+	})
+
+	assert.Nil(t, err)
+	assert.True(t, controller.Client.Endpoints == pool)
+	assert.True(t, pool.HTTPClient == httpClient)
+}
+
+func TestNewControllerUnknownVersionBaseURLs(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	server := client.NewSimpleServer()
+	// The endpoints are checked once, before the versions are probed: the
+	// 404 of 2.0 does not make the server unhealthy.
+	server.AddGetResponse("/api/2.1/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.1/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:  dead.URL,
+		BaseURLs: []string{server.URL},
+		APIKey:   "fake:as:key",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, controller.APIVersion, version.Number{Major: 2, Minor: 1})
+	assert.Equal(t, controller.Client.Endpoints.Status(), []client.EndpointStatus{
+		{URL: dead.URL + "/api/2.1/", Healthy: false, Active: false},
+		{URL: server.URL + "/api/2.1/", Healthy: true, Active: true},
+	})
+}
+
+func TestControllerCassette(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	server.Start()
+	recorder := &client.Recorder{}
+	controller, err := NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{Transport: recorder},
+	})
+	assert.Nil(t, err)
+	recorded, err := controller.Machines(MachinesArgs{})
+	assert.Nil(t, err)
+	server.Close()
+
+	replayer := client.NewReplayer(recorder.Cassette())
+	controller, err = NewController(ControllerArgs{
+		BaseURL:       "http://maas.invalid/",
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{Transport: replayer},
+	})
+	assert.Nil(t, err)
+	replayed, err := controller.Machines(MachinesArgs{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, len(replayed), len(recorded))
+	assert.Equal(t, replayed[0].SystemID, recorded[0].SystemID)
+	assert.Empty(t, replayer.Unplayed())
+}
+
+func TestNewControllerWithLogin(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	login := client.NewLoginHandler("admin", "secret", "login:as:key")
+	server.SetLoginHandler(login)
+	server.Start()
+	defer server.Close()
+
+	controller, err := NewControllerWithLogin(LoginArgs{
+		BaseURL:   server.URL,
+		Username:  "admin",
+		Password:  "secret",
+		TokenName: "ci",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, controller.APIVersion, version.Number{Major: 2, Minor: 0})
+	assert.Equal(t, login.Tokens["ci"], "login:as:key")
+	assert.Contains(t, server.LastRequest().Header.Get("Authorization"), `oauth_token="as"`)
+}
+
+func TestNewControllerWithLoginBadPassword(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.SetLoginHandler(client.NewLoginHandler("admin", "secret", "login:as:key"))
+	server.Start()
+	defer server.Close()
+
+	_, err := NewControllerWithLogin(LoginArgs{
+		BaseURL:  server.URL,
+		Username: "admin",
+		Password: "wrong",
+	})
+	assert.True(t, util.IsPermissionError(err))
+}
+
 func TestNewControllerUnsupportedVersionSpecified(t *testing.T) {
 	server := client.NewSimpleServer()
 	// Ensure the server would actually respond to the version if it
@@ -415,6 +671,59 @@ func TestControllerMachines(t *testing.T) {
 	assert.Len(t, machines, 3)
 }
 
+func TestControllerMachinesContextCancelled(t *testing.T) {
+	server, controller := createTestServerController(t)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	machines, err := controller.MachinesContext(ctx, MachinesArgs{})
+	assert.True(t, util.IsUnexpectedError(err))
+	assert.Nil(t, machines)
+}
+
+func TestControllerMiddleware(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	var calls []client.Call
+	controller.Client.Middleware = []client.Middleware{func(next client.Doer) client.Doer {
+		return client.DoerFunc(func(call *client.Call) (*client.Result, error) {
+			calls = append(calls, *call)
+			return next.Do(call)
+		})
+	}}
+
+	_, err := controller.Machines(MachinesArgs{})
+	assert.Nil(t, err)
+
+	assert.Len(t, calls, 1)
+	assert.Equal(t, calls[0].Path, "machines/")
+	assert.NotZero(t, calls[0].RequestID)
+}
+
+func TestControllerMetrics(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	metrics := client.NewMetrics()
+
+	_, err := NewController(ControllerArgs{
+		BaseURL:       server.URL,
+		APIKey:        "fake:as:key",
+		ClientOptions: client.Options{Metrics: metrics},
+	})
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, metrics.WriteText(&buf))
+	assert.Contains(t, buf.String(), `maas_client_requests_total{method="GET",op="",path="version/"} 1`)
+	assert.Contains(t, buf.String(), `maas_client_requests_total{method="GET",op="whoami",path="users/"} 1`)
+}
+
 func TestControllerMachinesFilter(t *testing.T) {
 	hostName := "untasted-markita"
 	response := "[" + machineResponse + "]"
@@ -643,6 +952,31 @@ func TestControllerAllocateMachineNoMatch(t *testing.T) {
 	assert.True(t, util.IsNoMatchError(err))
 }
 
+func TestControllerAllocateMachineTraced(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "boo")
+	server.Start()
+	defer server.Close()
+	controller := getController(t, server)
+	tracer := &client.RecordingTracer{}
+	controller.Client.Tracer = tracer
+
+	_, _, err := controller.AllocateMachine(AllocateMachineArgs{})
+
+	spans := tracer.Spans()
+	assert.Len(t, spans, 2)
+	operation, attempt := spans[0], spans[1]
+	assert.Equal(t, operation.Name, "maas.AllocateMachine")
+	assert.Equal(t, operation.Attributes[AttributeOperation], "AllocateMachine")
+	assert.Equal(t, operation.Errors, []error{err})
+	assert.True(t, operation.Ended)
+	assert.Equal(t, attempt.Parent, operation.Context)
+	assert.Equal(t, attempt.Attributes[client.AttributeStatusCode], http.StatusConflict)
+	assert.Equal(t, server.LastRequest().Header.Get(client.TraceParentHeader), attempt.Context.TraceParent())
+}
+
 func TestControllerAllocateMachineUnexpected(t *testing.T) {
 	server := client.NewSimpleServer()
 	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
@@ -777,6 +1111,69 @@ func TestControllerGetFile(t *testing.T) {
 	assert.Equal(t, file.AnonymousURI.RequestURI(), "/MAAS/api/2.0/files/?op=get_by_key&key=88e64b76-fb82-11e5-932f-52540051bf22")
 }
 
+func TestControllerDownloadFile(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	server.AddGetResponse("/api/2.0/files/?filename=testing&op=get", http.StatusOK, "file content")
+	controller := getController(t, server)
+	var buf bytes.Buffer
+	n, err := controller.DownloadFile(DownloadFileArgs{Filename: "testing", Writer: &buf, Size: 12})
+	assert.Nil(t, err)
+
+	assert.EqualValues(t, n, 12)
+	assert.Equal(t, buf.String(), "file content")
+	assert.NotEqual(t, server.LastRequest().Header.Get("Authorization"), "")
+}
+
+func TestControllerDownloadFileAnonymous(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, fileResponse)
+	server.AddGetResponse("/MAAS/api/2.0/files/?key=88e64b76-fb82-11e5-932f-52540051bf22&op=get_by_key", http.StatusOK, "file content")
+	controller := getController(t, server)
+	file, err := controller.GetFile("testing")
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	_, err = controller.DownloadFile(DownloadFileArgs{AnonymousURI: file.AnonymousURI, Writer: &buf})
+	assert.Nil(t, err)
+
+	assert.Equal(t, buf.String(), "file content")
+	assert.Equal(t, server.LastRequest().Header.Get("Authorization"), "")
+}
+
+func TestControllerDownloadFileMissing(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	controller := getController(t, server)
+	_, err := controller.DownloadFile(DownloadFileArgs{Filename: "missing", Writer: &bytes.Buffer{}})
+	assert.True(t, util.IsNoMatchError(err))
+}
+
+func TestControllerDownloadFileSizeMismatch(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	server.AddGetResponse("/api/2.0/files/?filename=testing&op=get", http.StatusOK, "file content")
+	controller := getController(t, server)
+	_, err := controller.DownloadFile(DownloadFileArgs{Filename: "testing", Writer: &bytes.Buffer{}, Size: 100})
+	assert.True(t, errors.IsNotValid(err))
+}
+
 func TestControllerGetFileMissing(t *testing.T) {
 	server := client.NewSimpleServer()
 	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
@@ -841,9 +1238,43 @@ func TestControllerAddFileReader(t *testing.T) {
 	assertFile(t, request, "foo.txt", "test\n")
 }
 
+// retryOnce retries every failed request once, without waiting.
+type retryOnce struct{}
+
+func (retryOnce) Retry(attempt client.RetryAttempt) (time.Duration, bool) {
+	return 0, attempt.Number == 1
+}
+
+func TestControllerAddFileSeekerProgress(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.Start()
+	defer server.Close()
+
+	// The first attempt fails and is retried from the start of the file.
+	server.AddPostResponse("/api/2.0/files/?op=", http.StatusBadGateway, "")
+	server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	controller := getController(t, server)
+	controller.Client.RetryPolicy = retryOnce{}
+	var sent, total int64
+	err := controller.AddFile(AddFileArgs{
+		Filename: "foo.txt",
+		Reader:   strings.NewReader("test\n extra over length ignored"),
+		Length:   5,
+		Progress: func(s, t int64) { sent, total = s, t },
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, server.RequestCount(), 4)
+	assertFile(t, server.LastRequest(), "foo.txt", "test\n")
+	assert.EqualValues(t, sent, 5)
+	assert.EqualValues(t, total, 5)
+}
+
 func assertFile(t *testing.T, request *http.Request, filename, content string) {
 	form := request.Form
-	assert.Equal(t, filename, form.Get("Filename"))
+	assert.Equal(t, filename, form.Get("filename"))
 
 	fileHeader := request.MultipartForm.File["file"][0]
 	f, err := fileHeader.Open()
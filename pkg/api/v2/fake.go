@@ -0,0 +1,946 @@
+package v2
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeCall is a call made to a FakeController: the name of the operation,
+// without its Context suffix, and its arguments, without the context.
+type FakeCall struct {
+	Method string
+	Args   []interface{}
+}
+
+// FakeController is a hand-written implementation of Services for the unit
+// tests of code using a Controller. It records the calls made to it, and
+// answers them with the function set for the operation, or with zero values
+// and no error when that is nil. An operation and its Context variant share
+// the same function.
+type FakeController struct {
+	MachinesFunc        func(ctx context.Context, args MachinesArgs) ([]Machine, error)
+	AllocateMachineFunc func(ctx context.Context, args AllocateMachineArgs) (*Machine, ConstraintMatches, error)
+	ReleaseMachinesFunc func(ctx context.Context, args ReleaseMachinesArgs) error
+	DeployFunc          func(ctx context.Context, m *Machine, args DeployMachineArgs) error
+	SetOwnerDataFunc    func(ctx context.Context, m *Machine, ownerData map[string]string) error
+
+	CommissionMachineFunc              func(ctx context.Context, m *Machine, args CommissionMachineArgs) error
+	AbortMachineFunc                   func(ctx context.Context, m *Machine, args AbortMachineArgs) error
+	ReleaseMachineFunc                 func(ctx context.Context, m *Machine, args ReleaseMachineArgs) error
+	PowerOnMachineFunc                 func(ctx context.Context, m *Machine, args PowerOnMachineArgs) error
+	PowerOffMachineFunc                func(ctx context.Context, m *Machine, args PowerOffMachineArgs) error
+	MarkMachineBrokenFunc              func(ctx context.Context, m *Machine, args MarkMachineBrokenArgs) error
+	MarkMachineFixedFunc               func(ctx context.Context, m *Machine, args MarkMachineFixedArgs) error
+	EnterRescueModeFunc                func(ctx context.Context, m *Machine) error
+	ExitRescueModeFunc                 func(ctx context.Context, m *Machine) error
+	RestoreDefaultConfigurationFunc    func(ctx context.Context, m *Machine) error
+	RestoreNetworkingConfigurationFunc func(ctx context.Context, m *Machine) error
+	SetStorageLayoutFunc               func(ctx context.Context, m *Machine, args SetStorageLayoutArgs) error
+	RestoreStorageConfigurationFunc    func(ctx context.Context, m *Machine) error
+	ClearDefaultGatewaysFunc           func(ctx context.Context, m *Machine) error
+	MountSpecialFunc                   func(ctx context.Context, m *Machine, args MountSpecialArgs) error
+	UnmountSpecialFunc                 func(ctx context.Context, m *Machine, mountPoint string) error
+	MachineDetailsFunc                 func(ctx context.Context, m *Machine) ([]byte, error)
+	CurtinConfigFunc                   func(ctx context.Context, m *Machine) ([]byte, error)
+	PowerParametersFunc                func(ctx context.Context, m *Machine) (map[string]interface{}, error)
+	WaitForStatusFunc                  func(ctx context.Context, systemID string, targets []NodeStatus, opts WaitOptions) (*Machine, error)
+	StartDeployFunc                    func(ctx context.Context, m *Machine, args DeployMachineArgs, opts WaitOptions) (*Operation, error)
+	StartCommissionFunc                func(ctx context.Context, m *Machine, args CommissionMachineArgs, opts WaitOptions) (*Operation, error)
+	StartReleaseFunc                   func(ctx context.Context, m *Machine, args ReleaseMachineArgs, opts WaitOptions) (*Operation, error)
+
+	NodesFunc      func(ctx context.Context, args NodesArgs) ([]Node, error)
+	CreateNodeFunc func(ctx context.Context, args CreateNodeArgs) (*Node, error)
+	DevicesFunc    func(ctx context.Context, args DevicesArgs) ([]Device, error)
+
+	FabricsFunc                func(ctx context.Context) ([]Fabric, error)
+	SpacesFunc                 func(ctx context.Context) ([]Space, error)
+	StaticRoutesFunc           func(ctx context.Context) ([]StaticRoute, error)
+	ZonesFunc                  func(ctx context.Context) ([]Zone, error)
+	CreateInterfaceFunc        func(ctx context.Context, d *Node, args CreateNodeNetworkInterfaceArgs) (*NetworkInterface, error)
+	LinkSubnetFunc             func(ctx context.Context, i *NetworkInterface, args LinkSubnetArgs) error
+	UnlinkSubnetFunc           func(ctx context.Context, i *NetworkInterface, s *Subnet) error
+	UpdateNetworkInterfaceFunc func(ctx context.Context, i *NetworkInterface, args UpdateInterfaceArgs) error
+
+	GetFileFunc         func(ctx context.Context, filename string) (*File, error)
+	ReadFileContentFunc func(ctx context.Context, f *File) ([]byte, error)
+	DownloadFileFunc    func(ctx context.Context, args DownloadFileArgs) (int64, error)
+	AddFileFunc         func(ctx context.Context, args AddFileArgs) error
+
+	FormatBlockDeviceFunc    func(ctx context.Context, d *BlockDevice, args FormatArgs) error
+	UnformatBlockDeviceFunc  func(ctx context.Context, d *BlockDevice) error
+	MountBlockDeviceFunc     func(ctx context.Context, d *BlockDevice, args MountArgs) error
+	UnmountBlockDeviceFunc   func(ctx context.Context, d *BlockDevice) error
+	SetBootDiskFunc          func(ctx context.Context, d *BlockDevice) error
+	AddBlockDeviceTagFunc    func(ctx context.Context, d *BlockDevice, tag string) error
+	RemoveBlockDeviceTagFunc func(ctx context.Context, d *BlockDevice, tag string) error
+	DeleteBlockDeviceFunc    func(ctx context.Context, d *BlockDevice) error
+	CreatePartitionFunc      func(ctx context.Context, d *BlockDevice, args CreatePartitionArgs) (*Partition, error)
+	DeletePartitionFunc      func(ctx context.Context, d *BlockDevice, p *Partition) error
+	FormatPartitionFunc      func(ctx context.Context, p *Partition, args FormatArgs) error
+	UnformatPartitionFunc    func(ctx context.Context, p *Partition) error
+	MountPartitionFunc       func(ctx context.Context, p *Partition, args MountArgs) error
+	UnmountPartitionFunc     func(ctx context.Context, p *Partition) error
+	CreateVolumeGroupFunc    func(ctx context.Context, m *Machine, args CreateVolumeGroupArgs) (*VolumeGroup, error)
+	CreateLogicalVolumeFunc  func(ctx context.Context, vg *VolumeGroup, args CreateLogicalVolumeArgs) (*BlockDevice, error)
+
+	BootResourcesFunc func(ctx context.Context) ([]*BootResource, error)
+
+	mu    sync.Mutex
+	calls []FakeCall
+}
+
+func (f *FakeController) record(method string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, FakeCall{Method: method, Args: args})
+}
+
+// Calls returns the calls made so far, in order.
+func (f *FakeController) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeCall(nil), f.calls...)
+}
+
+// CallsTo returns the calls made so far to method, in order.
+func (f *FakeController) CallsTo(method string) []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []FakeCall
+	for _, call := range f.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets the calls made so far.
+func (f *FakeController) ResetCalls() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+// Machines implements MachineService.
+func (f *FakeController) Machines(args MachinesArgs) ([]Machine, error) {
+	return f.MachinesContext(context.Background(), args)
+}
+
+// MachinesContext implements MachineService.
+func (f *FakeController) MachinesContext(ctx context.Context, args MachinesArgs) ([]Machine, error) {
+	f.record("Machines", args)
+	if f.MachinesFunc == nil {
+		return nil, nil
+	}
+	return f.MachinesFunc(ctx, args)
+}
+
+// AllocateMachine implements MachineService.
+func (f *FakeController) AllocateMachine(args AllocateMachineArgs) (*Machine, ConstraintMatches, error) {
+	return f.AllocateMachineContext(context.Background(), args)
+}
+
+// AllocateMachineContext implements MachineService.
+func (f *FakeController) AllocateMachineContext(ctx context.Context, args AllocateMachineArgs) (*Machine, ConstraintMatches, error) {
+	f.record("AllocateMachine", args)
+	if f.AllocateMachineFunc == nil {
+		return nil, ConstraintMatches{}, nil
+	}
+	return f.AllocateMachineFunc(ctx, args)
+}
+
+// ReleaseMachines implements MachineService.
+func (f *FakeController) ReleaseMachines(args ReleaseMachinesArgs) error {
+	return f.ReleaseMachinesContext(context.Background(), args)
+}
+
+// ReleaseMachinesContext implements MachineService.
+func (f *FakeController) ReleaseMachinesContext(ctx context.Context, args ReleaseMachinesArgs) error {
+	f.record("ReleaseMachines", args)
+	if f.ReleaseMachinesFunc == nil {
+		return nil
+	}
+	return f.ReleaseMachinesFunc(ctx, args)
+}
+
+// Deploy implements MachineService.
+func (f *FakeController) Deploy(m *Machine, args DeployMachineArgs) error {
+	return f.DeployContext(context.Background(), m, args)
+}
+
+// DeployContext implements MachineService.
+func (f *FakeController) DeployContext(ctx context.Context, m *Machine, args DeployMachineArgs) error {
+	f.record("Deploy", m, args)
+	if f.DeployFunc == nil {
+		return nil
+	}
+	return f.DeployFunc(ctx, m, args)
+}
+
+// SetOwnerData implements MachineService.
+func (f *FakeController) SetOwnerData(m *Machine, ownerData map[string]string) error {
+	return f.SetOwnerDataContext(context.Background(), m, ownerData)
+}
+
+// SetOwnerDataContext implements MachineService.
+func (f *FakeController) SetOwnerDataContext(ctx context.Context, m *Machine, ownerData map[string]string) error {
+	f.record("SetOwnerData", m, ownerData)
+	if f.SetOwnerDataFunc == nil {
+		return nil
+	}
+	return f.SetOwnerDataFunc(ctx, m, ownerData)
+}
+
+// CommissionMachine implements MachineService.
+func (f *FakeController) CommissionMachine(m *Machine, args CommissionMachineArgs) error {
+	return f.CommissionMachineContext(context.Background(), m, args)
+}
+
+// CommissionMachineContext implements MachineService.
+func (f *FakeController) CommissionMachineContext(ctx context.Context, m *Machine, args CommissionMachineArgs) error {
+	f.record("CommissionMachine", m, args)
+	if f.CommissionMachineFunc == nil {
+		return nil
+	}
+	return f.CommissionMachineFunc(ctx, m, args)
+}
+
+// AbortMachine implements MachineService.
+func (f *FakeController) AbortMachine(m *Machine, args AbortMachineArgs) error {
+	return f.AbortMachineContext(context.Background(), m, args)
+}
+
+// AbortMachineContext implements MachineService.
+func (f *FakeController) AbortMachineContext(ctx context.Context, m *Machine, args AbortMachineArgs) error {
+	f.record("AbortMachine", m, args)
+	if f.AbortMachineFunc == nil {
+		return nil
+	}
+	return f.AbortMachineFunc(ctx, m, args)
+}
+
+// ReleaseMachine implements MachineService.
+func (f *FakeController) ReleaseMachine(m *Machine, args ReleaseMachineArgs) error {
+	return f.ReleaseMachineContext(context.Background(), m, args)
+}
+
+// ReleaseMachineContext implements MachineService.
+func (f *FakeController) ReleaseMachineContext(ctx context.Context, m *Machine, args ReleaseMachineArgs) error {
+	f.record("ReleaseMachine", m, args)
+	if f.ReleaseMachineFunc == nil {
+		return nil
+	}
+	return f.ReleaseMachineFunc(ctx, m, args)
+}
+
+// PowerOnMachine implements MachineService.
+func (f *FakeController) PowerOnMachine(m *Machine, args PowerOnMachineArgs) error {
+	return f.PowerOnMachineContext(context.Background(), m, args)
+}
+
+// PowerOnMachineContext implements MachineService.
+func (f *FakeController) PowerOnMachineContext(ctx context.Context, m *Machine, args PowerOnMachineArgs) error {
+	f.record("PowerOnMachine", m, args)
+	if f.PowerOnMachineFunc == nil {
+		return nil
+	}
+	return f.PowerOnMachineFunc(ctx, m, args)
+}
+
+// PowerOffMachine implements MachineService.
+func (f *FakeController) PowerOffMachine(m *Machine, args PowerOffMachineArgs) error {
+	return f.PowerOffMachineContext(context.Background(), m, args)
+}
+
+// PowerOffMachineContext implements MachineService.
+func (f *FakeController) PowerOffMachineContext(ctx context.Context, m *Machine, args PowerOffMachineArgs) error {
+	f.record("PowerOffMachine", m, args)
+	if f.PowerOffMachineFunc == nil {
+		return nil
+	}
+	return f.PowerOffMachineFunc(ctx, m, args)
+}
+
+// MarkMachineBroken implements MachineService.
+func (f *FakeController) MarkMachineBroken(m *Machine, args MarkMachineBrokenArgs) error {
+	return f.MarkMachineBrokenContext(context.Background(), m, args)
+}
+
+// MarkMachineBrokenContext implements MachineService.
+func (f *FakeController) MarkMachineBrokenContext(ctx context.Context, m *Machine, args MarkMachineBrokenArgs) error {
+	f.record("MarkMachineBroken", m, args)
+	if f.MarkMachineBrokenFunc == nil {
+		return nil
+	}
+	return f.MarkMachineBrokenFunc(ctx, m, args)
+}
+
+// MarkMachineFixed implements MachineService.
+func (f *FakeController) MarkMachineFixed(m *Machine, args MarkMachineFixedArgs) error {
+	return f.MarkMachineFixedContext(context.Background(), m, args)
+}
+
+// MarkMachineFixedContext implements MachineService.
+func (f *FakeController) MarkMachineFixedContext(ctx context.Context, m *Machine, args MarkMachineFixedArgs) error {
+	f.record("MarkMachineFixed", m, args)
+	if f.MarkMachineFixedFunc == nil {
+		return nil
+	}
+	return f.MarkMachineFixedFunc(ctx, m, args)
+}
+
+// EnterRescueMode implements MachineService.
+func (f *FakeController) EnterRescueMode(m *Machine) error {
+	return f.EnterRescueModeContext(context.Background(), m)
+}
+
+// EnterRescueModeContext implements MachineService.
+func (f *FakeController) EnterRescueModeContext(ctx context.Context, m *Machine) error {
+	f.record("EnterRescueMode", m)
+	if f.EnterRescueModeFunc == nil {
+		return nil
+	}
+	return f.EnterRescueModeFunc(ctx, m)
+}
+
+// ExitRescueMode implements MachineService.
+func (f *FakeController) ExitRescueMode(m *Machine) error {
+	return f.ExitRescueModeContext(context.Background(), m)
+}
+
+// ExitRescueModeContext implements MachineService.
+func (f *FakeController) ExitRescueModeContext(ctx context.Context, m *Machine) error {
+	f.record("ExitRescueMode", m)
+	if f.ExitRescueModeFunc == nil {
+		return nil
+	}
+	return f.ExitRescueModeFunc(ctx, m)
+}
+
+// RestoreDefaultConfiguration implements MachineService.
+func (f *FakeController) RestoreDefaultConfiguration(m *Machine) error {
+	return f.RestoreDefaultConfigurationContext(context.Background(), m)
+}
+
+// RestoreDefaultConfigurationContext implements MachineService.
+func (f *FakeController) RestoreDefaultConfigurationContext(ctx context.Context, m *Machine) error {
+	f.record("RestoreDefaultConfiguration", m)
+	if f.RestoreDefaultConfigurationFunc == nil {
+		return nil
+	}
+	return f.RestoreDefaultConfigurationFunc(ctx, m)
+}
+
+// RestoreNetworkingConfiguration implements MachineService.
+func (f *FakeController) RestoreNetworkingConfiguration(m *Machine) error {
+	return f.RestoreNetworkingConfigurationContext(context.Background(), m)
+}
+
+// RestoreNetworkingConfigurationContext implements MachineService.
+func (f *FakeController) RestoreNetworkingConfigurationContext(ctx context.Context, m *Machine) error {
+	f.record("RestoreNetworkingConfiguration", m)
+	if f.RestoreNetworkingConfigurationFunc == nil {
+		return nil
+	}
+	return f.RestoreNetworkingConfigurationFunc(ctx, m)
+}
+
+// SetStorageLayout implements MachineService.
+func (f *FakeController) SetStorageLayout(m *Machine, args SetStorageLayoutArgs) error {
+	return f.SetStorageLayoutContext(context.Background(), m, args)
+}
+
+// SetStorageLayoutContext implements MachineService.
+func (f *FakeController) SetStorageLayoutContext(ctx context.Context, m *Machine, args SetStorageLayoutArgs) error {
+	f.record("SetStorageLayout", m, args)
+	if f.SetStorageLayoutFunc == nil {
+		return nil
+	}
+	return f.SetStorageLayoutFunc(ctx, m, args)
+}
+
+// RestoreStorageConfiguration implements MachineService.
+func (f *FakeController) RestoreStorageConfiguration(m *Machine) error {
+	return f.RestoreStorageConfigurationContext(context.Background(), m)
+}
+
+// RestoreStorageConfigurationContext implements MachineService.
+func (f *FakeController) RestoreStorageConfigurationContext(ctx context.Context, m *Machine) error {
+	f.record("RestoreStorageConfiguration", m)
+	if f.RestoreStorageConfigurationFunc == nil {
+		return nil
+	}
+	return f.RestoreStorageConfigurationFunc(ctx, m)
+}
+
+// ClearDefaultGateways implements MachineService.
+func (f *FakeController) ClearDefaultGateways(m *Machine) error {
+	return f.ClearDefaultGatewaysContext(context.Background(), m)
+}
+
+// ClearDefaultGatewaysContext implements MachineService.
+func (f *FakeController) ClearDefaultGatewaysContext(ctx context.Context, m *Machine) error {
+	f.record("ClearDefaultGateways", m)
+	if f.ClearDefaultGatewaysFunc == nil {
+		return nil
+	}
+	return f.ClearDefaultGatewaysFunc(ctx, m)
+}
+
+// MountSpecial implements MachineService.
+func (f *FakeController) MountSpecial(m *Machine, args MountSpecialArgs) error {
+	return f.MountSpecialContext(context.Background(), m, args)
+}
+
+// MountSpecialContext implements MachineService.
+func (f *FakeController) MountSpecialContext(ctx context.Context, m *Machine, args MountSpecialArgs) error {
+	f.record("MountSpecial", m, args)
+	if f.MountSpecialFunc == nil {
+		return nil
+	}
+	return f.MountSpecialFunc(ctx, m, args)
+}
+
+// UnmountSpecial implements MachineService.
+func (f *FakeController) UnmountSpecial(m *Machine, mountPoint string) error {
+	return f.UnmountSpecialContext(context.Background(), m, mountPoint)
+}
+
+// UnmountSpecialContext implements MachineService.
+func (f *FakeController) UnmountSpecialContext(ctx context.Context, m *Machine, mountPoint string) error {
+	f.record("UnmountSpecial", m, mountPoint)
+	if f.UnmountSpecialFunc == nil {
+		return nil
+	}
+	return f.UnmountSpecialFunc(ctx, m, mountPoint)
+}
+
+// MachineDetails implements MachineService.
+func (f *FakeController) MachineDetails(m *Machine) ([]byte, error) {
+	return f.MachineDetailsContext(context.Background(), m)
+}
+
+// MachineDetailsContext implements MachineService.
+func (f *FakeController) MachineDetailsContext(ctx context.Context, m *Machine) ([]byte, error) {
+	f.record("MachineDetails", m)
+	if f.MachineDetailsFunc == nil {
+		return nil, nil
+	}
+	return f.MachineDetailsFunc(ctx, m)
+}
+
+// CurtinConfig implements MachineService.
+func (f *FakeController) CurtinConfig(m *Machine) ([]byte, error) {
+	return f.CurtinConfigContext(context.Background(), m)
+}
+
+// CurtinConfigContext implements MachineService.
+func (f *FakeController) CurtinConfigContext(ctx context.Context, m *Machine) ([]byte, error) {
+	f.record("CurtinConfig", m)
+	if f.CurtinConfigFunc == nil {
+		return nil, nil
+	}
+	return f.CurtinConfigFunc(ctx, m)
+}
+
+// PowerParameters implements MachineService.
+func (f *FakeController) PowerParameters(m *Machine) (map[string]interface{}, error) {
+	return f.PowerParametersContext(context.Background(), m)
+}
+
+// PowerParametersContext implements MachineService.
+func (f *FakeController) PowerParametersContext(ctx context.Context, m *Machine) (map[string]interface{}, error) {
+	f.record("PowerParameters", m)
+	if f.PowerParametersFunc == nil {
+		return nil, nil
+	}
+	return f.PowerParametersFunc(ctx, m)
+}
+
+// WaitForStatus implements MachineService.
+func (f *FakeController) WaitForStatus(ctx context.Context, systemID string, targets []NodeStatus, opts WaitOptions) (*Machine, error) {
+	f.record("WaitForStatus", systemID, targets, opts)
+	if f.WaitForStatusFunc == nil {
+		return nil, nil
+	}
+	return f.WaitForStatusFunc(ctx, systemID, targets, opts)
+}
+
+// StartDeploy implements MachineService. When StartDeployFunc is nil, it returns an
+// Operation already completed with m.
+func (f *FakeController) StartDeploy(ctx context.Context, m *Machine, args DeployMachineArgs, opts WaitOptions) (*Operation, error) {
+	f.record("StartDeploy", m, args, opts)
+	if f.StartDeployFunc == nil {
+		return CompletedOperation(m, nil), nil
+	}
+	return f.StartDeployFunc(ctx, m, args, opts)
+}
+
+// StartCommission implements MachineService. When StartCommissionFunc is nil, it returns an
+// Operation already completed with m.
+func (f *FakeController) StartCommission(ctx context.Context, m *Machine, args CommissionMachineArgs, opts WaitOptions) (*Operation, error) {
+	f.record("StartCommission", m, args, opts)
+	if f.StartCommissionFunc == nil {
+		return CompletedOperation(m, nil), nil
+	}
+	return f.StartCommissionFunc(ctx, m, args, opts)
+}
+
+// StartRelease implements MachineService. When StartReleaseFunc is nil, it returns an
+// Operation already completed with m.
+func (f *FakeController) StartRelease(ctx context.Context, m *Machine, args ReleaseMachineArgs, opts WaitOptions) (*Operation, error) {
+	f.record("StartRelease", m, args, opts)
+	if f.StartReleaseFunc == nil {
+		return CompletedOperation(m, nil), nil
+	}
+	return f.StartReleaseFunc(ctx, m, args, opts)
+}
+
+// Nodes implements NodeService.
+func (f *FakeController) Nodes(args NodesArgs) ([]Node, error) {
+	return f.NodesContext(context.Background(), args)
+}
+
+// NodesContext implements NodeService.
+func (f *FakeController) NodesContext(ctx context.Context, args NodesArgs) ([]Node, error) {
+	f.record("Nodes", args)
+	if f.NodesFunc == nil {
+		return nil, nil
+	}
+	return f.NodesFunc(ctx, args)
+}
+
+// CreateNode implements NodeService.
+func (f *FakeController) CreateNode(args CreateNodeArgs) (*Node, error) {
+	return f.CreateNodeContext(context.Background(), args)
+}
+
+// CreateNodeContext implements NodeService.
+func (f *FakeController) CreateNodeContext(ctx context.Context, args CreateNodeArgs) (*Node, error) {
+	f.record("CreateNode", args)
+	if f.CreateNodeFunc == nil {
+		return nil, nil
+	}
+	return f.CreateNodeFunc(ctx, args)
+}
+
+// Devices implements NodeService.
+func (f *FakeController) Devices(args DevicesArgs) ([]Device, error) {
+	return f.DevicesContext(context.Background(), args)
+}
+
+// DevicesContext implements NodeService.
+func (f *FakeController) DevicesContext(ctx context.Context, args DevicesArgs) ([]Device, error) {
+	f.record("Devices", args)
+	if f.DevicesFunc == nil {
+		return nil, nil
+	}
+	return f.DevicesFunc(ctx, args)
+}
+
+// Fabrics implements NetworkService.
+func (f *FakeController) Fabrics() ([]Fabric, error) {
+	return f.FabricsContext(context.Background())
+}
+
+// FabricsContext implements NetworkService.
+func (f *FakeController) FabricsContext(ctx context.Context) ([]Fabric, error) {
+	f.record("Fabrics")
+	if f.FabricsFunc == nil {
+		return nil, nil
+	}
+	return f.FabricsFunc(ctx)
+}
+
+// Spaces implements NetworkService.
+func (f *FakeController) Spaces() ([]Space, error) {
+	return f.SpacesContext(context.Background())
+}
+
+// SpacesContext implements NetworkService.
+func (f *FakeController) SpacesContext(ctx context.Context) ([]Space, error) {
+	f.record("Spaces")
+	if f.SpacesFunc == nil {
+		return nil, nil
+	}
+	return f.SpacesFunc(ctx)
+}
+
+// StaticRoutes implements NetworkService.
+func (f *FakeController) StaticRoutes() ([]StaticRoute, error) {
+	return f.StaticRoutesContext(context.Background())
+}
+
+// StaticRoutesContext implements NetworkService.
+func (f *FakeController) StaticRoutesContext(ctx context.Context) ([]StaticRoute, error) {
+	f.record("StaticRoutes")
+	if f.StaticRoutesFunc == nil {
+		return nil, nil
+	}
+	return f.StaticRoutesFunc(ctx)
+}
+
+// Zones implements NetworkService.
+func (f *FakeController) Zones() ([]Zone, error) {
+	return f.ZonesContext(context.Background())
+}
+
+// ZonesContext implements NetworkService.
+func (f *FakeController) ZonesContext(ctx context.Context) ([]Zone, error) {
+	f.record("Zones")
+	if f.ZonesFunc == nil {
+		return nil, nil
+	}
+	return f.ZonesFunc(ctx)
+}
+
+// CreateInterface implements NetworkService.
+func (f *FakeController) CreateInterface(d *Node, args CreateNodeNetworkInterfaceArgs) (*NetworkInterface, error) {
+	return f.CreateInterfaceContext(context.Background(), d, args)
+}
+
+// CreateInterfaceContext implements NetworkService.
+func (f *FakeController) CreateInterfaceContext(ctx context.Context, d *Node, args CreateNodeNetworkInterfaceArgs) (*NetworkInterface, error) {
+	f.record("CreateInterface", d, args)
+	if f.CreateInterfaceFunc == nil {
+		return nil, nil
+	}
+	return f.CreateInterfaceFunc(ctx, d, args)
+}
+
+// LinkSubnet implements NetworkService.
+func (f *FakeController) LinkSubnet(i *NetworkInterface, args LinkSubnetArgs) error {
+	return f.LinkSubnetContext(context.Background(), i, args)
+}
+
+// LinkSubnetContext implements NetworkService.
+func (f *FakeController) LinkSubnetContext(ctx context.Context, i *NetworkInterface, args LinkSubnetArgs) error {
+	f.record("LinkSubnet", i, args)
+	if f.LinkSubnetFunc == nil {
+		return nil
+	}
+	return f.LinkSubnetFunc(ctx, i, args)
+}
+
+// UnlinkSubnet implements NetworkService.
+func (f *FakeController) UnlinkSubnet(i *NetworkInterface, s *Subnet) error {
+	return f.UnlinkSubnetContext(context.Background(), i, s)
+}
+
+// UnlinkSubnetContext implements NetworkService.
+func (f *FakeController) UnlinkSubnetContext(ctx context.Context, i *NetworkInterface, s *Subnet) error {
+	f.record("UnlinkSubnet", i, s)
+	if f.UnlinkSubnetFunc == nil {
+		return nil
+	}
+	return f.UnlinkSubnetFunc(ctx, i, s)
+}
+
+// UpdateNetworkInterface implements NetworkService.
+func (f *FakeController) UpdateNetworkInterface(i *NetworkInterface, args UpdateInterfaceArgs) error {
+	return f.UpdateNetworkInterfaceContext(context.Background(), i, args)
+}
+
+// UpdateNetworkInterfaceContext implements NetworkService.
+func (f *FakeController) UpdateNetworkInterfaceContext(ctx context.Context, i *NetworkInterface, args UpdateInterfaceArgs) error {
+	f.record("UpdateNetworkInterface", i, args)
+	if f.UpdateNetworkInterfaceFunc == nil {
+		return nil
+	}
+	return f.UpdateNetworkInterfaceFunc(ctx, i, args)
+}
+
+// GetFile implements FileService.
+func (f *FakeController) GetFile(filename string) (*File, error) {
+	return f.GetFileContext(context.Background(), filename)
+}
+
+// GetFileContext implements FileService.
+func (f *FakeController) GetFileContext(ctx context.Context, filename string) (*File, error) {
+	f.record("GetFile", filename)
+	if f.GetFileFunc == nil {
+		return nil, nil
+	}
+	return f.GetFileFunc(ctx, filename)
+}
+
+// ReadFileContent implements FileService.
+func (f *FakeController) ReadFileContent(file *File) ([]byte, error) {
+	return f.ReadFileContentContext(context.Background(), file)
+}
+
+// ReadFileContentContext implements FileService.
+func (f *FakeController) ReadFileContentContext(ctx context.Context, file *File) ([]byte, error) {
+	f.record("ReadFileContent", file)
+	if f.ReadFileContentFunc == nil {
+		return nil, nil
+	}
+	return f.ReadFileContentFunc(ctx, file)
+}
+
+// DownloadFile implements FileService.
+func (f *FakeController) DownloadFile(args DownloadFileArgs) (int64, error) {
+	return f.DownloadFileContext(context.Background(), args)
+}
+
+// DownloadFileContext implements FileService.
+func (f *FakeController) DownloadFileContext(ctx context.Context, args DownloadFileArgs) (int64, error) {
+	f.record("DownloadFile", args)
+	if f.DownloadFileFunc == nil {
+		return 0, nil
+	}
+	return f.DownloadFileFunc(ctx, args)
+}
+
+// AddFile implements FileService.
+func (f *FakeController) AddFile(args AddFileArgs) error {
+	return f.AddFileContext(context.Background(), args)
+}
+
+// AddFileContext implements FileService.
+func (f *FakeController) AddFileContext(ctx context.Context, args AddFileArgs) error {
+	f.record("AddFile", args)
+	if f.AddFileFunc == nil {
+		return nil
+	}
+	return f.AddFileFunc(ctx, args)
+}
+
+// FormatBlockDevice implements StorageService.
+func (f *FakeController) FormatBlockDevice(d *BlockDevice, args FormatArgs) error {
+	return f.FormatBlockDeviceContext(context.Background(), d, args)
+}
+
+// FormatBlockDeviceContext implements StorageService.
+func (f *FakeController) FormatBlockDeviceContext(ctx context.Context, d *BlockDevice, args FormatArgs) error {
+	f.record("FormatBlockDevice", d, args)
+	if f.FormatBlockDeviceFunc == nil {
+		return nil
+	}
+	return f.FormatBlockDeviceFunc(ctx, d, args)
+}
+
+// UnformatBlockDevice implements StorageService.
+func (f *FakeController) UnformatBlockDevice(d *BlockDevice) error {
+	return f.UnformatBlockDeviceContext(context.Background(), d)
+}
+
+// UnformatBlockDeviceContext implements StorageService.
+func (f *FakeController) UnformatBlockDeviceContext(ctx context.Context, d *BlockDevice) error {
+	f.record("UnformatBlockDevice", d)
+	if f.UnformatBlockDeviceFunc == nil {
+		return nil
+	}
+	return f.UnformatBlockDeviceFunc(ctx, d)
+}
+
+// MountBlockDevice implements StorageService.
+func (f *FakeController) MountBlockDevice(d *BlockDevice, args MountArgs) error {
+	return f.MountBlockDeviceContext(context.Background(), d, args)
+}
+
+// MountBlockDeviceContext implements StorageService.
+func (f *FakeController) MountBlockDeviceContext(ctx context.Context, d *BlockDevice, args MountArgs) error {
+	f.record("MountBlockDevice", d, args)
+	if f.MountBlockDeviceFunc == nil {
+		return nil
+	}
+	return f.MountBlockDeviceFunc(ctx, d, args)
+}
+
+// UnmountBlockDevice implements StorageService.
+func (f *FakeController) UnmountBlockDevice(d *BlockDevice) error {
+	return f.UnmountBlockDeviceContext(context.Background(), d)
+}
+
+// UnmountBlockDeviceContext implements StorageService.
+func (f *FakeController) UnmountBlockDeviceContext(ctx context.Context, d *BlockDevice) error {
+	f.record("UnmountBlockDevice", d)
+	if f.UnmountBlockDeviceFunc == nil {
+		return nil
+	}
+	return f.UnmountBlockDeviceFunc(ctx, d)
+}
+
+// SetBootDisk implements StorageService.
+func (f *FakeController) SetBootDisk(d *BlockDevice) error {
+	return f.SetBootDiskContext(context.Background(), d)
+}
+
+// SetBootDiskContext implements StorageService.
+func (f *FakeController) SetBootDiskContext(ctx context.Context, d *BlockDevice) error {
+	f.record("SetBootDisk", d)
+	if f.SetBootDiskFunc == nil {
+		return nil
+	}
+	return f.SetBootDiskFunc(ctx, d)
+}
+
+// AddBlockDeviceTag implements StorageService.
+func (f *FakeController) AddBlockDeviceTag(d *BlockDevice, tag string) error {
+	return f.AddBlockDeviceTagContext(context.Background(), d, tag)
+}
+
+// AddBlockDeviceTagContext implements StorageService.
+func (f *FakeController) AddBlockDeviceTagContext(ctx context.Context, d *BlockDevice, tag string) error {
+	f.record("AddBlockDeviceTag", d, tag)
+	if f.AddBlockDeviceTagFunc == nil {
+		return nil
+	}
+	return f.AddBlockDeviceTagFunc(ctx, d, tag)
+}
+
+// RemoveBlockDeviceTag implements StorageService.
+func (f *FakeController) RemoveBlockDeviceTag(d *BlockDevice, tag string) error {
+	return f.RemoveBlockDeviceTagContext(context.Background(), d, tag)
+}
+
+// RemoveBlockDeviceTagContext implements StorageService.
+func (f *FakeController) RemoveBlockDeviceTagContext(ctx context.Context, d *BlockDevice, tag string) error {
+	f.record("RemoveBlockDeviceTag", d, tag)
+	if f.RemoveBlockDeviceTagFunc == nil {
+		return nil
+	}
+	return f.RemoveBlockDeviceTagFunc(ctx, d, tag)
+}
+
+// DeleteBlockDevice implements StorageService.
+func (f *FakeController) DeleteBlockDevice(d *BlockDevice) error {
+	return f.DeleteBlockDeviceContext(context.Background(), d)
+}
+
+// DeleteBlockDeviceContext implements StorageService.
+func (f *FakeController) DeleteBlockDeviceContext(ctx context.Context, d *BlockDevice) error {
+	f.record("DeleteBlockDevice", d)
+	if f.DeleteBlockDeviceFunc == nil {
+		return nil
+	}
+	return f.DeleteBlockDeviceFunc(ctx, d)
+}
+
+// CreatePartition implements StorageService.
+func (f *FakeController) CreatePartition(d *BlockDevice, args CreatePartitionArgs) (*Partition, error) {
+	return f.CreatePartitionContext(context.Background(), d, args)
+}
+
+// CreatePartitionContext implements StorageService.
+func (f *FakeController) CreatePartitionContext(ctx context.Context, d *BlockDevice, args CreatePartitionArgs) (*Partition, error) {
+	f.record("CreatePartition", d, args)
+	if f.CreatePartitionFunc == nil {
+		return nil, nil
+	}
+	return f.CreatePartitionFunc(ctx, d, args)
+}
+
+// DeletePartition implements StorageService.
+func (f *FakeController) DeletePartition(d *BlockDevice, p *Partition) error {
+	return f.DeletePartitionContext(context.Background(), d, p)
+}
+
+// DeletePartitionContext implements StorageService.
+func (f *FakeController) DeletePartitionContext(ctx context.Context, d *BlockDevice, p *Partition) error {
+	f.record("DeletePartition", d, p)
+	if f.DeletePartitionFunc == nil {
+		return nil
+	}
+	return f.DeletePartitionFunc(ctx, d, p)
+}
+
+// FormatPartition implements StorageService.
+func (f *FakeController) FormatPartition(p *Partition, args FormatArgs) error {
+	return f.FormatPartitionContext(context.Background(), p, args)
+}
+
+// FormatPartitionContext implements StorageService.
+func (f *FakeController) FormatPartitionContext(ctx context.Context, p *Partition, args FormatArgs) error {
+	f.record("FormatPartition", p, args)
+	if f.FormatPartitionFunc == nil {
+		return nil
+	}
+	return f.FormatPartitionFunc(ctx, p, args)
+}
+
+// UnformatPartition implements StorageService.
+func (f *FakeController) UnformatPartition(p *Partition) error {
+	return f.UnformatPartitionContext(context.Background(), p)
+}
+
+// UnformatPartitionContext implements StorageService.
+func (f *FakeController) UnformatPartitionContext(ctx context.Context, p *Partition) error {
+	f.record("UnformatPartition", p)
+	if f.UnformatPartitionFunc == nil {
+		return nil
+	}
+	return f.UnformatPartitionFunc(ctx, p)
+}
+
+// MountPartition implements StorageService.
+func (f *FakeController) MountPartition(p *Partition, args MountArgs) error {
+	return f.MountPartitionContext(context.Background(), p, args)
+}
+
+// MountPartitionContext implements StorageService.
+func (f *FakeController) MountPartitionContext(ctx context.Context, p *Partition, args MountArgs) error {
+	f.record("MountPartition", p, args)
+	if f.MountPartitionFunc == nil {
+		return nil
+	}
+	return f.MountPartitionFunc(ctx, p, args)
+}
+
+// UnmountPartition implements StorageService.
+func (f *FakeController) UnmountPartition(p *Partition) error {
+	return f.UnmountPartitionContext(context.Background(), p)
+}
+
+// UnmountPartitionContext implements StorageService.
+func (f *FakeController) UnmountPartitionContext(ctx context.Context, p *Partition) error {
+	f.record("UnmountPartition", p)
+	if f.UnmountPartitionFunc == nil {
+		return nil
+	}
+	return f.UnmountPartitionFunc(ctx, p)
+}
+
+// CreateVolumeGroup implements StorageService.
+func (f *FakeController) CreateVolumeGroup(m *Machine, args CreateVolumeGroupArgs) (*VolumeGroup, error) {
+	return f.CreateVolumeGroupContext(context.Background(), m, args)
+}
+
+// CreateVolumeGroupContext implements StorageService.
+func (f *FakeController) CreateVolumeGroupContext(ctx context.Context, m *Machine, args CreateVolumeGroupArgs) (*VolumeGroup, error) {
+	f.record("CreateVolumeGroup", m, args)
+	if f.CreateVolumeGroupFunc == nil {
+		return nil, nil
+	}
+	return f.CreateVolumeGroupFunc(ctx, m, args)
+}
+
+// CreateLogicalVolume implements StorageService.
+func (f *FakeController) CreateLogicalVolume(vg *VolumeGroup, args CreateLogicalVolumeArgs) (*BlockDevice, error) {
+	return f.CreateLogicalVolumeContext(context.Background(), vg, args)
+}
+
+// CreateLogicalVolumeContext implements StorageService.
+func (f *FakeController) CreateLogicalVolumeContext(ctx context.Context, vg *VolumeGroup, args CreateLogicalVolumeArgs) (*BlockDevice, error) {
+	f.record("CreateLogicalVolume", vg, args)
+	if f.CreateLogicalVolumeFunc == nil {
+		return nil, nil
+	}
+	return f.CreateLogicalVolumeFunc(ctx, vg, args)
+}
+
+// BootResources implements BootResourceService.
+func (f *FakeController) BootResources() ([]*BootResource, error) {
+	return f.BootResourcesContext(context.Background())
+}
+
+// BootResourcesContext implements BootResourceService.
+func (f *FakeController) BootResourcesContext(ctx context.Context) ([]*BootResource, error) {
+	f.record("BootResources")
+	if f.BootResourcesFunc == nil {
+		return nil, nil
+	}
+	return f.BootResourcesFunc(ctx)
+}
@@ -0,0 +1,79 @@
+package v2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// deployFirstReady is the kind of consumer code the service interfaces are
+// meant for.
+func deployFirstReady(machines MachineService, series string) (*Machine, error) {
+	machine, _, err := machines.AllocateMachine(AllocateMachineArgs{})
+	if err != nil {
+		return nil, err
+	}
+	if err := machines.Deploy(machine, DeployMachineArgs{DistroSeries: series}); err != nil {
+		return nil, err
+	}
+	return machine, nil
+}
+
+func TestFakeControllerRecordsCalls(t *testing.T) {
+	allocated := &Machine{SystemID: "4y3ha3"}
+	fake := &FakeController{
+		AllocateMachineFunc: func(context.Context, AllocateMachineArgs) (*Machine, ConstraintMatches, error) {
+			return allocated, ConstraintMatches{}, nil
+		},
+	}
+
+	machine, err := deployFirstReady(fake, "bionic")
+
+	assert.Nil(t, err)
+	assert.True(t, machine == allocated)
+	assert.Equal(t, fake.Calls(), []FakeCall{
+		{Method: "AllocateMachine", Args: []interface{}{AllocateMachineArgs{}}},
+		{Method: "Deploy", Args: []interface{}{allocated, DeployMachineArgs{DistroSeries: "bionic"}}},
+	})
+	assert.Len(t, fake.CallsTo("Deploy"), 1)
+
+	fake.ResetCalls()
+	assert.Empty(t, fake.Calls())
+}
+
+func TestFakeControllerContextVariantsShareFuncs(t *testing.T) {
+	type key struct{}
+	var seen []interface{}
+	fake := &FakeController{
+		ZonesFunc: func(ctx context.Context) ([]Zone, error) {
+			seen = append(seen, ctx.Value(key{}))
+			return nil, util.NewNoMatchError("no zones")
+		},
+	}
+
+	_, err := fake.Zones()
+	assert.True(t, util.IsNoMatchError(err))
+	_, err = fake.ZonesContext(context.WithValue(context.Background(), key{}, "value"))
+	assert.True(t, util.IsNoMatchError(err))
+
+	assert.Equal(t, seen, []interface{}{nil, "value"})
+	assert.Len(t, fake.CallsTo("Zones"), 2)
+}
+
+func TestFakeControllerDefaults(t *testing.T) {
+	var services Services = &FakeController{}
+
+	machines, err := services.Machines(MachinesArgs{})
+	assert.Nil(t, err)
+	assert.Empty(t, machines)
+	written, err := services.DownloadFile(DownloadFileArgs{Filename: "image"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, written, 0)
+	op, err := services.StartDeploy(context.Background(), &Machine{SystemID: "4y3ha3"}, DeployMachineArgs{}, WaitOptions{})
+	assert.Nil(t, err)
+	machine, err := op.Wait(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, machine.SystemID, "4y3ha3")
+}
@@ -2,8 +2,10 @@ package v2
 
 import (
 	"io"
+	"net/url"
 	"path"
 
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
 	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
 	"github.com/juju/errors"
 )
@@ -15,6 +17,8 @@ type AddFileArgs struct {
 	Content  []byte
 	Reader   io.Reader
 	Length   int64
+	// Progress, when set, is called as the File Content is uploaded.
+	Progress client.ProgressFunc
 }
 
 // Validate checks to make sure the Filename has no slashes, and that one of
@@ -45,6 +49,38 @@ func (a *AddFileArgs) Validate() error {
 	return nil
 }
 
+// DownloadFileArgs is an argument struct for passing information into
+// DownloadFile. One of Filename or AnonymousURI must be specified.
+type DownloadFileArgs struct {
+	// Filename names the File to download with the controller's
+	// credentials.
+	Filename string
+	// AnonymousURI is the AnonymousURI of a File, downloaded without
+	// credentials.
+	AnonymousURI *url.URL
+	// Writer receives the File Content as it is downloaded.
+	Writer io.Writer
+	// Size, when not zero, is the expected length of the Content.
+	Size int64
+	// SHA256, when set, is the hex encoded SHA-256 digest of the Content.
+	SHA256 string
+}
+
+// Validate checks that exactly one of Filename or AnonymousURI is specified,
+// and that the Writer is set.
+func (a *DownloadFileArgs) Validate() error {
+	if a.Filename == "" && a.AnonymousURI == nil {
+		return errors.NotValidf("missing Filename or AnonymousURI")
+	}
+	if a.Filename != "" && a.AnonymousURI != nil {
+		return errors.NotValidf("specifying Filename and AnonymousURI")
+	}
+	if a.Writer == nil {
+		return errors.NotValidf("missing Writer")
+	}
+	return nil
+}
+
 func FileParams(args AddFileArgs) *util.URLParams {
 	params := util.NewURLParams()
 	params.MaybeAdd("filename", args.Filename)
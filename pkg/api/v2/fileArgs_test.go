@@ -2,6 +2,7 @@ package v2
 
 import (
 	"bytes"
+	"net/url"
 	"testing"
 
 	"github.com/juju/errors"
@@ -65,3 +66,32 @@ func TestAddFileArgsValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestDownloadFileArgsValidate(t *testing.T) {
+	anonymousURI, err := url.Parse("/MAAS/api/2.0/files/?op=get_by_key&key=abc")
+	assert.Nil(t, err)
+	for _, test := range []struct {
+		args    DownloadFileArgs
+		errText string
+	}{{
+		errText: "missing Filename or AnonymousURI not valid",
+	}, {
+		args:    DownloadFileArgs{Filename: "foo", AnonymousURI: anonymousURI},
+		errText: "specifying Filename and AnonymousURI not valid",
+	}, {
+		args:    DownloadFileArgs{Filename: "foo"},
+		errText: "missing Writer not valid",
+	}, {
+		args: DownloadFileArgs{Filename: "foo", Writer: &bytes.Buffer{}},
+	}, {
+		args: DownloadFileArgs{AnonymousURI: anonymousURI, Writer: &bytes.Buffer{}},
+	}} {
+		err := test.args.Validate()
+		if test.errText == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.True(t, errors.IsNotValid(err))
+			assert.EqualError(t, err, test.errText)
+		}
+	}
+}
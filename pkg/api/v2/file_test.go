@@ -45,7 +45,7 @@ func TestReadAllFromFiles(t *testing.T) {
 	server, controller := createTestServerController(t)
 	defer server.Close()
 	server.AddGetResponse("/api/2.0/files/", http.StatusOK, filesResponse)
-	server.AddGetResponse("/api/2.0/files/?Filename=test&op=Get", http.StatusOK, "some Content\n")
+	server.AddGetResponse("/api/2.0/files/?filename=test&op=get", http.StatusOK, "some Content\n")
 
 	files, err := controller.getFiles("")
 	assert.Nil(t, err)
@@ -4,9 +4,9 @@
 package v2
 
 type Filesystem struct {
-	Type       string `json:"Type,omitempty"`
-	MountPoint string `json:"mount_point,omitempty"`
-	Label      string `json:"Label,omitempty"`
-	UUID       string `json:"UUID,omitempty"`
-	// no idea what the mount_options are as a value type, so ignoring for now.
+	Type         string `json:"Type,omitempty"`
+	MountPoint   string `json:"mount_point,omitempty"`
+	Label        string `json:"Label,omitempty"`
+	UUID         string `json:"UUID,omitempty"`
+	MountOptions string `json:"mount_options,omitempty"`
 }
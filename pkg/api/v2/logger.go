@@ -0,0 +1,257 @@
+package v2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/juju/loggo"
+)
+
+// Level is the severity of a log record.
+type Level int
+
+const (
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+// String implements fmt.Stringer.
+func (level Level) String() string {
+	switch level {
+	case LevelTrace:
+		return "TRACE"
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarning:
+		return "WARNING"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("Level(%d)", int(level))
+}
+
+// Field is a key/value pair attached to a log record.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger receives the structured records logged by a Controller. The
+// values of the fields have been redacted before they reach it.
+type Logger interface {
+	// Enabled reports whether records at level are logged in ctx, so that
+	// expensive fields are only built when needed.
+	Enabled(ctx context.Context, level Level) bool
+	// Log logs a record made of msg and fields.
+	Log(ctx context.Context, level Level, msg string, fields ...Field)
+}
+
+// NewLoggoLogger returns a Logger writing to a loggo logger, with the
+// fields formatted as key=value pairs after the message.
+func NewLoggoLogger(logger loggo.Logger) Logger {
+	return loggoLogger{logger: logger}
+}
+
+// DefaultLogger returns the Logger used by controllers that are not given
+// one: the "maas" loggo logger.
+func DefaultLogger() Logger {
+	return NewLoggoLogger(loggo.GetLogger("maas"))
+}
+
+type loggoLogger struct {
+	logger loggo.Logger
+}
+
+func (l loggoLogger) Enabled(ctx context.Context, level Level) bool {
+	return l.logger.IsLevelEnabled(loggoLevel(level))
+}
+
+func (l loggoLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	var buf bytes.Buffer
+	buf.WriteString(msg)
+	for _, field := range fields {
+		if s, ok := field.Value.(string); ok {
+			fmt.Fprintf(&buf, " %s=%q", field.Key, s)
+		} else {
+			fmt.Fprintf(&buf, " %s=%v", field.Key, field.Value)
+		}
+	}
+	l.logger.LogCallf(2, loggoLevel(level), "%s", buf.String())
+}
+
+func loggoLevel(level Level) loggo.Level {
+	switch level {
+	case LevelTrace:
+		return loggo.TRACE
+	case LevelDebug:
+		return loggo.DEBUG
+	case LevelInfo:
+		return loggo.INFO
+	case LevelWarning:
+		return loggo.WARNING
+	}
+	return loggo.ERROR
+}
+
+// SlogLevelTrace is the slog level used for LevelTrace records, below
+// slog.LevelDebug.
+const SlogLevelTrace = slog.LevelDebug - 4
+
+// NewSlogLogger returns a Logger writing to a log/slog logger. LevelTrace
+// records are logged at SlogLevelTrace.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Enabled(ctx context.Context, level Level) bool {
+	return l.logger.Enabled(ctx, slogLevel(level))
+}
+
+func (l slogLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	l.logger.LogAttrs(ctx, slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level Level) slog.Level {
+	switch level {
+	case LevelTrace:
+		return SlogLevelTrace
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarning:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+// DiscardLogger is a Logger that logs nothing.
+var DiscardLogger Logger = discardLogger{}
+
+type discardLogger struct{}
+
+func (discardLogger) Enabled(ctx context.Context, level Level) bool {
+	return false
+}
+
+func (discardLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {}
+
+// Redacted replaces the values removed from logged parameters and bodies.
+const Redacted = "[REDACTED]"
+
+// Redaction describes what a Controller removes from the requests and
+// responses it logs. Names are matched case-insensitively against patterns
+// using the syntax of path.Match, so "power_parameters*" matches
+// "power_parameters_power_pass".
+type Redaction struct {
+	// Params are patterns of request parameters whose values are redacted.
+	Params []string
+	// Ops are the operations whose request parameter values are all
+	// redacted, because the parameter names are chosen by the caller.
+	Ops []string
+	// Keys are patterns of keys whose values are redacted, at any depth,
+	// from JSON response bodies.
+	Keys []string
+	// MaxBodyLength is the number of bytes of a response body logged
+	// before it is truncated. Zero means bodies are not truncated.
+	MaxBodyLength int
+}
+
+// DefaultRedaction returns the Redaction used by controllers that are not
+// given one. It removes user data, power parameters, owner data and
+// secrets, and truncates bodies after 2KiB.
+func DefaultRedaction() *Redaction {
+	return &Redaction{
+		Params: []string{"user_data", "power_parameters*", "*password*", "*secret*", "*token*"},
+		Ops:    []string{string(MachineSetOwnerData)},
+		Keys: []string{
+			"user_data", "power_parameters", "owner_data", "power_pass",
+			"*password*", "*secret*", "*token*",
+		},
+		MaxBodyLength: 2048,
+	}
+}
+
+// RedactParams returns the encoded params of a request for op, with the
+// sensitive values replaced.
+func (r *Redaction) RedactParams(op string, params url.Values) string {
+	if len(params) == 0 {
+		return ""
+	}
+	redactAll := matchAny(op, r.Ops)
+	redacted := make(url.Values, len(params))
+	for name, values := range params {
+		if !redactAll && !matchAny(name, r.Params) {
+			redacted[name] = values
+			continue
+		}
+		for range values {
+			redacted.Add(name, Redacted)
+		}
+	}
+	return redacted.Encode()
+}
+
+// RedactBody returns a response body with the values of sensitive keys
+// replaced, truncated to MaxBodyLength. Bodies that are not JSON are only
+// truncated.
+func (r *Redaction) RedactBody(body []byte) string {
+	var decoded interface{}
+	if len(r.Keys) > 0 && json.Unmarshal(body, &decoded) == nil {
+		if encoded, err := json.Marshal(r.redactValue(decoded)); err == nil {
+			body = encoded
+		}
+	}
+	if r.MaxBodyLength > 0 && len(body) > r.MaxBodyLength {
+		return fmt.Sprintf("%s... (%d bytes truncated)", body[:r.MaxBodyLength], len(body)-r.MaxBodyLength)
+	}
+	return string(body)
+}
+
+func (r *Redaction) redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if matchAny(key, r.Keys) {
+				value[key] = Redacted
+			} else {
+				value[key] = r.redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = r.redactValue(item)
+		}
+	}
+	return value
+}
+
+// matchAny reports whether name matches any of patterns.
+func matchAny(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package v2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type logRecord struct {
+	level  Level
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordingLogger records what it is given at the levels it enables.
+type recordingLogger struct {
+	level   Level
+	records []logRecord
+}
+
+func (l *recordingLogger) Enabled(ctx context.Context, level Level) bool {
+	return level >= l.level
+}
+
+func (l *recordingLogger) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	record := logRecord{level: level, msg: msg, fields: make(map[string]interface{})}
+	for _, field := range fields {
+		record.fields[field.Key] = field.Value
+	}
+	l.records = append(l.records, record)
+}
+
+func TestRedactParams(t *testing.T) {
+	redaction := DefaultRedaction()
+	params := url.Values{
+		"hostname":                    {"node-1"},
+		"user_data":                   {"#!/bin/sh"},
+		"power_parameters_power_pass": {"hunter2"},
+		"Power_Parameters":            {`{"power_pass": "hunter2"}`},
+	}
+
+	redacted, err := url.ParseQuery(redaction.RedactParams("", params))
+
+	assert.Nil(t, err)
+	assert.Equal(t, redacted.Get("hostname"), "node-1")
+	assert.Equal(t, redacted.Get("user_data"), Redacted)
+	assert.Equal(t, redacted.Get("power_parameters_power_pass"), Redacted)
+	assert.Equal(t, redacted.Get("Power_Parameters"), Redacted)
+}
+
+func TestRedactParamsOp(t *testing.T) {
+	redaction := DefaultRedaction()
+
+	redacted := redaction.RedactParams("set_owner_data", url.Values{"owner": {"alice"}})
+
+	assert.Equal(t, redacted, url.Values{"owner": {Redacted}}.Encode())
+}
+
+func TestRedactBody(t *testing.T) {
+	redaction := DefaultRedaction()
+	body := `[{"hostname": "node-1", "owner_data": {"owner": "alice"}, "nested": {"power_pass": "hunter2"}}]`
+
+	var redacted []map[string]interface{}
+	err := json.Unmarshal([]byte(redaction.RedactBody([]byte(body))), &redacted)
+
+	assert.Nil(t, err)
+	assert.Equal(t, redacted, []map[string]interface{}{{
+		"hostname":   "node-1",
+		"owner_data": Redacted,
+		"nested":     map[string]interface{}{"power_pass": Redacted},
+	}})
+}
+
+func TestRedactBodyTruncates(t *testing.T) {
+	redaction := &Redaction{MaxBodyLength: 4}
+
+	redacted := redaction.RedactBody([]byte("not json at all"))
+
+	assert.Equal(t, redacted, "not ... (11 bytes truncated)")
+}
+
+func TestControllerLogsRedacted(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	logger := &recordingLogger{level: LevelTrace}
+	controller.Logger = logger
+	server.AddPostResponse("/api/2.0/machines/abc/?op=deploy", http.StatusOK, `{"hostname": "node-1", "power_parameters": {"power_pass": "hunter2"}}`)
+
+	_, err := controller.Post("machines/abc", "deploy", url.Values{"user_data": {"#!/bin/sh"}, "comment": {"hello"}})
+
+	assert.Nil(t, err)
+	assert.Len(t, logger.records, 2)
+	request, response := logger.records[0], logger.records[1]
+	assert.Equal(t, request.msg, "request")
+	assert.Equal(t, request.level, LevelTrace)
+	assert.Equal(t, request.fields["method"], "POST")
+	assert.Equal(t, request.fields["op"], "deploy")
+	assert.Equal(t, request.fields["url"], server.URL+"/api/2.0/machines/abc/")
+	assert.Equal(t, request.fields["params"], url.Values{"comment": {"hello"}, "user_data": {Redacted}}.Encode())
+	assert.Equal(t, response.msg, "response")
+	assert.Equal(t, response.fields["request_id"], request.fields["request_id"])
+	body := response.fields["body"].(string)
+	assert.Contains(t, body, "node-1")
+	assert.False(t, strings.Contains(body, "hunter2"), body)
+}
+
+func TestControllerLogsErrors(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	logger := &recordingLogger{level: LevelTrace}
+	controller.Logger = logger
+
+	err := controller.Delete("machines/abc")
+
+	assert.NotNil(t, err)
+	assert.Len(t, logger.records, 2)
+	assert.Equal(t, logger.records[1].fields["status"], http.StatusNotFound)
+	assert.Equal(t, logger.records[1].fields["error"], errors.Cause(err).(client.ServerError).BodyMessage)
+}
+
+func TestControllerLogsErrorBodiesRedacted(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	logger := &recordingLogger{level: LevelTrace}
+	controller.Logger = logger
+	controller.Redaction = &Redaction{Keys: []string{"power_parameters"}, MaxBodyLength: 40}
+	body := `{"power_parameters": {"power_pass": "hunter2"}, "tags": "` + strings.Repeat("x", 50) + `"}`
+	server.AddPostResponse("/api/2.0/machines/abc/?op=power_on", http.StatusBadRequest, body)
+
+	_, err := controller.Post("machines/abc", "power_on", nil)
+
+	assert.NotNil(t, err)
+	assert.Len(t, logger.records, 2)
+	logged := logger.records[1].fields["error"].(string)
+	assert.Equal(t, logger.records[1].fields["status"], http.StatusBadRequest)
+	assert.NotContains(t, logged, "hunter2")
+	assert.Contains(t, logged, Redacted)
+	assert.Contains(t, logged, "bytes truncated")
+}
+
+func TestControllerLoggerDisabled(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	logger := &recordingLogger{level: LevelDebug}
+	controller.Logger = logger
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "[]")
+
+	_, err := controller.Get("machines", "", nil)
+
+	assert.Nil(t, err)
+	assert.Len(t, logger.records, 0)
+}
+
+func TestNewControllerLogger(t *testing.T) {
+	server := client.NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/?comment=hello", http.StatusOK, "[]")
+	server.Start()
+	defer server.Close()
+	logger := &recordingLogger{level: LevelTrace}
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:   server.URL,
+		APIKey:    "fake:as:key",
+		Logger:    logger,
+		Redaction: &Redaction{Params: []string{"comment"}},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, controller.Logger, Logger(logger))
+	assert.Len(t, logger.records, 4)
+	_, err = controller.Get("machines", "", url.Values{"comment": {"hello"}})
+	assert.Nil(t, err)
+	assert.Equal(t, logger.records[4].fields["params"], "comment="+url.QueryEscape(Redacted))
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: SlogLevelTrace})))
+
+	assert.True(t, logger.Enabled(context.Background(), LevelTrace))
+	logger.Log(context.Background(), LevelTrace, "request", Field{"request_id", int64(7)}, Field{"method", "GET"})
+
+	var record map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &record)
+	assert.Nil(t, err)
+	assert.Equal(t, record["msg"], "request")
+	assert.Equal(t, record["level"], fmt.Sprint(SlogLevelTrace))
+	assert.Equal(t, record["request_id"], float64(7))
+	assert.Equal(t, record["method"], "GET")
+}
+
+func TestSlogLoggerLevels(t *testing.T) {
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	assert.False(t, logger.Enabled(context.Background(), LevelTrace))
+	assert.False(t, logger.Enabled(context.Background(), LevelDebug))
+	assert.True(t, logger.Enabled(context.Background(), LevelInfo))
+	assert.True(t, logger.Enabled(context.Background(), LevelError))
+}
@@ -20,9 +20,10 @@ type Machine struct {
 	CPUCount        int               `json:"cpu_count,omitempty"`
 	IPAddresses     []string          `json:"ip_addresses,omitempty"`
 	PowerState      string            `json:"power_state,omitempty"`
-	// NOTE: consider some form of status struct
-	StatusName    string `json:"status_name,omitempty"`
-	StatusMessage string `json:"status_message,omitempty"`
+	// Status is the status of the machine; StatusName is its name.
+	Status        NodeStatus `json:"status,omitempty"`
+	StatusName    string     `json:"status_name,omitempty"`
+	StatusMessage string     `json:"status_message,omitempty"`
 	// BootInterface returns the interface that was used to boot the MachineInterface.
 	BootInterface *NetworkInterface `json:"boot_interface,omitempty"`
 	// InterfaceSet returns all the interfaces for the MachineInterface.
@@ -50,6 +51,7 @@ func (m *Machine) updateFrom(other *Machine) {
 	m.CPUCount = other.CPUCount
 	m.IPAddresses = other.IPAddresses
 	m.PowerState = other.PowerState
+	m.Status = other.Status
 	m.StatusName = other.StatusName
 	m.StatusMessage = other.StatusMessage
 	m.Zone = other.Zone
@@ -81,6 +81,69 @@ type CommissionMachineArgs struct {
 	TestingScript        string
 }
 
+// ReleaseMachineArgs is an argument struct for passing parameters to the
+// ReleaseMachine method.
+type ReleaseMachineArgs struct {
+	Comment     string
+	Erase       bool
+	SecureErase bool
+	QuickErase  bool
+}
+
+// AbortMachineArgs is an argument struct for passing parameters to the
+// AbortMachine method.
+type AbortMachineArgs struct {
+	Comment string
+}
+
+// MarkMachineBrokenArgs is an argument struct for passing parameters to the
+// MarkMachineBroken method.
+type MarkMachineBrokenArgs struct {
+	Comment string
+}
+
+// MarkMachineFixedArgs is an argument struct for passing parameters to the
+// MarkMachineFixed method.
+type MarkMachineFixedArgs struct {
+	Comment string
+}
+
+// PowerOnMachineArgs is an argument struct for passing parameters to the
+// PowerOnMachine method.
+type PowerOnMachineArgs struct {
+	// UserData needs to be Base64 encoded user data for cloud-init.
+	UserData string
+	Comment  string
+}
+
+// PowerOffMachineArgs is an argument struct for passing parameters to the
+// PowerOffMachine method.
+type PowerOffMachineArgs struct {
+	// StopMode is either "hard", the default, or "soft".
+	StopMode string
+	Comment  string
+}
+
+// MountSpecialArgs is an argument struct for passing parameters to the
+// MountSpecial method.
+type MountSpecialArgs struct {
+	// FSType is the special-purpose filesystem, e.g. "tmpfs" or "ramfs".
+	FSType       string
+	MountPoint   string
+	MountOptions string
+}
+
+// Validate checks that the filesystem and mount point are set.
+func (a *MountSpecialArgs) Validate() error {
+	if a.FSType == "" {
+		return errors.NotValidf("missing FSType")
+	}
+	if a.MountPoint == "" {
+		return errors.NotValidf("missing MountPoint")
+	}
+	return nil
+}
+
 // Validate makes sure that any labels specifed in Storage or Interfaces
 // are unique, and that the required specifications are valid.
 func (a *AllocateMachineArgs) Validate() error {
@@ -151,17 +214,17 @@ func MachinesParams(args MachinesArgs) *util.URLParams {
 
 func AllocateMachinesParams(args AllocateMachineArgs) *util.URLParams {
 	params := util.NewURLParams()
-	params.MaybeAdd("Name", args.Hostname)
+	params.MaybeAdd("name", args.Hostname)
 	params.MaybeAdd("system_id", args.SystemId)
 	params.MaybeAdd("arch", args.Architecture)
 	params.MaybeAddInt("cpu_count", args.MinCPUCount)
 	params.MaybeAddInt("mem", args.MinMemory)
-	params.MaybeAddMany("Tags", args.Tags)
+	params.MaybeAddMany("tags", args.Tags)
 	params.MaybeAddMany("not_tags", args.NotTags)
 	params.MaybeAdd("storage", args.storage())
 	params.MaybeAdd("interfaces", args.interfaces())
 	params.MaybeAddMany("not_subnets", args.notSubnets())
-	params.MaybeAdd("Zone", args.Zone)
+	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAddMany("not_in_zone", args.NotInZone)
 	params.MaybeAdd("agent_name", args.AgentName)
 	params.MaybeAdd("comment", args.Comment)
@@ -204,6 +267,44 @@ func CommissionMachineParams(args CommissionMachineArgs) *util.URLParams {
 	return params
 }
 
+func ReleaseMachineParams(args ReleaseMachineArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("comment", args.Comment)
+	params.MaybeAddBool("erase", args.Erase)
+	params.MaybeAddBool("secure_erase", args.SecureErase)
+	params.MaybeAddBool("quick_erase", args.QuickErase)
+	return params
+}
+
+func PowerOnMachineParams(args PowerOnMachineArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("user_data", args.UserData)
+	params.MaybeAdd("comment", args.Comment)
+	return params
+}
+
+func PowerOffMachineParams(args PowerOffMachineArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("stop_mode", args.StopMode)
+	params.MaybeAdd("comment", args.Comment)
+	return params
+}
+
+func MountSpecialParams(args MountSpecialArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("fstype", args.FSType)
+	params.MaybeAdd("mount_point", args.MountPoint)
+	params.MaybeAdd("mount_options", args.MountOptions)
+	return params
+}
+
+// commentParams returns the params of the operations taking only a comment.
+func commentParams(comment string) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("comment", comment)
+	return params
+}
+
 func parseAllocateConstraintsResponse(source interface{}, machine *Machine) (ConstraintMatches, error) {
 	var empty ConstraintMatches
 	matchFields := schema.Fields{
@@ -43,6 +43,7 @@ func TestReadMachines(t *testing.T) {
 	assert.Equal(t, machine.OperatingSystem, "ubuntu")
 	assert.Equal(t, machine.DistroSeries, "trusty")
 	assert.Equal(t, machine.Architecture, "amd64/generic")
+	assert.Equal(t, machine.Status, NodeStatusDeployed)
 	assert.Equal(t, machine.StatusName, "Deployed")
 	assert.Equal(t, machine.StatusMessage, "From 'Deploying' to 'Deployed'")
 
@@ -1,5 +1,16 @@
 package v2
 
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
 type MachineOp string
 
 const (
@@ -54,3 +65,315 @@ const (
 	// Allocate an available machine for deployment.
 	Allocate MachinesOp = "allocate"
 )
+
+// CommissionMachine begins commissioning the machine, and updates it with
+// its new state.
+func (c *Controller) CommissionMachine(m *Machine, args CommissionMachineArgs) error {
+	return c.CommissionMachineContext(context.Background(), m, args)
+}
+
+// CommissionMachineContext is like CommissionMachine but the request is bound to ctx.
+func (c *Controller) CommissionMachineContext(ctx context.Context, m *Machine, args CommissionMachineArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "CommissionMachine")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineComission, CommissionMachineParams(args).Values)
+}
+
+// AbortMachine aborts the current operation of the machine, such as
+// commissioning or deploying, and updates it with its new state.
+func (c *Controller) AbortMachine(m *Machine, args AbortMachineArgs) error {
+	return c.AbortMachineContext(context.Background(), m, args)
+}
+
+// AbortMachineContext is like AbortMachine but the request is bound to ctx.
+func (c *Controller) AbortMachineContext(ctx context.Context, m *Machine, args AbortMachineArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "AbortMachine")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineAbort, commentParams(args.Comment).Values)
+}
+
+// ReleaseMachine releases the machine back to the pool, and updates it with
+// its new state. Use ReleaseMachines to release several machines at once.
+func (c *Controller) ReleaseMachine(m *Machine, args ReleaseMachineArgs) error {
+	return c.ReleaseMachineContext(context.Background(), m, args)
+}
+
+// ReleaseMachineContext is like ReleaseMachine but the request is bound to ctx.
+func (c *Controller) ReleaseMachineContext(ctx context.Context, m *Machine, args ReleaseMachineArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "ReleaseMachine")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineRelease, ReleaseMachineParams(args).Values)
+}
+
+// PowerOnMachine turns the machine on, and updates it with its new state.
+func (c *Controller) PowerOnMachine(m *Machine, args PowerOnMachineArgs) error {
+	return c.PowerOnMachineContext(context.Background(), m, args)
+}
+
+// PowerOnMachineContext is like PowerOnMachine but the request is bound to ctx.
+func (c *Controller) PowerOnMachineContext(ctx context.Context, m *Machine, args PowerOnMachineArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "PowerOnMachine")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachinePowerON, PowerOnMachineParams(args).Values)
+}
+
+// PowerOffMachine turns the machine off, and updates it with its new state.
+func (c *Controller) PowerOffMachine(m *Machine, args PowerOffMachineArgs) error {
+	return c.PowerOffMachineContext(context.Background(), m, args)
+}
+
+// PowerOffMachineContext is like PowerOffMachine but the request is bound to ctx.
+func (c *Controller) PowerOffMachineContext(ctx context.Context, m *Machine, args PowerOffMachineArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "PowerOffMachine")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachinePowerOFF, PowerOffMachineParams(args).Values)
+}
+
+// MarkMachineBroken marks the machine as broken, and updates it with its new
+// state.
+func (c *Controller) MarkMachineBroken(m *Machine, args MarkMachineBrokenArgs) error {
+	return c.MarkMachineBrokenContext(context.Background(), m, args)
+}
+
+// MarkMachineBrokenContext is like MarkMachineBroken but the request is bound to ctx.
+func (c *Controller) MarkMachineBrokenContext(ctx context.Context, m *Machine, args MarkMachineBrokenArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "MarkMachineBroken")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineMarkBroken, commentParams(args.Comment).Values)
+}
+
+// MarkMachineFixed marks a broken machine as fixed, so that it is ready
+// again, and updates it with its new state.
+func (c *Controller) MarkMachineFixed(m *Machine, args MarkMachineFixedArgs) error {
+	return c.MarkMachineFixedContext(context.Background(), m, args)
+}
+
+// MarkMachineFixedContext is like MarkMachineFixed but the request is bound to ctx.
+func (c *Controller) MarkMachineFixedContext(ctx context.Context, m *Machine, args MarkMachineFixedArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "MarkMachineFixed")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineMarkFixed, commentParams(args.Comment).Values)
+}
+
+// EnterRescueMode begins booting the machine into rescue mode, and updates
+// it with its new state.
+func (c *Controller) EnterRescueMode(m *Machine) error {
+	return c.EnterRescueModeContext(context.Background(), m)
+}
+
+// EnterRescueModeContext is like EnterRescueMode but the request is bound to ctx.
+func (c *Controller) EnterRescueModeContext(ctx context.Context, m *Machine) (err error) {
+	ctx, end := c.startSpan(ctx, "EnterRescueMode")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineRescueMode, nil)
+}
+
+// ExitRescueMode begins taking the machine out of rescue mode, and updates
+// it with its new state.
+func (c *Controller) ExitRescueMode(m *Machine) error {
+	return c.ExitRescueModeContext(context.Background(), m)
+}
+
+// ExitRescueModeContext is like ExitRescueMode but the request is bound to ctx.
+func (c *Controller) ExitRescueModeContext(ctx context.Context, m *Machine) (err error) {
+	ctx, end := c.startSpan(ctx, "ExitRescueMode")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineExitRescueMode, nil)
+}
+
+// RestoreDefaultConfiguration resets the networking and storage
+// configuration of the machine to their initial state, and updates it.
+func (c *Controller) RestoreDefaultConfiguration(m *Machine) error {
+	return c.RestoreDefaultConfigurationContext(context.Background(), m)
+}
+
+// RestoreDefaultConfigurationContext is like RestoreDefaultConfiguration but the request is bound to ctx.
+func (c *Controller) RestoreDefaultConfigurationContext(ctx context.Context, m *Machine) (err error) {
+	ctx, end := c.startSpan(ctx, "RestoreDefaultConfiguration")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineRestoreDefaultConfig, nil)
+}
+
+// RestoreNetworkingConfiguration resets the networking configuration of the
+// machine to its initial state, and updates it.
+func (c *Controller) RestoreNetworkingConfiguration(m *Machine) error {
+	return c.RestoreNetworkingConfigurationContext(context.Background(), m)
+}
+
+// RestoreNetworkingConfigurationContext is like RestoreNetworkingConfiguration but the request is bound to ctx.
+func (c *Controller) RestoreNetworkingConfigurationContext(ctx context.Context, m *Machine) (err error) {
+	ctx, end := c.startSpan(ctx, "RestoreNetworkingConfiguration")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineRestoreNetworkConfig, nil)
+}
+
+// SetStorageLayout replaces the storage configuration of the machine with
+// the layout of args, and updates it. The machine must be ready or
+// allocated. The args are validated before anything is posted.
+func (c *Controller) SetStorageLayout(m *Machine, args SetStorageLayoutArgs) error {
+	return c.SetStorageLayoutContext(context.Background(), m, args)
+}
+
+// SetStorageLayoutContext is like SetStorageLayout but the request is bound to ctx.
+func (c *Controller) SetStorageLayoutContext(ctx context.Context, m *Machine, args SetStorageLayoutArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "SetStorageLayout")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.postMachineOp(ctx, m, MachineSetStorageLayout, SetStorageLayoutParams(args).Values)
+}
+
+// RestoreStorageConfiguration resets the storage configuration of the
+// machine to its initial state, undoing SetStorageLayout and any custom
+// storage configuration, and updates it.
+func (c *Controller) RestoreStorageConfiguration(m *Machine) error {
+	return c.RestoreStorageConfigurationContext(context.Background(), m)
+}
+
+// RestoreStorageConfigurationContext is like RestoreStorageConfiguration but the request is bound to ctx.
+func (c *Controller) RestoreStorageConfigurationContext(ctx context.Context, m *Machine) (err error) {
+	ctx, end := c.startSpan(ctx, "RestoreStorageConfiguration")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineRestoreStorageConfig, nil)
+}
+
+// ClearDefaultGateways clears the default gateways of the machine, so that
+// maas picks them again, and updates it.
+func (c *Controller) ClearDefaultGateways(m *Machine) error {
+	return c.ClearDefaultGatewaysContext(context.Background(), m)
+}
+
+// ClearDefaultGatewaysContext is like ClearDefaultGateways but the request is bound to ctx.
+func (c *Controller) ClearDefaultGatewaysContext(ctx context.Context, m *Machine) (err error) {
+	ctx, end := c.startSpan(ctx, "ClearDefaultGateways")
+	defer end(&err)
+	return c.postMachineOp(ctx, m, MachineClearDefaultGateways, nil)
+}
+
+// MountSpecial mounts a special-purpose filesystem, such as tmpfs, on the
+// machine, and updates it.
+func (c *Controller) MountSpecial(m *Machine, args MountSpecialArgs) error {
+	return c.MountSpecialContext(context.Background(), m, args)
+}
+
+// MountSpecialContext is like MountSpecial but the request is bound to ctx.
+func (c *Controller) MountSpecialContext(ctx context.Context, m *Machine, args MountSpecialArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "MountSpecial")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return err
+	}
+	return c.postMachineOp(ctx, m, MachineMountSpecial, MountSpecialParams(args).Values)
+}
+
+// UnmountSpecial unmounts the special-purpose filesystem mounted at
+// mountPoint on the machine, and updates it.
+func (c *Controller) UnmountSpecial(m *Machine, mountPoint string) error {
+	return c.UnmountSpecialContext(context.Background(), m, mountPoint)
+}
+
+// UnmountSpecialContext is like UnmountSpecial but the request is bound to ctx.
+func (c *Controller) UnmountSpecialContext(ctx context.Context, m *Machine, mountPoint string) (err error) {
+	ctx, end := c.startSpan(ctx, "UnmountSpecial")
+	defer end(&err)
+	if mountPoint == "" {
+		return errors.NotValidf("missing mount point")
+	}
+	return c.postMachineOp(ctx, m, MachineUnmountSpecial, url.Values{"mount_point": {mountPoint}})
+}
+
+// MachineDetails returns the details maas collected about the machine while
+// commissioning it, such as the lshw and lldp output, BSON encoded.
+func (c *Controller) MachineDetails(m *Machine) ([]byte, error) {
+	return c.MachineDetailsContext(context.Background(), m)
+}
+
+// MachineDetailsContext is like MachineDetails but the request is bound to
+// ctx.
+func (c *Controller) MachineDetailsContext(ctx context.Context, m *Machine) (_ []byte, err error) {
+	ctx, end := c.startSpan(ctx, "MachineDetails")
+	defer end(&err)
+	return c.getMachineOp(ctx, m, MachineDetails)
+}
+
+// CurtinConfig returns the rendered curtin configuration of the machine, in
+// YAML.
+func (c *Controller) CurtinConfig(m *Machine) ([]byte, error) {
+	return c.CurtinConfigContext(context.Background(), m)
+}
+
+// CurtinConfigContext is like CurtinConfig but the request is bound to ctx.
+func (c *Controller) CurtinConfigContext(ctx context.Context, m *Machine) (_ []byte, err error) {
+	ctx, end := c.startSpan(ctx, "CurtinConfig")
+	defer end(&err)
+	return c.getMachineOp(ctx, m, MachineGetCurtinConfig)
+}
+
+// PowerParameters returns the power parameters of the machine. They include
+// the credentials of its BMC.
+func (c *Controller) PowerParameters(m *Machine) (map[string]interface{}, error) {
+	return c.PowerParametersContext(context.Background(), m)
+}
+
+// PowerParametersContext is like PowerParameters but the request is bound to
+// ctx.
+func (c *Controller) PowerParametersContext(ctx context.Context, m *Machine) (_ map[string]interface{}, err error) {
+	ctx, end := c.startSpan(ctx, "PowerParameters")
+	defer end(&err)
+	source, err := c.getMachineOp(ctx, m, PowerParams)
+	if err != nil {
+		return nil, err
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(source, &params); err != nil {
+		return nil, util.WrapWithDeserializationError(err, "power parameters")
+	}
+	return params, nil
+}
+
+// postMachineOp posts op to the machine m, and updates m from the machine
+// returned. See machineOpError for the errors returned.
+func (c *Controller) postMachineOp(ctx context.Context, m *Machine, op MachineOp, params url.Values) error {
+	result, err := c.PostContext(ctx, m.ResourceURI, string(op), params)
+	if err != nil {
+		return machineOpError(err)
+	}
+	var machine *Machine
+	if err := json.Unmarshal(result, &machine); err != nil {
+		return util.WrapWithDeserializationError(err, "%s response", op)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// getMachineOp gets the result of op on the machine m. See machineOpError for
+// the errors returned.
+func (c *Controller) getMachineOp(ctx context.Context, m *Machine, op MachineOp) ([]byte, error) {
+	result, err := c.GetContext(ctx, m.ResourceURI, string(op), nil)
+	if err != nil {
+		return nil, machineOpError(err)
+	}
+	return result, nil
+}
+
+// machineOpError maps the error of a machine operation, or of an operation on
+// the storage of a machine. Returns
+//   - BadRequestError if the request is not valid or the machine cannot be found
+//   - PermissionError if the user does not have permission to act on the machine
+//   - CannotCompleteError if the machine is not in a state allowing the operation, or
+//     the server cannot do it now
+//   - UnexpectedError otherwise
+func machineOpError(err error) error {
+	if svrErr, ok := errors.Cause(err).(client.ServerError); ok {
+		switch svrErr.StatusCode {
+		case http.StatusBadRequest, http.StatusNotFound:
+			return errors.Wrap(err, util.NewBadRequestError(svrErr.BodyMessage))
+		case http.StatusForbidden:
+			return errors.Wrap(err, util.NewPermissionError(svrErr.BodyMessage))
+		case http.StatusConflict, http.StatusServiceUnavailable:
+			return errors.Wrap(err, util.NewCannotCompleteError(svrErr.BodyMessage))
+		}
+	}
+	return util.NewUnexpectedError(err)
+}
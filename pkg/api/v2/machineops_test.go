@@ -0,0 +1,152 @@
+package v2
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMachineCommission(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	response := util.UpdateJSONMap(t, machineResponse, map[string]interface{}{
+		"status_name": "Commissioning",
+	})
+	server.AddPostResponse(machine.ResourceURI+"?op=commission", http.StatusOK, response)
+
+	err := controller.CommissionMachine(machine, CommissionMachineArgs{EnableSSH: true, SkipStorage: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, machine.StatusName, "Commissioning")
+	form := server.LastRequest().PostForm
+	assert.Len(t, form, 2)
+	assert.Equal(t, form.Get("enable_ssh"), "true")
+	assert.Equal(t, form.Get("skip_storage"), "true")
+}
+
+func TestMachinePowerOff(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	response := util.UpdateJSONMap(t, machineResponse, map[string]interface{}{
+		"power_state": "off",
+	})
+	server.AddPostResponse(machine.ResourceURI+"?op=power_off", http.StatusOK, response)
+
+	err := controller.PowerOffMachine(machine, PowerOffMachineArgs{StopMode: "soft", Comment: "bye"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, machine.PowerState, "off")
+	form := server.LastRequest().PostForm
+	assert.Equal(t, form.Get("stop_mode"), "soft")
+	assert.Equal(t, form.Get("comment"), "bye")
+}
+
+func TestMachineOpsWithoutArgs(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	ops := map[MachineOp]func(*Machine) error{
+		MachineRescueMode:           controller.EnterRescueMode,
+		MachineExitRescueMode:       controller.ExitRescueMode,
+		MachineRestoreDefaultConfig: controller.RestoreDefaultConfiguration,
+		MachineRestoreNetworkConfig: controller.RestoreNetworkingConfiguration,
+		MachineRestoreStorageConfig: controller.RestoreStorageConfiguration,
+		MachineClearDefaultGateways: controller.ClearDefaultGateways,
+	}
+	for op, call := range ops {
+		response := util.UpdateJSONMap(t, machineResponse, map[string]interface{}{
+			"status_message": string(op),
+		})
+		server.AddPostResponse(machine.ResourceURI+"?op="+string(op), http.StatusOK, response)
+
+		err := call(machine)
+
+		assert.Nil(t, err, string(op))
+		assert.Equal(t, machine.StatusMessage, string(op))
+		assert.Len(t, server.LastRequest().PostForm, 0)
+	}
+}
+
+func TestMachineMountSpecial(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddPostResponse(machine.ResourceURI+"?op=mount_special", http.StatusOK, machineResponse)
+	server.AddPostResponse(machine.ResourceURI+"?op=unmount_special", http.StatusOK, machineResponse)
+
+	err := controller.MountSpecial(machine, MountSpecialArgs{FSType: "tmpfs", MountPoint: "/scratch"})
+	assert.Nil(t, err)
+	form := server.LastRequest().PostForm
+	assert.Equal(t, form.Get("fstype"), "tmpfs")
+	assert.Equal(t, form.Get("mount_point"), "/scratch")
+
+	err = controller.UnmountSpecial(machine, "/scratch")
+	assert.Nil(t, err)
+	assert.Equal(t, server.LastRequest().PostForm.Get("mount_point"), "/scratch")
+}
+
+func TestMachineMountSpecialValidates(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	requests := server.RequestCount()
+
+	err := controller.MountSpecial(machine, MountSpecialArgs{FSType: "tmpfs"})
+	assert.True(t, errors.IsNotValid(err))
+	err = controller.UnmountSpecial(machine, "")
+	assert.True(t, errors.IsNotValid(err))
+
+	assert.Equal(t, server.RequestCount(), requests)
+}
+
+func TestMachineOpErrors(t *testing.T) {
+	for _, test := range []struct {
+		status int
+		check  func(error) bool
+	}{
+		{http.StatusBadRequest, util.IsBadRequestError},
+		{http.StatusNotFound, util.IsBadRequestError},
+		{http.StatusForbidden, util.IsPermissionError},
+		{http.StatusConflict, util.IsCannotCompleteError},
+		{http.StatusServiceUnavailable, util.IsCannotCompleteError},
+		{http.StatusMethodNotAllowed, util.IsUnexpectedError},
+	} {
+		server, machine, controller := getMachineControllerAndServer(t)
+		server.AddPostResponse(machine.ResourceURI+"?op=mark_broken", test.status, "no")
+
+		err := controller.MarkMachineBroken(machine, MarkMachineBrokenArgs{Comment: "smoke"})
+
+		assert.True(t, test.check(err), "%d: %v", test.status, err)
+		assert.Equal(t, machine.StatusName, "Deployed")
+		server.Close()
+	}
+}
+
+func TestMachinePowerParameters(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddGetResponse(machine.ResourceURI+"?op=power_parameters", http.StatusOK,
+		`{"power_address": "qemu+ssh://ubuntu@10.0.0.1/system", "power_id": "node-1"}`)
+
+	params, err := controller.PowerParameters(machine)
+
+	assert.Nil(t, err)
+	assert.Equal(t, params, map[string]interface{}{
+		"power_address": "qemu+ssh://ubuntu@10.0.0.1/system",
+		"power_id":      "node-1",
+	})
+}
+
+func TestMachineCurtinConfig(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddGetResponse(machine.ResourceURI+"?op=get_curtin_config", http.StatusOK, "partitioning_commands: {}\n")
+	server.AddGetResponse(machine.ResourceURI+"?op=details", http.StatusForbidden, "not yours")
+
+	config, err := controller.CurtinConfig(machine)
+	assert.Nil(t, err)
+	assert.Equal(t, string(config), "partitioning_commands: {}\n")
+
+	_, err = controller.MachineDetails(machine)
+	assert.True(t, util.IsPermissionError(err))
+}
@@ -3,55 +3,204 @@
 
 package v2
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+// NodeStatus is the status of a Node, as the number maas uses for it in the
+// "status" field, in decimal.
+type NodeStatus string
+
 const (
 	// NodeStatus* Values represent the vocabulary of a Node‘s possible statuses.
 
 	// The Node has been created and has a system ID assigned to it.
-	NodeStatusDeclared = "0"
+	NodeStatusDeclared NodeStatus = "0"
 
 	//Testing and other commissioning steps are taking place.
-	NodeStatusCommissioning = "1"
+	NodeStatusCommissioning NodeStatus = "1"
 
 	// Smoke or burn-in testing has a found a problem.
-	NodeStatusFailedTests = "2"
+	NodeStatusFailedTests NodeStatus = "2"
 
 	// The Node can’t be contacted.
-	NodeStatusMissing = "3"
+	NodeStatusMissing NodeStatus = "3"
 
 	// The Node is in the general pool ready to be deployed.
-	NodeStatusReady = "4"
+	NodeStatusReady NodeStatus = "4"
 
 	// The Node is ready for named deployment.
-	NodeStatusReserved = "5"
+	NodeStatusReserved NodeStatus = "5"
 
 	// The Node is powering a service from a charm or is ready for use with a fresh Ubuntu install.
-	NodeStatusDeployed = "6"
+	NodeStatusDeployed NodeStatus = "6"
 
 	// The Node has been removed from service manually until an admin overrides the retirement.
-	NodeStatusRetired = "7"
+	NodeStatusRetired NodeStatus = "7"
 
 	// The Node is broken: a step in the Node lifecyle failed. More details
 	// can be found in the Node's event log.
-	NodeStatusBroken = "8"
+	NodeStatusBroken NodeStatus = "8"
 
 	// The Node is being installed.
-	NodeStatusDeploying = "9"
+	NodeStatusDeploying NodeStatus = "9"
 
 	// The Node has been allocated to a user and is ready for deployment.
-	NodeStatusAllocated = "10"
+	NodeStatusAllocated NodeStatus = "10"
 
 	// The deployment of the Node failed.
-	NodeStatusFailedDeployment = "11"
+	NodeStatusFailedDeployment NodeStatus = "11"
 
 	// The Node is powering down after a release request.
-	NodeStatusReleasing = "12"
+	NodeStatusReleasing NodeStatus = "12"
 
 	// The releasing of the Node failed.
-	NodeStatusFailedReleasing = "13"
+	NodeStatusFailedReleasing NodeStatus = "13"
 
 	// The Node is erasing its disks.
-	NodeStatusDiskErasing = "14"
+	NodeStatusDiskErasing NodeStatus = "14"
 
 	// The Node failed to erase its disks.
-	NodeStatusFailedDiskErasing = "15"
+	NodeStatusFailedDiskErasing NodeStatus = "15"
+
+	// The Node is in rescue mode.
+	NodeStatusRescueMode NodeStatus = "16"
+
+	// The Node is booting into rescue mode.
+	NodeStatusEnteringRescueMode NodeStatus = "17"
+
+	// The Node failed to boot into rescue mode.
+	NodeStatusFailedEnteringRescueMode NodeStatus = "18"
+
+	// The Node is leaving rescue mode.
+	NodeStatusExitingRescueMode NodeStatus = "19"
+
+	// The Node failed to leave rescue mode.
+	NodeStatusFailedExitingRescueMode NodeStatus = "20"
+
+	// Hardware tests are running on the Node.
+	NodeStatusTesting NodeStatus = "21"
+
+	// The hardware tests found a problem with the Node.
+	NodeStatusFailedTesting NodeStatus = "22"
 )
+
+// nodeStatusNames are the names maas shows for the statuses, in the
+// "status_name" field.
+var nodeStatusNames = map[NodeStatus]string{
+	NodeStatusDeclared:                 "New",
+	NodeStatusCommissioning:            "Commissioning",
+	NodeStatusFailedTests:              "Failed commissioning",
+	NodeStatusMissing:                  "Missing",
+	NodeStatusReady:                    "Ready",
+	NodeStatusReserved:                 "Reserved",
+	NodeStatusDeployed:                 "Deployed",
+	NodeStatusRetired:                  "Retired",
+	NodeStatusBroken:                   "Broken",
+	NodeStatusDeploying:                "Deploying",
+	NodeStatusAllocated:                "Allocated",
+	NodeStatusFailedDeployment:         "Failed deployment",
+	NodeStatusReleasing:                "Releasing",
+	NodeStatusFailedReleasing:          "Releasing failed",
+	NodeStatusDiskErasing:              "Disk erasing",
+	NodeStatusFailedDiskErasing:        "Failed disk erasing",
+	NodeStatusRescueMode:               "Rescue mode",
+	NodeStatusEnteringRescueMode:       "Entering rescue mode",
+	NodeStatusFailedEnteringRescueMode: "Failed to enter rescue mode",
+	NodeStatusExitingRescueMode:        "Exiting rescue mode",
+	NodeStatusFailedExitingRescueMode:  "Failed to exit rescue mode",
+	NodeStatusTesting:                  "Testing",
+	NodeStatusFailedTesting:            "Failed testing",
+}
+
+// ParseNodeStatus returns the status with the given name, as found in the
+// StatusName of a Machine. The case of name is ignored.
+func ParseNodeStatus(name string) (NodeStatus, error) {
+	for status, statusName := range nodeStatusNames {
+		if strings.EqualFold(name, statusName) {
+			return status, nil
+		}
+	}
+	return "", errors.NotValidf("node status %q", name)
+}
+
+// String returns the name maas shows for the status.
+func (s NodeStatus) String() string {
+	if name, ok := nodeStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("NodeStatus(%s)", string(s))
+}
+
+// Number returns the number maas uses for the status, or -1 if s is not a
+// number.
+func (s NodeStatus) Number() int {
+	n, err := strconv.Atoi(string(s))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// IsFailed reports whether the status records the failure of an operation,
+// or a Node that needs the attention of an administrator.
+func (s NodeStatus) IsFailed() bool {
+	switch s {
+	case NodeStatusFailedTests, NodeStatusBroken, NodeStatusFailedDeployment,
+		NodeStatusFailedReleasing, NodeStatusFailedDiskErasing,
+		NodeStatusFailedEnteringRescueMode, NodeStatusFailedExitingRescueMode,
+		NodeStatusFailedTesting:
+		return true
+	}
+	return false
+}
+
+// IsTransitional reports whether the status is that of an operation in
+// progress, which maas moves the Node out of on its own.
+func (s NodeStatus) IsTransitional() bool {
+	switch s {
+	case NodeStatusCommissioning, NodeStatusDeploying, NodeStatusReleasing,
+		NodeStatusDiskErasing, NodeStatusEnteringRescueMode,
+		NodeStatusExitingRescueMode, NodeStatusTesting:
+		return true
+	}
+	return false
+}
+
+// IsTerminal reports whether the Node stays in the status until it is acted
+// upon, i.e. whether the status is not transitional.
+func (s NodeStatus) IsTerminal() bool {
+	return !s.IsTransitional()
+}
+
+// UnmarshalJSON accepts the number maas sends, as well as a string.
+func (s *NodeStatus) UnmarshalJSON(j []byte) error {
+	if string(j) == "null" {
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(j, &n); err == nil {
+		*s = NodeStatus(strconv.Itoa(n))
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(j, &str); err != nil {
+		return util.WrapWithDeserializationError(err, "node status")
+	}
+	*s = NodeStatus(str)
+	return nil
+}
+
+// MarshalJSON writes the status as a number, like maas.
+func (s NodeStatus) MarshalJSON() ([]byte, error) {
+	if n := s.Number(); n >= 0 {
+		return json.Marshal(n)
+	}
+	return json.Marshal(string(s))
+}
@@ -0,0 +1,55 @@
+package v2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeStatusNames(t *testing.T) {
+	for status := range nodeStatusNames {
+		parsed, err := ParseNodeStatus(status.String())
+		assert.Nil(t, err)
+		assert.Equal(t, parsed, status)
+	}
+	status, err := ParseNodeStatus("failed DEPLOYMENT")
+	assert.Nil(t, err)
+	assert.Equal(t, status, NodeStatusFailedDeployment)
+	assert.Equal(t, status.Number(), 11)
+
+	_, err = ParseNodeStatus("Sleeping")
+	assert.True(t, errors.IsNotValid(err))
+	assert.Equal(t, NodeStatus("99").String(), "NodeStatus(99)")
+}
+
+func TestNodeStatusClassification(t *testing.T) {
+	assert.True(t, NodeStatusDeploying.IsTransitional())
+	assert.False(t, NodeStatusDeploying.IsTerminal())
+	assert.False(t, NodeStatusDeploying.IsFailed())
+
+	assert.True(t, NodeStatusDeployed.IsTerminal())
+	assert.False(t, NodeStatusDeployed.IsFailed())
+
+	assert.True(t, NodeStatusFailedDiskErasing.IsTerminal())
+	assert.True(t, NodeStatusFailedDiskErasing.IsFailed())
+	for status := range nodeStatusNames {
+		assert.False(t, status.IsFailed() && status.IsTransitional(), status.String())
+	}
+}
+
+func TestNodeStatusJSON(t *testing.T) {
+	var m Machine
+	err := json.Unmarshal([]byte(`{"status": 9, "status_name": "Deploying"}`), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, m.Status, NodeStatusDeploying)
+
+	err = json.Unmarshal([]byte(`{"status": "4"}`), &m)
+	assert.Nil(t, err)
+	assert.Equal(t, m.Status, NodeStatusReady)
+
+	data, err := json.Marshal(NodeStatusReady)
+	assert.Nil(t, err)
+	assert.Equal(t, string(data), "4")
+}
@@ -0,0 +1,167 @@
+package v2
+
+import (
+	"context"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// ErrOperationCanceled is the error of an Operation stopped by Cancel.
+var ErrOperationCanceled = errors.New("operation canceled")
+
+// Operation tracks a long-running action on a machine, such as a deployment,
+// from the moment maas accepted it until the machine reaches the status the
+// action ends in, or a failed status.
+type Operation struct {
+	controller *Controller
+	machine    Machine
+	stop       context.CancelFunc
+	done       chan struct{}
+	once       sync.Once
+
+	mu     sync.Mutex
+	status NodeStatus
+	result *Machine
+	err    error
+}
+
+// CompletedOperation returns an Operation that is already done, with the
+// given result. It is meant for fakes of the controller.
+func CompletedOperation(m *Machine, err error) *Operation {
+	op := &Operation{done: make(chan struct{})}
+	if m != nil {
+		op.machine = *m
+		op.status, _ = m.nodeStatus()
+	}
+	op.finish(m, err)
+	return op
+}
+
+// startOperation tracks the action started on m until the machine is in one
+// of targets.
+func (c *Controller) startOperation(ctx context.Context, m *Machine, targets []NodeStatus, opts WaitOptions) *Operation {
+	ctx, stop := context.WithCancel(ctx)
+	op := &Operation{
+		controller: c,
+		machine:    *m,
+		stop:       stop,
+		done:       make(chan struct{}),
+	}
+	op.status, _ = m.nodeStatus()
+	onTransition := opts.OnTransition
+	opts.OnTransition = func(m *Machine, from, to NodeStatus) {
+		op.mu.Lock()
+		op.status = to
+		op.mu.Unlock()
+		if onTransition != nil {
+			onTransition(m, from, to)
+		}
+	}
+	go func() {
+		result, err := c.WaitForStatus(ctx, m.SystemID, targets, opts)
+		op.finish(result, err)
+	}()
+	return op
+}
+
+// finish records the result of the operation, unless it already has one.
+func (op *Operation) finish(m *Machine, err error) {
+	op.once.Do(func() {
+		op.mu.Lock()
+		if m != nil {
+			if status, statusErr := m.nodeStatus(); statusErr == nil {
+				op.status = status
+			}
+		}
+		op.result, op.err = m, err
+		op.mu.Unlock()
+		if op.stop != nil {
+			op.stop()
+		}
+		close(op.done)
+	})
+}
+
+// SystemID returns the system ID of the machine of the operation.
+func (op *Operation) SystemID() string {
+	return op.machine.SystemID
+}
+
+// Status returns the last status seen of the machine.
+func (op *Operation) Status() NodeStatus {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.status
+}
+
+// Done returns a channel that is closed when the operation is over.
+func (op *Operation) Done() <-chan struct{} {
+	return op.done
+}
+
+// Wait waits for the operation to be over, and returns the machine in its
+// final status. The errors are those of WaitForStatus, or
+// ErrOperationCanceled. The error of ctx is returned if it is done first,
+// without stopping the operation.
+func (op *Operation) Wait(ctx context.Context) (*Machine, error) {
+	select {
+	case <-op.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.result, op.err
+}
+
+// Cancel aborts the action on the machine, and stops tracking it. It does
+// nothing if the operation is already over.
+func (op *Operation) Cancel() error {
+	return op.CancelContext(context.Background())
+}
+
+// CancelContext is like Cancel but the request is bound to ctx.
+func (op *Operation) CancelContext(ctx context.Context) error {
+	select {
+	case <-op.done:
+		return nil
+	default:
+	}
+	machine := op.machine
+	if err := op.controller.AbortMachineContext(ctx, &machine, AbortMachineArgs{}); err != nil {
+		return errors.Trace(err)
+	}
+	op.finish(&machine, ErrOperationCanceled)
+	return nil
+}
+
+// StartDeploy is like Deploy, but returns an Operation tracking the
+// deployment until the machine is deployed. The polling of the machine is
+// bound to ctx and configured by opts.
+func (c *Controller) StartDeploy(ctx context.Context, m *Machine, args DeployMachineArgs, opts WaitOptions) (*Operation, error) {
+	if err := c.DeployContext(ctx, m, args); err != nil {
+		return nil, err
+	}
+	return c.startOperation(ctx, m, []NodeStatus{NodeStatusDeployed}, opts), nil
+}
+
+// StartCommission is like CommissionMachine, but returns an Operation
+// tracking the commissioning until the machine is ready. The polling of the
+// machine is bound to ctx and configured by opts.
+func (c *Controller) StartCommission(ctx context.Context, m *Machine, args CommissionMachineArgs, opts WaitOptions) (*Operation, error) {
+	if err := c.CommissionMachineContext(ctx, m, args); err != nil {
+		return nil, err
+	}
+	return c.startOperation(ctx, m, []NodeStatus{NodeStatusReady}, opts), nil
+}
+
+// StartRelease is like ReleaseMachine, but returns an Operation tracking
+// the release, including the erasure of the disks, until the machine is
+// ready. The polling of the machine is bound to ctx and configured by opts.
+func (c *Controller) StartRelease(ctx context.Context, m *Machine, args ReleaseMachineArgs, opts WaitOptions) (*Operation, error) {
+	if err := c.ReleaseMachineContext(ctx, m, args); err != nil {
+		return nil, err
+	}
+	return c.startOperation(ctx, m, []NodeStatus{NodeStatusReady}, opts), nil
+}
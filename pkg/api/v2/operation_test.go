@@ -0,0 +1,100 @@
+package v2
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func machineInStatus(t *testing.T, status NodeStatus) string {
+	return util.UpdateJSONMap(t, machineResponse, map[string]interface{}{
+		"status":      status.Number(),
+		"status_name": status.String(),
+	})
+}
+
+func TestStartDeploy(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddPostResponse(machine.ResourceURI+"?op=deploy", http.StatusOK, machineInStatus(t, NodeStatusDeploying))
+	addMachineStatuses(t, server, NodeStatusDeploying, NodeStatusDeployed)
+
+	op, err := controller.StartDeploy(context.Background(), machine, DeployMachineArgs{}, WaitOptions{Interval: time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, op.SystemID(), "4y3ha3")
+	assert.Equal(t, machine.Status, NodeStatusDeploying)
+
+	deployed, err := op.Wait(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, deployed.Status, NodeStatusDeployed)
+	assert.Equal(t, op.Status(), NodeStatusDeployed)
+	select {
+	case <-op.Done():
+	default:
+		t.Fatal("operation not done")
+	}
+}
+
+func TestStartDeployRejected(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddPostResponse(machine.ResourceURI+"?op=deploy", http.StatusForbidden, "not yours")
+
+	op, err := controller.StartDeploy(context.Background(), machine, DeployMachineArgs{}, WaitOptions{})
+
+	assert.True(t, util.IsPermissionError(err))
+	assert.Nil(t, op)
+}
+
+func TestStartReleaseFails(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddPostResponse(machine.ResourceURI+"?op=release", http.StatusOK, machineInStatus(t, NodeStatusDiskErasing))
+	addMachineStatuses(t, server, NodeStatusFailedDiskErasing)
+
+	op, err := controller.StartRelease(context.Background(), machine, ReleaseMachineArgs{Erase: true}, WaitOptions{})
+	assert.Nil(t, err)
+	_, err = op.Wait(context.Background())
+
+	assert.True(t, IsFailedStatusError(err), "%v", err)
+	assert.Equal(t, op.Status(), NodeStatusFailedDiskErasing)
+}
+
+func TestOperationCancel(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddPostResponse(machine.ResourceURI+"?op=commission", http.StatusOK, machineInStatus(t, NodeStatusCommissioning))
+	server.AddPostResponse(machine.ResourceURI+"?op=abort", http.StatusOK, machineInStatus(t, NodeStatusDeclared))
+	addMachineStatuses(t, server, NodeStatusCommissioning)
+
+	op, err := controller.StartCommission(context.Background(), machine, CommissionMachineArgs{}, WaitOptions{Interval: time.Hour})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = op.Wait(ctx)
+	assert.Equal(t, err, context.DeadlineExceeded)
+
+	assert.Nil(t, op.Cancel())
+	aborted, err := op.Wait(context.Background())
+
+	assert.Equal(t, err, ErrOperationCanceled)
+	assert.Equal(t, aborted.Status, NodeStatusDeclared)
+	assert.Equal(t, op.Status(), NodeStatusDeclared)
+	// Canceling again does nothing.
+	assert.Nil(t, op.Cancel())
+}
+
+func TestCompletedOperation(t *testing.T) {
+	op := CompletedOperation(&Machine{SystemID: "4y3ha3", Status: NodeStatusDeployed}, nil)
+
+	machine, err := op.Wait(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, machine.SystemID, "4y3ha3")
+	assert.Equal(t, op.Status(), NodeStatusDeployed)
+	assert.Nil(t, op.Cancel())
+}
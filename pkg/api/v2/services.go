@@ -0,0 +1,176 @@
+package v2
+
+import (
+	"context"
+)
+
+// The service interfaces below group the typed operations of a Controller
+// by the part of maas they manage, so that code using only some of them can
+// depend on a narrow interface and be tested against a FakeController.
+// Each operation comes with a *Context variant, which binds the requests
+// made to ctx.
+
+// MachineService manages the machines of a maas.
+type MachineService interface {
+	Machines(args MachinesArgs) ([]Machine, error)
+	AllocateMachine(args AllocateMachineArgs) (*Machine, ConstraintMatches, error)
+	ReleaseMachines(args ReleaseMachinesArgs) error
+	Deploy(m *Machine, args DeployMachineArgs) error
+	SetOwnerData(m *Machine, ownerData map[string]string) error
+	CommissionMachine(m *Machine, args CommissionMachineArgs) error
+	AbortMachine(m *Machine, args AbortMachineArgs) error
+	ReleaseMachine(m *Machine, args ReleaseMachineArgs) error
+	PowerOnMachine(m *Machine, args PowerOnMachineArgs) error
+	PowerOffMachine(m *Machine, args PowerOffMachineArgs) error
+	MarkMachineBroken(m *Machine, args MarkMachineBrokenArgs) error
+	MarkMachineFixed(m *Machine, args MarkMachineFixedArgs) error
+	EnterRescueMode(m *Machine) error
+	ExitRescueMode(m *Machine) error
+	RestoreDefaultConfiguration(m *Machine) error
+	RestoreNetworkingConfiguration(m *Machine) error
+	SetStorageLayout(m *Machine, args SetStorageLayoutArgs) error
+	RestoreStorageConfiguration(m *Machine) error
+	ClearDefaultGateways(m *Machine) error
+	MountSpecial(m *Machine, args MountSpecialArgs) error
+	UnmountSpecial(m *Machine, mountPoint string) error
+	MachineDetails(m *Machine) ([]byte, error)
+	CurtinConfig(m *Machine) ([]byte, error)
+	PowerParameters(m *Machine) (map[string]interface{}, error)
+
+	MachinesContext(ctx context.Context, args MachinesArgs) ([]Machine, error)
+	AllocateMachineContext(ctx context.Context, args AllocateMachineArgs) (*Machine, ConstraintMatches, error)
+	ReleaseMachinesContext(ctx context.Context, args ReleaseMachinesArgs) error
+	DeployContext(ctx context.Context, m *Machine, args DeployMachineArgs) error
+	SetOwnerDataContext(ctx context.Context, m *Machine, ownerData map[string]string) error
+	CommissionMachineContext(ctx context.Context, m *Machine, args CommissionMachineArgs) error
+	AbortMachineContext(ctx context.Context, m *Machine, args AbortMachineArgs) error
+	ReleaseMachineContext(ctx context.Context, m *Machine, args ReleaseMachineArgs) error
+	PowerOnMachineContext(ctx context.Context, m *Machine, args PowerOnMachineArgs) error
+	PowerOffMachineContext(ctx context.Context, m *Machine, args PowerOffMachineArgs) error
+	MarkMachineBrokenContext(ctx context.Context, m *Machine, args MarkMachineBrokenArgs) error
+	MarkMachineFixedContext(ctx context.Context, m *Machine, args MarkMachineFixedArgs) error
+	EnterRescueModeContext(ctx context.Context, m *Machine) error
+	ExitRescueModeContext(ctx context.Context, m *Machine) error
+	RestoreDefaultConfigurationContext(ctx context.Context, m *Machine) error
+	RestoreNetworkingConfigurationContext(ctx context.Context, m *Machine) error
+	SetStorageLayoutContext(ctx context.Context, m *Machine, args SetStorageLayoutArgs) error
+	RestoreStorageConfigurationContext(ctx context.Context, m *Machine) error
+	ClearDefaultGatewaysContext(ctx context.Context, m *Machine) error
+	MountSpecialContext(ctx context.Context, m *Machine, args MountSpecialArgs) error
+	UnmountSpecialContext(ctx context.Context, m *Machine, mountPoint string) error
+	MachineDetailsContext(ctx context.Context, m *Machine) ([]byte, error)
+	CurtinConfigContext(ctx context.Context, m *Machine) ([]byte, error)
+	PowerParametersContext(ctx context.Context, m *Machine) (map[string]interface{}, error)
+
+	WaitForStatus(ctx context.Context, systemID string, targets []NodeStatus, opts WaitOptions) (*Machine, error)
+	StartDeploy(ctx context.Context, m *Machine, args DeployMachineArgs, opts WaitOptions) (*Operation, error)
+	StartCommission(ctx context.Context, m *Machine, args CommissionMachineArgs, opts WaitOptions) (*Operation, error)
+	StartRelease(ctx context.Context, m *Machine, args ReleaseMachineArgs, opts WaitOptions) (*Operation, error)
+}
+
+// NodeService manages the nodes and devices of a maas.
+type NodeService interface {
+	Nodes(args NodesArgs) ([]Node, error)
+	CreateNode(args CreateNodeArgs) (*Node, error)
+	Devices(args DevicesArgs) ([]Device, error)
+
+	NodesContext(ctx context.Context, args NodesArgs) ([]Node, error)
+	CreateNodeContext(ctx context.Context, args CreateNodeArgs) (*Node, error)
+	DevicesContext(ctx context.Context, args DevicesArgs) ([]Device, error)
+}
+
+// NetworkService manages the fabrics, spaces, static routes and zones of a
+// maas, and the network interfaces of its nodes.
+type NetworkService interface {
+	Fabrics() ([]Fabric, error)
+	Spaces() ([]Space, error)
+	StaticRoutes() ([]StaticRoute, error)
+	Zones() ([]Zone, error)
+	CreateInterface(d *Node, args CreateNodeNetworkInterfaceArgs) (*NetworkInterface, error)
+	LinkSubnet(i *NetworkInterface, args LinkSubnetArgs) error
+	UnlinkSubnet(i *NetworkInterface, s *Subnet) error
+	UpdateNetworkInterface(i *NetworkInterface, args UpdateInterfaceArgs) error
+
+	FabricsContext(ctx context.Context) ([]Fabric, error)
+	SpacesContext(ctx context.Context) ([]Space, error)
+	StaticRoutesContext(ctx context.Context) ([]StaticRoute, error)
+	ZonesContext(ctx context.Context) ([]Zone, error)
+	CreateInterfaceContext(ctx context.Context, d *Node, args CreateNodeNetworkInterfaceArgs) (*NetworkInterface, error)
+	LinkSubnetContext(ctx context.Context, i *NetworkInterface, args LinkSubnetArgs) error
+	UnlinkSubnetContext(ctx context.Context, i *NetworkInterface, s *Subnet) error
+	UpdateNetworkInterfaceContext(ctx context.Context, i *NetworkInterface, args UpdateInterfaceArgs) error
+}
+
+// FileService manages the files stored in a maas.
+type FileService interface {
+	GetFile(filename string) (*File, error)
+	ReadFileContent(f *File) ([]byte, error)
+	DownloadFile(args DownloadFileArgs) (int64, error)
+	AddFile(args AddFileArgs) error
+
+	GetFileContext(ctx context.Context, filename string) (*File, error)
+	ReadFileContentContext(ctx context.Context, f *File) ([]byte, error)
+	DownloadFileContext(ctx context.Context, args DownloadFileArgs) (int64, error)
+	AddFileContext(ctx context.Context, args AddFileArgs) error
+}
+
+// StorageService manages the block devices, partitions, filesystems and
+// volume groups of the machines of a maas.
+type StorageService interface {
+	FormatBlockDevice(d *BlockDevice, args FormatArgs) error
+	UnformatBlockDevice(d *BlockDevice) error
+	MountBlockDevice(d *BlockDevice, args MountArgs) error
+	UnmountBlockDevice(d *BlockDevice) error
+	SetBootDisk(d *BlockDevice) error
+	AddBlockDeviceTag(d *BlockDevice, tag string) error
+	RemoveBlockDeviceTag(d *BlockDevice, tag string) error
+	DeleteBlockDevice(d *BlockDevice) error
+	CreatePartition(d *BlockDevice, args CreatePartitionArgs) (*Partition, error)
+	DeletePartition(d *BlockDevice, p *Partition) error
+	FormatPartition(p *Partition, args FormatArgs) error
+	UnformatPartition(p *Partition) error
+	MountPartition(p *Partition, args MountArgs) error
+	UnmountPartition(p *Partition) error
+	CreateVolumeGroup(m *Machine, args CreateVolumeGroupArgs) (*VolumeGroup, error)
+	CreateLogicalVolume(vg *VolumeGroup, args CreateLogicalVolumeArgs) (*BlockDevice, error)
+
+	FormatBlockDeviceContext(ctx context.Context, d *BlockDevice, args FormatArgs) error
+	UnformatBlockDeviceContext(ctx context.Context, d *BlockDevice) error
+	MountBlockDeviceContext(ctx context.Context, d *BlockDevice, args MountArgs) error
+	UnmountBlockDeviceContext(ctx context.Context, d *BlockDevice) error
+	SetBootDiskContext(ctx context.Context, d *BlockDevice) error
+	AddBlockDeviceTagContext(ctx context.Context, d *BlockDevice, tag string) error
+	RemoveBlockDeviceTagContext(ctx context.Context, d *BlockDevice, tag string) error
+	DeleteBlockDeviceContext(ctx context.Context, d *BlockDevice) error
+	CreatePartitionContext(ctx context.Context, d *BlockDevice, args CreatePartitionArgs) (*Partition, error)
+	DeletePartitionContext(ctx context.Context, d *BlockDevice, p *Partition) error
+	FormatPartitionContext(ctx context.Context, p *Partition, args FormatArgs) error
+	UnformatPartitionContext(ctx context.Context, p *Partition) error
+	MountPartitionContext(ctx context.Context, p *Partition, args MountArgs) error
+	UnmountPartitionContext(ctx context.Context, p *Partition) error
+	CreateVolumeGroupContext(ctx context.Context, m *Machine, args CreateVolumeGroupArgs) (*VolumeGroup, error)
+	CreateLogicalVolumeContext(ctx context.Context, vg *VolumeGroup, args CreateLogicalVolumeArgs) (*BlockDevice, error)
+}
+
+// BootResourceService lists the boot resources of a maas.
+type BootResourceService interface {
+	BootResources() ([]*BootResource, error)
+
+	BootResourcesContext(ctx context.Context) ([]*BootResource, error)
+}
+
+// Services is the union of the service interfaces, implemented by
+// Controller and FakeController.
+type Services interface {
+	MachineService
+	NodeService
+	NetworkService
+	FileService
+	StorageService
+	BootResourceService
+}
+
+var (
+	_ Services = (*Controller)(nil)
+	_ Services = (*FakeController)(nil)
+)
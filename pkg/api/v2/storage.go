@@ -0,0 +1,351 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+type StorageOp string
+
+const (
+	// Format formats a block device or partition.
+	StorageFormat StorageOp = "format"
+	// Unformat removes the filesystem of a block device or partition.
+	StorageUnformat StorageOp = "unformat"
+	// Mount mounts the filesystem of a block device or partition.
+	StorageMount StorageOp = "mount"
+	// Unmount unmounts the filesystem of a block device or partition.
+	StorageUnmount StorageOp = "unmount"
+	// SetBootDisk sets a block device as the boot disk of its machine.
+	StorageSetBootDisk StorageOp = "set_boot_disk"
+	// AddTag adds a tag to a block device.
+	StorageAddTag StorageOp = "add_tag"
+	// RemoveTag removes a tag from a block device.
+	StorageRemoveTag StorageOp = "remove_tag"
+	// CreateLogicalVolume creates a logical volume in a volume group.
+	StorageCreateLogicalVolume StorageOp = "create_logical_volume"
+)
+
+// The storage of a machine can only be changed while it is ready or
+// allocated. Otherwise maas refuses the operations below, and they return a
+// CannotCompleteError.
+
+// FormatBlockDevice creates a filesystem on the whole block device, and
+// updates it with its new state.
+func (c *Controller) FormatBlockDevice(d *BlockDevice, args FormatArgs) error {
+	return c.FormatBlockDeviceContext(context.Background(), d, args)
+}
+
+// FormatBlockDeviceContext is like FormatBlockDevice but the request is
+// bound to ctx.
+func (c *Controller) FormatBlockDeviceContext(ctx context.Context, d *BlockDevice, args FormatArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "FormatBlockDevice")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.postBlockDeviceOp(ctx, d, StorageFormat, FormatParams(args).Values)
+}
+
+// UnformatBlockDevice removes the filesystem of the block device, and
+// updates it with its new state.
+func (c *Controller) UnformatBlockDevice(d *BlockDevice) error {
+	return c.UnformatBlockDeviceContext(context.Background(), d)
+}
+
+// UnformatBlockDeviceContext is like UnformatBlockDevice but the request is
+// bound to ctx.
+func (c *Controller) UnformatBlockDeviceContext(ctx context.Context, d *BlockDevice) (err error) {
+	ctx, end := c.startSpan(ctx, "UnformatBlockDevice")
+	defer end(&err)
+	return c.postBlockDeviceOp(ctx, d, StorageUnformat, nil)
+}
+
+// MountBlockDevice mounts the filesystem of the block device, and updates it
+// with its new state.
+func (c *Controller) MountBlockDevice(d *BlockDevice, args MountArgs) error {
+	return c.MountBlockDeviceContext(context.Background(), d, args)
+}
+
+// MountBlockDeviceContext is like MountBlockDevice but the request is bound
+// to ctx.
+func (c *Controller) MountBlockDeviceContext(ctx context.Context, d *BlockDevice, args MountArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "MountBlockDevice")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.postBlockDeviceOp(ctx, d, StorageMount, MountParams(args).Values)
+}
+
+// UnmountBlockDevice unmounts the filesystem of the block device, and
+// updates it with its new state.
+func (c *Controller) UnmountBlockDevice(d *BlockDevice) error {
+	return c.UnmountBlockDeviceContext(context.Background(), d)
+}
+
+// UnmountBlockDeviceContext is like UnmountBlockDevice but the request is
+// bound to ctx.
+func (c *Controller) UnmountBlockDeviceContext(ctx context.Context, d *BlockDevice) (err error) {
+	ctx, end := c.startSpan(ctx, "UnmountBlockDevice")
+	defer end(&err)
+	return c.postBlockDeviceOp(ctx, d, StorageUnmount, nil)
+}
+
+// SetBootDisk makes the block device the disk its machine boots from. The
+// block device is not updated, as maas does not return it.
+func (c *Controller) SetBootDisk(d *BlockDevice) error {
+	return c.SetBootDiskContext(context.Background(), d)
+}
+
+// SetBootDiskContext is like SetBootDisk but the request is bound to ctx.
+func (c *Controller) SetBootDiskContext(ctx context.Context, d *BlockDevice) (err error) {
+	ctx, end := c.startSpan(ctx, "SetBootDisk")
+	defer end(&err)
+	if _, err := c.PostContext(ctx, d.ResourceURI, string(StorageSetBootDisk), nil); err != nil {
+		return machineOpError(err)
+	}
+	return nil
+}
+
+// AddBlockDeviceTag tags the block device, and updates it with its new
+// state.
+func (c *Controller) AddBlockDeviceTag(d *BlockDevice, tag string) error {
+	return c.AddBlockDeviceTagContext(context.Background(), d, tag)
+}
+
+// AddBlockDeviceTagContext is like AddBlockDeviceTag but the request is
+// bound to ctx.
+func (c *Controller) AddBlockDeviceTagContext(ctx context.Context, d *BlockDevice, tag string) (err error) {
+	ctx, end := c.startSpan(ctx, "AddBlockDeviceTag")
+	defer end(&err)
+	if tag == "" {
+		return errors.NotValidf("empty tag")
+	}
+	return c.postBlockDeviceOp(ctx, d, StorageAddTag, url.Values{"tag": {tag}})
+}
+
+// RemoveBlockDeviceTag removes the tag from the block device, and updates it
+// with its new state.
+func (c *Controller) RemoveBlockDeviceTag(d *BlockDevice, tag string) error {
+	return c.RemoveBlockDeviceTagContext(context.Background(), d, tag)
+}
+
+// RemoveBlockDeviceTagContext is like RemoveBlockDeviceTag but the request
+// is bound to ctx.
+func (c *Controller) RemoveBlockDeviceTagContext(ctx context.Context, d *BlockDevice, tag string) (err error) {
+	ctx, end := c.startSpan(ctx, "RemoveBlockDeviceTag")
+	defer end(&err)
+	if tag == "" {
+		return errors.NotValidf("empty tag")
+	}
+	return c.postBlockDeviceOp(ctx, d, StorageRemoveTag, url.Values{"tag": {tag}})
+}
+
+// DeleteBlockDevice deletes the block device. Deleting a logical volume
+// frees its space in the volume group.
+func (c *Controller) DeleteBlockDevice(d *BlockDevice) error {
+	return c.DeleteBlockDeviceContext(context.Background(), d)
+}
+
+// DeleteBlockDeviceContext is like DeleteBlockDevice but the request is
+// bound to ctx.
+func (c *Controller) DeleteBlockDeviceContext(ctx context.Context, d *BlockDevice) (err error) {
+	ctx, end := c.startSpan(ctx, "DeleteBlockDevice")
+	defer end(&err)
+	if err := c.DeleteContext(ctx, d.ResourceURI); err != nil {
+		return machineOpError(err)
+	}
+	return nil
+}
+
+// CreatePartition creates a partition on the block device, adds it to the
+// partitions of the block device and returns it.
+func (c *Controller) CreatePartition(d *BlockDevice, args CreatePartitionArgs) (*Partition, error) {
+	return c.CreatePartitionContext(context.Background(), d, args)
+}
+
+// CreatePartitionContext is like CreatePartition but the request is bound
+// to ctx.
+func (c *Controller) CreatePartitionContext(ctx context.Context, d *BlockDevice, args CreatePartitionArgs) (_ *Partition, err error) {
+	ctx, end := c.startSpan(ctx, "CreatePartition")
+	defer end(&err)
+	var partition Partition
+	if err := c.postStorage(ctx, d.ResourceURI+"partitions/", "", CreatePartitionParams(args).Values, &partition); err != nil {
+		return nil, err
+	}
+	d.Partitions = append(d.Partitions, &partition)
+	return &partition, nil
+}
+
+// DeletePartition deletes the partition p of the block device d, and
+// removes it from the partitions of d.
+func (c *Controller) DeletePartition(d *BlockDevice, p *Partition) error {
+	return c.DeletePartitionContext(context.Background(), d, p)
+}
+
+// DeletePartitionContext is like DeletePartition but the request is bound
+// to ctx.
+func (c *Controller) DeletePartitionContext(ctx context.Context, d *BlockDevice, p *Partition) (err error) {
+	ctx, end := c.startSpan(ctx, "DeletePartition")
+	defer end(&err)
+	if err := c.DeleteContext(ctx, p.ResourceURI); err != nil {
+		return machineOpError(err)
+	}
+	partitions := d.Partitions[:0]
+	for _, partition := range d.Partitions {
+		if partition.ID != p.ID {
+			partitions = append(partitions, partition)
+		}
+	}
+	d.Partitions = partitions
+	return nil
+}
+
+// FormatPartition creates a filesystem on the partition, and updates it with
+// its new state.
+func (c *Controller) FormatPartition(p *Partition, args FormatArgs) error {
+	return c.FormatPartitionContext(context.Background(), p, args)
+}
+
+// FormatPartitionContext is like FormatPartition but the request is bound
+// to ctx.
+func (c *Controller) FormatPartitionContext(ctx context.Context, p *Partition, args FormatArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "FormatPartition")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.postPartitionOp(ctx, p, StorageFormat, FormatParams(args).Values)
+}
+
+// UnformatPartition removes the filesystem of the partition, and updates it
+// with its new state.
+func (c *Controller) UnformatPartition(p *Partition) error {
+	return c.UnformatPartitionContext(context.Background(), p)
+}
+
+// UnformatPartitionContext is like UnformatPartition but the request is
+// bound to ctx.
+func (c *Controller) UnformatPartitionContext(ctx context.Context, p *Partition) (err error) {
+	ctx, end := c.startSpan(ctx, "UnformatPartition")
+	defer end(&err)
+	return c.postPartitionOp(ctx, p, StorageUnformat, nil)
+}
+
+// MountPartition mounts the filesystem of the partition, and updates it with
+// its new state.
+func (c *Controller) MountPartition(p *Partition, args MountArgs) error {
+	return c.MountPartitionContext(context.Background(), p, args)
+}
+
+// MountPartitionContext is like MountPartition but the request is bound to
+// ctx.
+func (c *Controller) MountPartitionContext(ctx context.Context, p *Partition, args MountArgs) (err error) {
+	ctx, end := c.startSpan(ctx, "MountPartition")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.postPartitionOp(ctx, p, StorageMount, MountParams(args).Values)
+}
+
+// UnmountPartition unmounts the filesystem of the partition, and updates it
+// with its new state.
+func (c *Controller) UnmountPartition(p *Partition) error {
+	return c.UnmountPartitionContext(context.Background(), p)
+}
+
+// UnmountPartitionContext is like UnmountPartition but the request is bound
+// to ctx.
+func (c *Controller) UnmountPartitionContext(ctx context.Context, p *Partition) (err error) {
+	ctx, end := c.startSpan(ctx, "UnmountPartition")
+	defer end(&err)
+	return c.postPartitionOp(ctx, p, StorageUnmount, nil)
+}
+
+// CreateVolumeGroup creates a volume group on the machine, out of some of
+// its block devices and partitions, and returns it.
+func (c *Controller) CreateVolumeGroup(m *Machine, args CreateVolumeGroupArgs) (*VolumeGroup, error) {
+	return c.CreateVolumeGroupContext(context.Background(), m, args)
+}
+
+// CreateVolumeGroupContext is like CreateVolumeGroup but the request is
+// bound to ctx.
+func (c *Controller) CreateVolumeGroupContext(ctx context.Context, m *Machine, args CreateVolumeGroupArgs) (_ *VolumeGroup, err error) {
+	ctx, end := c.startSpan(ctx, "CreateVolumeGroup")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var vg VolumeGroup
+	path := "nodes/" + m.SystemID + "/volume-groups/"
+	if err := c.postStorage(ctx, path, "", CreateVolumeGroupParams(args).Values, &vg); err != nil {
+		return nil, err
+	}
+	return &vg, nil
+}
+
+// CreateLogicalVolume creates a logical volume in the volume group, adds it
+// to the logical volumes of the group and returns it. The logical volume is
+// a virtual block device, which can be formatted and mounted like the
+// others.
+func (c *Controller) CreateLogicalVolume(vg *VolumeGroup, args CreateLogicalVolumeArgs) (*BlockDevice, error) {
+	return c.CreateLogicalVolumeContext(context.Background(), vg, args)
+}
+
+// CreateLogicalVolumeContext is like CreateLogicalVolume but the request is
+// bound to ctx.
+func (c *Controller) CreateLogicalVolumeContext(ctx context.Context, vg *VolumeGroup, args CreateLogicalVolumeArgs) (_ *BlockDevice, err error) {
+	ctx, end := c.startSpan(ctx, "CreateLogicalVolume")
+	defer end(&err)
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var volume BlockDevice
+	params := CreateLogicalVolumeParams(args).Values
+	if err := c.postStorage(ctx, vg.ResourceURI, string(StorageCreateLogicalVolume), params, &volume); err != nil {
+		return nil, err
+	}
+	vg.LogicalVolumes = append(vg.LogicalVolumes, &volume)
+	return &volume, nil
+}
+
+// postBlockDeviceOp posts op to the block device d, and updates d from the
+// block device returned.
+func (c *Controller) postBlockDeviceOp(ctx context.Context, d *BlockDevice, op StorageOp, params url.Values) error {
+	var updated BlockDevice
+	if err := c.postStorage(ctx, d.ResourceURI, string(op), params, &updated); err != nil {
+		return err
+	}
+	*d = updated
+	return nil
+}
+
+// postPartitionOp posts op to the partition p, and updates p from the
+// partition returned.
+func (c *Controller) postPartitionOp(ctx context.Context, p *Partition, op StorageOp, params url.Values) error {
+	var updated Partition
+	if err := c.postStorage(ctx, p.ResourceURI, string(op), params, &updated); err != nil {
+		return err
+	}
+	*p = updated
+	return nil
+}
+
+// postStorage posts op to path, and unmarshals the response into result.
+// See machineOpError for the errors returned.
+func (c *Controller) postStorage(ctx context.Context, path, op string, params url.Values, result interface{}) error {
+	source, err := c.PostContext(ctx, path, op, params)
+	if err != nil {
+		return machineOpError(err)
+	}
+	if err := json.Unmarshal(source, result); err != nil {
+		return util.WrapWithDeserializationError(err, "response from %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package v2
+
+import (
+	"fmt"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+// FormatArgs is an argument struct for passing parameters to the
+// FormatBlockDevice and FormatPartition methods.
+type FormatArgs struct {
+	// FSType is the filesystem to create, e.g. "ext4", "xfs" or "swap".
+	FSType string
+	UUID   string
+	Label  string
+}
+
+// Validate checks that the filesystem is set.
+func (a *FormatArgs) Validate() error {
+	if a.FSType == "" {
+		return errors.NotValidf("missing FSType")
+	}
+	return nil
+}
+
+// MountArgs is an argument struct for passing parameters to the
+// MountBlockDevice and MountPartition methods.
+type MountArgs struct {
+	MountPoint   string
+	MountOptions string
+}
+
+// Validate checks that the mount point is set.
+func (a *MountArgs) Validate() error {
+	if a.MountPoint == "" {
+		return errors.NotValidf("missing MountPoint")
+	}
+	return nil
+}
+
+// CreatePartitionArgs is an argument struct for passing parameters to the
+// CreatePartition method.
+type CreatePartitionArgs struct {
+	// Size is the size of the partition in bytes. If zero, the partition
+	// takes the rest of the block device.
+	Size     uint64
+	UUID     string
+	Bootable bool
+}
+
+// CreateVolumeGroupArgs is an argument struct for passing parameters to the
+// CreateVolumeGroup method.
+type CreateVolumeGroupArgs struct {
+	Name string
+	UUID string
+	// BlockDevices and Partitions are the IDs of the block devices and
+	// partitions the volume group is made of.
+	BlockDevices []int
+	Partitions   []int
+}
+
+// Validate checks that the name is set, and that the volume group is made of
+// at least one block device or partition.
+func (a *CreateVolumeGroupArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if len(a.BlockDevices) == 0 && len(a.Partitions) == 0 {
+		return errors.NotValidf("missing BlockDevices and Partitions")
+	}
+	return nil
+}
+
+// CreateLogicalVolumeArgs is an argument struct for passing parameters to
+// the CreateLogicalVolume method.
+type CreateLogicalVolumeArgs struct {
+	Name string
+	UUID string
+	// Size is the size of the logical volume in bytes.
+	Size uint64
+}
+
+// Validate checks that the name and size are set.
+func (a *CreateLogicalVolumeArgs) Validate() error {
+	if a.Name == "" {
+		return errors.NotValidf("missing Name")
+	}
+	if a.Size == 0 {
+		return errors.NotValidf("missing Size")
+	}
+	return nil
+}
+
+func FormatParams(args FormatArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("fstype", args.FSType)
+	params.MaybeAdd("uuid", args.UUID)
+	params.MaybeAdd("label", args.Label)
+	return params
+}
+
+func MountParams(args MountArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("mount_point", args.MountPoint)
+	params.MaybeAdd("mount_options", args.MountOptions)
+	return params
+}
+
+func CreatePartitionParams(args CreatePartitionArgs) *util.URLParams {
+	params := util.NewURLParams()
+	if args.Size > 0 {
+		params.Values.Add("size", fmt.Sprint(args.Size))
+	}
+	params.MaybeAdd("uuid", args.UUID)
+	params.MaybeAddBool("bootable", args.Bootable)
+	return params
+}
+
+func CreateVolumeGroupParams(args CreateVolumeGroupArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("name", args.Name)
+	params.MaybeAdd("uuid", args.UUID)
+	for _, id := range args.BlockDevices {
+		params.Values.Add("block_devices", fmt.Sprint(id))
+	}
+	for _, id := range args.Partitions {
+		params.Values.Add("partitions", fmt.Sprint(id))
+	}
+	return params
+}
+
+func CreateLogicalVolumeParams(args CreateLogicalVolumeArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("name", args.Name)
+	params.MaybeAdd("uuid", args.UUID)
+	params.Values.Add("size", fmt.Sprint(args.Size))
+	return params
+}
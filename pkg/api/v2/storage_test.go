@@ -0,0 +1,175 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func toJSON(t *testing.T, v interface{}) string {
+	source, err := json.Marshal(v)
+	assert.Nil(t, err)
+	return string(source)
+}
+
+func TestFormatAndMountBlockDevice(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	device := machine.BlockDevices[0]
+	formatted := *device
+	formatted.FileSystem = &Filesystem{Type: "xfs", Label: "data"}
+	server.AddPostResponse(device.ResourceURI+"?op=format", http.StatusOK, toJSON(t, formatted))
+	mounted := formatted
+	mounted.FileSystem = &Filesystem{Type: "xfs", Label: "data", MountPoint: "/srv", MountOptions: "noatime"}
+	server.AddPostResponse(device.ResourceURI+"?op=mount", http.StatusOK, toJSON(t, mounted))
+
+	err := controller.FormatBlockDevice(device, FormatArgs{FSType: "xfs", Label: "data"})
+	assert.Nil(t, err)
+	assert.Equal(t, device.FileSystem.Type, "xfs")
+	form := server.LastRequest().PostForm
+	assert.Len(t, form, 2)
+	assert.Equal(t, form.Get("fstype"), "xfs")
+	assert.Equal(t, form.Get("label"), "data")
+
+	err = controller.MountBlockDevice(device, MountArgs{MountPoint: "/srv", MountOptions: "noatime"})
+	assert.Nil(t, err)
+	assert.Equal(t, device.FileSystem.MountPoint, "/srv")
+	assert.Equal(t, device.FileSystem.MountOptions, "noatime")
+	assert.Equal(t, server.LastRequest().PostForm.Get("mount_point"), "/srv")
+}
+
+func TestStorageArgsValidated(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	device := machine.BlockDevices[0]
+
+	err := controller.FormatBlockDevice(device, FormatArgs{})
+	assert.True(t, errors.IsNotValid(err))
+	err = controller.MountPartition(device.Partitions[0], MountArgs{})
+	assert.True(t, errors.IsNotValid(err))
+	err = controller.AddBlockDeviceTag(device, "")
+	assert.True(t, errors.IsNotValid(err))
+	_, err = controller.CreateVolumeGroup(machine, CreateVolumeGroupArgs{Name: "vg0"})
+	assert.True(t, errors.IsNotValid(err))
+	_, err = controller.CreateLogicalVolume(&VolumeGroup{}, CreateLogicalVolumeArgs{Name: "lv0"})
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func TestBlockDeviceTags(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	device := machine.BlockDevices[0]
+	tagged := *device
+	tagged.Tags = []string{"rotary", "fast"}
+	server.AddPostResponse(device.ResourceURI+"?op=add_tag", http.StatusOK, toJSON(t, tagged))
+	server.AddPostResponse(device.ResourceURI+"?op=remove_tag", http.StatusForbidden, "not yours")
+
+	err := controller.AddBlockDeviceTag(device, "fast")
+	assert.Nil(t, err)
+	assert.Equal(t, device.Tags, []string{"rotary", "fast"})
+	assert.Equal(t, server.LastRequest().PostForm.Get("tag"), "fast")
+
+	err = controller.RemoveBlockDeviceTag(device, "fast")
+	assert.True(t, util.IsPermissionError(err))
+	assert.Equal(t, device.Tags, []string{"rotary", "fast"})
+}
+
+func TestSetBootDisk(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	device := machine.BlockDevices[1]
+	server.AddPostResponse(device.ResourceURI+"?op=set_boot_disk", http.StatusOK, "OK")
+
+	err := controller.SetBootDisk(device)
+
+	assert.Nil(t, err)
+}
+
+func TestCreateAndDeletePartition(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	device := machine.BlockDevices[0]
+	created := Partition{
+		ResourceURI: device.ResourceURI + "partition/2/",
+		ID:          2,
+		Size:        1 << 30,
+	}
+	server.AddPostResponse(device.ResourceURI+"partitions/?op=", http.StatusOK, toJSON(t, created))
+	server.AddDeleteResponse(created.ResourceURI, http.StatusNoContent, "")
+
+	partition, err := controller.CreatePartition(device, CreatePartitionArgs{Size: 1 << 30, Bootable: true})
+	assert.Nil(t, err)
+	assert.Equal(t, partition.ID, 2)
+	assert.Len(t, device.Partitions, 2)
+	form := server.LastRequest().PostForm
+	assert.Equal(t, form.Get("size"), "1073741824")
+	assert.Equal(t, form.Get("bootable"), "true")
+
+	err = controller.DeletePartition(device, partition)
+	assert.Nil(t, err)
+	assert.Len(t, device.Partitions, 1)
+	assert.Equal(t, device.Partitions[0].ID, 1)
+}
+
+func TestFormatPartitionCannotComplete(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	partition := machine.BlockDevices[0].Partitions[0]
+	server.AddPostResponse(partition.ResourceURI+"/?op=format", http.StatusConflict, "machine is deployed")
+
+	err := controller.FormatPartition(partition, FormatArgs{FSType: "ext4"})
+
+	assert.True(t, util.IsCannotCompleteError(err), "%v", err)
+	assert.Equal(t, partition.FileSystem.Type, "ext4")
+}
+
+func TestUnmountPartition(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	partition := machine.BlockDevices[0].Partitions[0]
+	unmounted := *partition
+	unmounted.FileSystem = &Filesystem{Type: "ext4", Label: "root"}
+	server.AddPostResponse(partition.ResourceURI+"/?op=unmount", http.StatusOK, toJSON(t, unmounted))
+
+	err := controller.UnmountPartition(partition)
+
+	assert.Nil(t, err)
+	assert.Equal(t, partition.FileSystem.MountPoint, "")
+}
+
+func TestCreateVolumeGroupAndLogicalVolume(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	group := VolumeGroup{
+		ResourceURI: "/maas/api/2.0/nodes/4y3ha3/volume-group/5/",
+		ID:          5,
+		Name:        "vg0",
+		Size:        8589934592,
+	}
+	server.AddPostResponse("/api/2.0/nodes/4y3ha3/volume-groups/?op=", http.StatusOK, toJSON(t, group))
+	volume := BlockDevice{
+		ResourceURI: "/maas/api/2.0/nodes/4y3ha3/blockdevices/40/",
+		ID:          40,
+		Name:        "vg0-lv0",
+		Type:        "virtual",
+		Size:        1 << 30,
+	}
+	server.AddPostResponse(group.ResourceURI+"?op=create_logical_volume", http.StatusOK, toJSON(t, volume))
+
+	vg, err := controller.CreateVolumeGroup(machine, CreateVolumeGroupArgs{Name: "vg0", BlockDevices: []int{34, 35}})
+	assert.Nil(t, err)
+	assert.Equal(t, vg.Name, "vg0")
+	form := server.LastRequest().PostForm
+	assert.Equal(t, form.Get("name"), "vg0")
+	assert.Equal(t, form["block_devices"], []string{"34", "35"})
+
+	lv, err := controller.CreateLogicalVolume(vg, CreateLogicalVolumeArgs{Name: "lv0", Size: 1 << 30})
+	assert.Nil(t, err)
+	assert.Equal(t, lv.Type, "virtual")
+	assert.Len(t, vg.LogicalVolumes, 1)
+	assert.Equal(t, server.LastRequest().PostForm.Get("size"), "1073741824")
+}
@@ -0,0 +1,123 @@
+package v2
+
+import (
+	"fmt"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+// StorageLayout is a layout maas can apply to the storage of a machine
+// with SetStorageLayout, replacing its current storage configuration.
+type StorageLayout string
+
+const (
+	// StorageLayoutFlat puts the root filesystem on a single partition of
+	// the boot disk.
+	StorageLayoutFlat StorageLayout = "flat"
+	// StorageLayoutLVM puts the root filesystem on a logical volume of a
+	// volume group made of a partition of the boot disk.
+	StorageLayoutLVM StorageLayout = "lvm"
+	// StorageLayoutBcache puts the root filesystem on a bcache device,
+	// backed by the boot disk and cached by a solid state disk.
+	StorageLayoutBcache StorageLayout = "bcache"
+	// StorageLayoutVMFS is the layout used to deploy VMware ESXi 6.
+	StorageLayoutVMFS StorageLayout = "vmfs6"
+	// StorageLayoutBlank removes all the storage configuration, leaving the
+	// disks unused.
+	StorageLayoutBlank StorageLayout = "blank"
+)
+
+// BcacheMode is the caching mode of a bcache device.
+type BcacheMode string
+
+const (
+	BcacheModeWriteBack    BcacheMode = "writeback"
+	BcacheModeWriteThrough BcacheMode = "writethrough"
+	BcacheModeWriteAround  BcacheMode = "writearound"
+)
+
+// SetStorageLayoutArgs is an argument struct for passing parameters to the
+// SetStorageLayout method. The sizes are in bytes, and are left to maas to
+// decide when zero.
+type SetStorageLayoutArgs struct {
+	Layout StorageLayout
+
+	// BootSize, RootSize and RootDevice apply to all the layouts but
+	// StorageLayoutBlank. RootDevice is the name or ID of the block device
+	// the root filesystem goes on, the boot disk by default.
+	BootSize   uint64
+	RootSize   uint64
+	RootDevice string
+
+	// VGName, LVName and LVSize only apply to StorageLayoutLVM.
+	VGName string
+	LVName string
+	LVSize uint64
+
+	// CacheDevice, CacheMode, CacheSize and CacheNoPart only apply to
+	// StorageLayoutBcache. CacheDevice is the name or ID of the block device
+	// used as cache, the first solid state disk by default. With CacheNoPart,
+	// the whole cache device is used, rather than a partition of it.
+	CacheDevice string
+	CacheMode   BcacheMode
+	CacheSize   uint64
+	CacheNoPart bool
+}
+
+// Validate checks that the layout is known, and that only the options of the
+// layout are set.
+func (a *SetStorageLayoutArgs) Validate() error {
+	switch a.Layout {
+	case StorageLayoutFlat, StorageLayoutLVM, StorageLayoutBcache, StorageLayoutVMFS:
+	case StorageLayoutBlank:
+		if a.BootSize != 0 || a.RootSize != 0 || a.RootDevice != "" {
+			return errors.NotValidf("boot and root options with %s layout", a.Layout)
+		}
+	case "":
+		return errors.NotValidf("missing Layout")
+	default:
+		return errors.NotValidf("storage layout %q", a.Layout)
+	}
+	if a.Layout != StorageLayoutLVM && (a.VGName != "" || a.LVName != "" || a.LVSize != 0) {
+		return errors.NotValidf("LVM options with %s layout", a.Layout)
+	}
+	if a.Layout != StorageLayoutBcache && (a.CacheDevice != "" || a.CacheMode != "" || a.CacheSize != 0 || a.CacheNoPart) {
+		return errors.NotValidf("bcache options with %s layout", a.Layout)
+	}
+	if a.RootSize != 0 && a.LVSize > a.RootSize {
+		return errors.NotValidf("LVSize %d larger than RootSize %d", a.LVSize, a.RootSize)
+	}
+	switch a.CacheMode {
+	case "", BcacheModeWriteBack, BcacheModeWriteThrough, BcacheModeWriteAround:
+	default:
+		return errors.NotValidf("cache mode %q", a.CacheMode)
+	}
+	if a.CacheNoPart && a.CacheSize != 0 {
+		return errors.NotValidf("CacheSize with CacheNoPart")
+	}
+	return nil
+}
+
+func SetStorageLayoutParams(args SetStorageLayoutArgs) *util.URLParams {
+	params := util.NewURLParams()
+	params.MaybeAdd("storage_layout", string(args.Layout))
+	maybeAddSize(params, "boot_size", args.BootSize)
+	maybeAddSize(params, "root_size", args.RootSize)
+	params.MaybeAdd("root_device", args.RootDevice)
+	params.MaybeAdd("vg_name", args.VGName)
+	params.MaybeAdd("lv_name", args.LVName)
+	maybeAddSize(params, "lv_size", args.LVSize)
+	params.MaybeAdd("cache_device", args.CacheDevice)
+	params.MaybeAdd("cache_mode", string(args.CacheMode))
+	maybeAddSize(params, "cache_size", args.CacheSize)
+	params.MaybeAddBool("cache_no_part", args.CacheNoPart)
+	return params
+}
+
+// maybeAddSize adds the size in bytes iff it is not zero.
+func maybeAddSize(params *util.URLParams, name string, size uint64) {
+	if size != 0 {
+		params.Values.Add(name, fmt.Sprint(size))
+	}
+}
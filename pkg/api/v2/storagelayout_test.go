@@ -0,0 +1,100 @@
+package v2
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStorageLayoutArgs(t *testing.T) {
+	for _, test := range []struct {
+		args   SetStorageLayoutArgs
+		err    string
+		params url.Values
+	}{{
+		args: SetStorageLayoutArgs{},
+		err:  "missing Layout not valid",
+	}, {
+		args: SetStorageLayoutArgs{Layout: "zfs"},
+		err:  `storage layout "zfs" not valid`,
+	}, {
+		args:   SetStorageLayoutArgs{Layout: StorageLayoutFlat, BootSize: 1 << 29, RootDevice: "sdb"},
+		params: url.Values{"storage_layout": {"flat"}, "boot_size": {"536870912"}, "root_device": {"sdb"}},
+	}, {
+		args: SetStorageLayoutArgs{Layout: StorageLayoutFlat, VGName: "vg0"},
+		err:  "LVM options with flat layout not valid",
+	}, {
+		args:   SetStorageLayoutArgs{Layout: StorageLayoutLVM, VGName: "vg0", LVName: "root", LVSize: 1 << 30},
+		params: url.Values{"storage_layout": {"lvm"}, "vg_name": {"vg0"}, "lv_name": {"root"}, "lv_size": {"1073741824"}},
+	}, {
+		args: SetStorageLayoutArgs{Layout: StorageLayoutLVM, RootSize: 1 << 30, LVSize: 1 << 31},
+		err:  "LVSize 2147483648 larger than RootSize 1073741824 not valid",
+	}, {
+		args: SetStorageLayoutArgs{Layout: StorageLayoutLVM, CacheMode: BcacheModeWriteBack},
+		err:  "bcache options with lvm layout not valid",
+	}, {
+		args:   SetStorageLayoutArgs{Layout: StorageLayoutBcache, CacheDevice: "nvme0n1", CacheMode: BcacheModeWriteThrough, CacheNoPart: true},
+		params: url.Values{"storage_layout": {"bcache"}, "cache_device": {"nvme0n1"}, "cache_mode": {"writethrough"}, "cache_no_part": {"true"}},
+	}, {
+		args: SetStorageLayoutArgs{Layout: StorageLayoutBcache, CacheMode: "writeonly"},
+		err:  `cache mode "writeonly" not valid`,
+	}, {
+		args: SetStorageLayoutArgs{Layout: StorageLayoutBcache, CacheNoPart: true, CacheSize: 1 << 30},
+		err:  "CacheSize with CacheNoPart not valid",
+	}, {
+		args:   SetStorageLayoutArgs{Layout: StorageLayoutBlank},
+		params: url.Values{"storage_layout": {"blank"}},
+	}, {
+		args: SetStorageLayoutArgs{Layout: StorageLayoutBlank, RootSize: 1 << 30},
+		err:  "boot and root options with blank layout not valid",
+	}} {
+		err := test.args.Validate()
+		if test.err == "" {
+			assert.Nil(t, err)
+			assert.Equal(t, SetStorageLayoutParams(test.args).Values, test.params)
+		} else {
+			assert.True(t, errors.IsNotValid(err))
+			assert.Equal(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestMachineSetStorageLayout(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	response := util.UpdateJSONMap(t, machineResponse, map[string]interface{}{
+		"status_message": "lvm layout",
+	})
+	server.AddPostResponse(machine.ResourceURI+"?op=set_storage_layout", http.StatusOK, response)
+
+	err := controller.SetStorageLayout(machine, SetStorageLayoutArgs{Layout: StorageLayoutLVM, VGName: "vg0"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, machine.StatusMessage, "lvm layout")
+	form := server.LastRequest().PostForm
+	assert.Equal(t, form.Get("storage_layout"), "lvm")
+	assert.Equal(t, form.Get("vg_name"), "vg0")
+}
+
+func TestMachineSetStorageLayoutValidates(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+
+	err := controller.SetStorageLayout(machine, SetStorageLayoutArgs{Layout: StorageLayoutFlat, LVName: "root"})
+
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func TestMachineSetStorageLayoutDeployed(t *testing.T) {
+	server, machine, controller := getMachineControllerAndServer(t)
+	defer server.Close()
+	server.AddPostResponse(machine.ResourceURI+"?op=set_storage_layout", http.StatusConflict, "machine is deployed")
+
+	err := controller.SetStorageLayout(machine, SetStorageLayoutArgs{Layout: StorageLayoutFlat})
+
+	assert.True(t, util.IsCannotCompleteError(err))
+}
@@ -0,0 +1,27 @@
+package v2
+
+import (
+	"context"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
+)
+
+// AttributeOperation names the controller operation of a span.
+const AttributeOperation = "maas.operation"
+
+// startSpan starts the span of a logical operation of the controller with
+// the Tracer of its client, the parent of the spans of the HTTP attempts the
+// operation makes. The returned function ends the span, recording the error
+// pointed to if any.
+func (c *Controller) startSpan(ctx context.Context, operation string) (context.Context, func(*error)) {
+	if c.Client == nil || c.Client.Tracer == nil {
+		return ctx, func(*error) {}
+	}
+	ctx, span := c.Client.Tracer.Start(ctx, "maas."+operation, client.Attribute{Key: AttributeOperation, Value: operation})
+	return ctx, func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+		}
+		span.End()
+	}
+}
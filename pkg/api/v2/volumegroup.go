@@ -0,0 +1,14 @@
+package v2
+
+// VolumeGroup is an LVM volume group of a machine, made of block devices and
+// partitions. Its logical volumes are virtual block devices.
+type VolumeGroup struct {
+	ResourceURI    string         `json:"resource_uri,omitempty"`
+	ID             int            `json:"id,omitempty"`
+	Name           string         `json:"name,omitempty"`
+	UUID           string         `json:"uuid,omitempty"`
+	Size           uint64         `json:"size,omitempty"`
+	AvailableSize  uint64         `json:"available_size,omitempty"`
+	UsedSize       uint64         `json:"used_size,omitempty"`
+	LogicalVolumes []*BlockDevice `json:"logical_volumes,omitempty"`
+}
@@ -0,0 +1,138 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultWaitInterval is the wait between the first polls of
+	// WaitForStatus.
+	DefaultWaitInterval = 2 * time.Second
+
+	// DefaultMaxWaitInterval caps the wait between the polls of
+	// WaitForStatus.
+	DefaultMaxWaitInterval = 30 * time.Second
+)
+
+// WaitOptions configures WaitForStatus. Zero fields take their defaults.
+type WaitOptions struct {
+	// Interval is the wait between the first two polls; it doubles after
+	// every poll. Defaults to DefaultWaitInterval.
+	Interval time.Duration
+	// MaxInterval caps the wait between polls. Defaults to
+	// DefaultMaxWaitInterval.
+	MaxInterval time.Duration
+	// OnTransition, when set, is called with the machine polled each time
+	// its status differs from the one of the previous poll.
+	OnTransition func(m *Machine, from, to NodeStatus)
+	// Clock times the waits between polls. Defaults to client.WallClock.
+	Clock client.Clock
+}
+
+// FailedStatusError is returned by WaitForStatus when the machine reaches a
+// failed status it was not waited for.
+type FailedStatusError struct {
+	errors.Err
+	SystemID string
+	Status   NodeStatus
+}
+
+func newFailedStatusError(m *Machine, status NodeStatus) error {
+	message := fmt.Sprintf("machine %s is in status %s", m.SystemID, status)
+	if m.StatusMessage != "" {
+		message += ": " + m.StatusMessage
+	}
+	err := &FailedStatusError{Err: errors.NewErr(message), SystemID: m.SystemID, Status: status}
+	err.SetLocation(1)
+	return err
+}
+
+// IsFailedStatusError returns true if err is a FailedStatusError.
+func IsFailedStatusError(err error) bool {
+	_, ok := errors.Cause(err).(*FailedStatusError)
+	return ok
+}
+
+// nodeStatus returns the status of the machine, from its name when the
+// server did not send the number.
+func (m *Machine) nodeStatus() (NodeStatus, error) {
+	if m.Status != "" {
+		return m.Status, nil
+	}
+	status, err := ParseNodeStatus(m.StatusName)
+	if err != nil {
+		return "", util.WrapWithDeserializationError(err, "machine %s", m.SystemID)
+	}
+	return status, nil
+}
+
+// WaitForStatus polls the machine with the given system ID until it is in
+// one of the targets statuses, and returns it. Returns
+//   - NotValid error if systemID or targets are missing
+//   - NoMatchError if the machine cannot be found
+//   - FailedStatusError, with the machine, if the machine reaches a failed
+//     status that is not one of the targets
+//   - the error of ctx if it is done first
+func (c *Controller) WaitForStatus(ctx context.Context, systemID string, targets []NodeStatus, opts WaitOptions) (_ *Machine, err error) {
+	ctx, end := c.startSpan(ctx, "WaitForStatus")
+	defer end(&err)
+	if systemID == "" {
+		return nil, errors.NotValidf("missing system ID")
+	}
+	if len(targets) == 0 {
+		return nil, errors.NotValidf("missing target statuses")
+	}
+	interval, maxInterval, clock := opts.Interval, opts.MaxInterval, opts.Clock
+	if interval <= 0 {
+		interval = DefaultWaitInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = DefaultMaxWaitInterval
+	}
+	if clock == nil {
+		clock = client.WallClock
+	}
+	var last NodeStatus
+	for {
+		machines, err := c.MachinesContext(ctx, MachinesArgs{SystemIDs: []string{systemID}})
+		if err != nil {
+			return nil, err
+		}
+		if len(machines) == 0 {
+			return nil, util.NewNoMatchError("no machine with system ID " + systemID)
+		}
+		m := &machines[0]
+		status, err := m.nodeStatus()
+		if err != nil {
+			return nil, err
+		}
+		if last != "" && status != last && opts.OnTransition != nil {
+			opts.OnTransition(m, last, status)
+		}
+		last = status
+		for _, target := range targets {
+			if status == target {
+				return m, nil
+			}
+		}
+		if status.IsFailed() {
+			return m, newFailedStatusError(m, status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Annotatef(ctx.Err(), "waiting for machine %s in status %s", systemID, status)
+		case <-clock.After(interval):
+		}
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
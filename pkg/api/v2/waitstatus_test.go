@@ -0,0 +1,113 @@
+package v2
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/client"
+	"github.com/alejandroEsc/golang-maas-client/pkg/api/util"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// addMachineStatuses queues the responses to the polls of the machine of
+// machineResponse, in the given statuses.
+func addMachineStatuses(t *testing.T, server *client.SimpleTestServer, statuses ...NodeStatus) {
+	for _, status := range statuses {
+		response := util.UpdateJSONMap(t, machineResponse, map[string]interface{}{
+			"status":         status.Number(),
+			"status_name":    status.String(),
+			"status_message": "testing " + status.String(),
+		})
+		server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+response+"]")
+	}
+}
+
+func TestWaitForStatus(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	addMachineStatuses(t, server, NodeStatusAllocated, NodeStatusDeploying, NodeStatusDeploying, NodeStatusDeployed)
+	type transition struct{ from, to NodeStatus }
+	var transitions []transition
+
+	machine, err := controller.WaitForStatus(context.Background(), "4y3ha3", []NodeStatus{NodeStatusDeployed}, WaitOptions{
+		Interval: time.Millisecond,
+		OnTransition: func(m *Machine, from, to NodeStatus) {
+			assert.Equal(t, m.Status, to)
+			transitions = append(transitions, transition{from, to})
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, machine.Status, NodeStatusDeployed)
+	assert.Equal(t, transitions, []transition{
+		{NodeStatusAllocated, NodeStatusDeploying},
+		{NodeStatusDeploying, NodeStatusDeployed},
+	})
+}
+
+func TestWaitForStatusFailed(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	addMachineStatuses(t, server, NodeStatusReleasing, NodeStatusDiskErasing, NodeStatusFailedDiskErasing)
+
+	machine, err := controller.WaitForStatus(context.Background(), "4y3ha3", []NodeStatus{NodeStatusReady}, WaitOptions{Interval: time.Millisecond})
+
+	assert.True(t, IsFailedStatusError(err), "%v", err)
+	assert.Equal(t, err.(*FailedStatusError).Status, NodeStatusFailedDiskErasing)
+	assert.Equal(t, err.Error(), "machine 4y3ha3 is in status Failed disk erasing: testing Failed disk erasing")
+	assert.Equal(t, machine.Status, NodeStatusFailedDiskErasing)
+}
+
+func TestWaitForStatusFailedTarget(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	addMachineStatuses(t, server, NodeStatusFailedDeployment)
+
+	targets := []NodeStatus{NodeStatusDeployed, NodeStatusFailedDeployment}
+	machine, err := controller.WaitForStatus(context.Background(), "4y3ha3", targets, WaitOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, machine.Status, NodeStatusFailedDeployment)
+}
+
+func TestWaitForStatusFromName(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	response := util.UpdateJSONMap(t, machineResponse, map[string]interface{}{
+		"status":      nil,
+		"status_name": "Ready",
+	})
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+response+"]")
+
+	machine, err := controller.WaitForStatus(context.Background(), "4y3ha3", []NodeStatus{NodeStatusReady}, WaitOptions{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, machine.StatusName, "Ready")
+}
+
+func TestWaitForStatusContextDone(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	addMachineStatuses(t, server, NodeStatusDeploying)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := controller.WaitForStatus(ctx, "4y3ha3", []NodeStatus{NodeStatusDeployed}, WaitOptions{Interval: time.Hour})
+
+	assert.Equal(t, errors.Cause(err), context.DeadlineExceeded)
+}
+
+func TestWaitForStatusNotFound(t *testing.T) {
+	server, controller := createTestServerController(t)
+	defer server.Close()
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "[]")
+
+	_, err := controller.WaitForStatus(context.Background(), "4y3ha3", []NodeStatus{NodeStatusDeployed}, WaitOptions{})
+	assert.True(t, util.IsNoMatchError(err))
+
+	_, err = controller.WaitForStatus(context.Background(), "4y3ha3", nil, WaitOptions{})
+	assert.True(t, errors.IsNotValid(err))
+}